@@ -0,0 +1,349 @@
+package marvin
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riking/marvin/slack"
+)
+
+// ArgType is the type of a parameter declared on an ArgParser.
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgDuration
+	// A user mention or username, resolved with Team.ResolveUserName.
+	ArgUser
+	// A channel mention or name, resolved with Team.ResolveChannelName.
+	ArgChannel
+	// A Slack archive link to a message.
+	ArgArchiveLink
+	// A flag with no value. Only valid for flags.
+	ArgBool
+)
+
+func (at ArgType) placeholder() string {
+	switch at {
+	case ArgInt:
+		return "number"
+	case ArgDuration:
+		return "duration"
+	case ArgUser:
+		return "@user"
+	case ArgChannel:
+		return "#channel"
+	case ArgArchiveLink:
+		return "message link"
+	}
+	return "text"
+}
+
+type argSpec struct {
+	name     string
+	short    string
+	typ      ArgType
+	optional bool
+	help     string
+}
+
+// ArgParser parses CommandArguments against declared flags and positional
+// parameters, and produces usage text from the declaration.
+//
+//	var argsAudit = marvin.NewArgParser("audit").
+//		Flag("limit", "n", marvin.ArgInt, "show this many entries (default 10)").
+//		Flag("action", "", marvin.ArgString, "only show this action, e.g. `config.set`").
+//		OptionalArg("user|module", marvin.ArgString)
+//
+//	p, fail := argsAudit.Parse(t, args)
+//	if fail != nil {
+//		return *fail
+//	}
+//	action := p.String("action")
+//
+// Flags are written as `--name value`, `--name=value` or `-s value`, and may
+// appear anywhere before the Rest parameter. `--` ends the flags.
+type ArgParser struct {
+	command    string
+	flags      []argSpec
+	positional []argSpec
+	rest       *argSpec
+}
+
+// NewArgParser starts a declaration for the command, which is the full command
+// path used in the usage text, e.g. "perm grant".
+func NewArgParser(command string) *ArgParser {
+	return &ArgParser{command: command}
+}
+
+// Flag declares an optional flag. short may be empty.
+func (p *ArgParser) Flag(name, short string, typ ArgType, help string) *ArgParser {
+	p.flags = append(p.flags, argSpec{name: name, short: short, typ: typ, optional: true, help: help})
+	return p
+}
+
+// Arg declares a required positional parameter.
+func (p *ArgParser) Arg(name string, typ ArgType) *ArgParser {
+	if typ == ArgBool {
+		panic("ArgBool is only valid for flags")
+	}
+	p.positional = append(p.positional, argSpec{name: name, typ: typ})
+	return p
+}
+
+// OptionalArg declares a positional parameter that may be left out. Optional
+// parameters must come after required ones.
+func (p *ArgParser) OptionalArg(name string, typ ArgType) *ArgParser {
+	if typ == ArgBool {
+		panic("ArgBool is only valid for flags")
+	}
+	p.positional = append(p.positional, argSpec{name: name, typ: typ, optional: true})
+	return p
+}
+
+// Rest declares that the remaining arguments are collected as text.
+func (p *ArgParser) Rest(name string, required bool) *ArgParser {
+	p.rest = &argSpec{name: name, typ: ArgString, optional: !required}
+	return p
+}
+
+// Usage returns the generated usage text.
+func (p *ArgParser) Usage() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Usage: `@marvin %s", p.command)
+	for _, f := range p.flags {
+		if f.typ == ArgBool {
+			fmt.Fprintf(&buf, " [--%s]", f.name)
+		} else {
+			fmt.Fprintf(&buf, " [--%s <%s>]", f.name, f.typ.placeholder())
+		}
+	}
+	for _, a := range p.positional {
+		if a.optional {
+			fmt.Fprintf(&buf, " [%s]", a.name)
+		} else {
+			fmt.Fprintf(&buf, " <%s>", a.name)
+		}
+	}
+	if p.rest != nil {
+		if p.rest.optional {
+			fmt.Fprintf(&buf, " [%s...]", p.rest.name)
+		} else {
+			fmt.Fprintf(&buf, " <%s...>", p.rest.name)
+		}
+	}
+	buf.WriteString("`")
+	for _, f := range p.flags {
+		if f.short != "" {
+			fmt.Fprintf(&buf, "\n\t`--%s`, `-%s`: %s", f.name, f.short, f.help)
+		} else {
+			fmt.Fprintf(&buf, "\n\t`--%s`: %s", f.name, f.help)
+		}
+	}
+	return buf.String()
+}
+
+func (p *ArgParser) findFlag(arg string) (spec argSpec, value string, hasValue bool, ok bool) {
+	var name string
+	if strings.HasPrefix(arg, "--") {
+		name = arg[2:]
+	} else if len(arg) >= 2 && arg[0] == '-' {
+		name = arg[1:]
+	} else {
+		return argSpec{}, "", false, false
+	}
+	if idx := strings.IndexByte(name, '='); idx != -1 {
+		name, value, hasValue = name[:idx], name[idx+1:], true
+	}
+	for _, f := range p.flags {
+		if (strings.HasPrefix(arg, "--") && f.name == name) || (!strings.HasPrefix(arg, "--") && f.short != "" && f.short == name) {
+			return f, value, hasValue, true
+		}
+	}
+	return argSpec{}, "", false, false
+}
+
+// Parse parses the remaining arguments. If they don't match the declaration,
+// a usage result explaining the problem is returned instead.
+func (p *ArgParser) Parse(t Team, args *CommandArguments) (*ParsedArgs, *CommandResult) {
+	result := &ParsedArgs{values: make(map[string]interface{})}
+	fail := func(format string, v ...interface{}) (*ParsedArgs, *CommandResult) {
+		r := CmdUsage(args, fmt.Sprintf(format, v...)+"\n"+p.Usage()).WithSimpleUndo()
+		return nil, &r
+	}
+
+	var positional []string
+	flagsDone := false
+	inRest := func() bool { return p.rest != nil && len(positional) >= len(p.positional) }
+	for i := 0; i < len(args.Arguments); i++ {
+		arg := args.Arguments[i]
+		if flagsDone || inRest() {
+			positional = append(positional, arg)
+			continue
+		}
+		if arg == "--" {
+			flagsDone = true
+			continue
+		}
+		spec, value, hasValue, isFlag := p.findFlag(arg)
+		if !isFlag {
+			if strings.HasPrefix(arg, "--") {
+				return fail("Unknown flag `%s`.", arg)
+			}
+			positional = append(positional, arg)
+			continue
+		}
+		if spec.typ == ArgBool {
+			if hasValue {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return fail("`--%s` must be `true` or `false`.", spec.name)
+				}
+				result.values[spec.name] = b
+			} else {
+				result.values[spec.name] = true
+			}
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args.Arguments) {
+				return fail("`--%s` needs a %s.", spec.name, spec.typ.placeholder())
+			}
+			i++
+			value = args.Arguments[i]
+		}
+		v, err := convertArg(t, spec.typ, value)
+		if err != nil {
+			return fail("`--%s`: %v", spec.name, err)
+		}
+		result.values[spec.name] = v
+	}
+
+	for i, spec := range p.positional {
+		if i >= len(positional) {
+			if spec.optional {
+				break
+			}
+			return fail("Missing `%s`.", spec.name)
+		}
+		v, err := convertArg(t, spec.typ, positional[i])
+		if err != nil {
+			return fail("`%s`: %v", spec.name, err)
+		}
+		result.values[spec.name] = v
+	}
+	if len(positional) > len(p.positional) {
+		if p.rest == nil {
+			return fail("Too many arguments.")
+		}
+		result.rest = positional[len(p.positional):]
+	} else if p.rest != nil && !p.rest.optional {
+		return fail("Missing `%s`.", p.rest.name)
+	}
+	return result, nil
+}
+
+// https://example.slack.com/archives/general/p1485982126006451
+var rgxArgArchiveLink = regexp.MustCompile(`^<?https://[^./]+\.slack\.com/archives/([^/]+)/p([0-9]+)`)
+
+func convertArg(t Team, typ ArgType, arg string) (interface{}, error) {
+	switch typ {
+	case ArgInt:
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", arg)
+		}
+		return n, nil
+	case ArgDuration:
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a duration, like `10m` or `1h30m`", arg)
+		}
+		return d, nil
+	case ArgUser:
+		u := t.ResolveUserName(arg)
+		if u == "" {
+			return nil, fmt.Errorf("no such user '%s'", arg)
+		}
+		return u, nil
+	case ArgChannel:
+		c := t.ResolveChannelName(arg)
+		if c == "" {
+			return nil, fmt.Errorf("no such channel '%s'", arg)
+		}
+		return c, nil
+	case ArgArchiveLink:
+		m := rgxArgArchiveLink.FindStringSubmatch(arg)
+		if m == nil {
+			return nil, fmt.Errorf("'%s' is not a message link", arg)
+		}
+		channel := slack.ParseChannelID(m[1])
+		if channel == "" {
+			channel = t.ChannelIDByName(m[1])
+		}
+		if channel == "" || len(m[2]) <= slack.MessageTSCharsAfterDot {
+			return nil, fmt.Errorf("could not find the channel in '%s'", arg)
+		}
+		ts := m[2][:len(m[2])-slack.MessageTSCharsAfterDot] + "." + m[2][len(m[2])-slack.MessageTSCharsAfterDot:]
+		return slack.MsgID(channel, slack.MessageTS(ts)), nil
+	}
+	return arg, nil
+}
+
+// ParsedArgs holds the result of ArgParser.Parse. The accessors return the
+// zero value for flags and optional parameters that were not given.
+type ParsedArgs struct {
+	values map[string]interface{}
+	rest   []string
+}
+
+// Has reports whether the flag or parameter was given.
+func (pa *ParsedArgs) Has(name string) bool {
+	_, ok := pa.values[name]
+	return ok
+}
+
+func (pa *ParsedArgs) String(name string) string {
+	v, _ := pa.values[name].(string)
+	return v
+}
+
+func (pa *ParsedArgs) Int(name string) int64 {
+	v, _ := pa.values[name].(int64)
+	return v
+}
+
+func (pa *ParsedArgs) Bool(name string) bool {
+	v, _ := pa.values[name].(bool)
+	return v
+}
+
+func (pa *ParsedArgs) Duration(name string) time.Duration {
+	v, _ := pa.values[name].(time.Duration)
+	return v
+}
+
+func (pa *ParsedArgs) User(name string) slack.UserID {
+	v, _ := pa.values[name].(slack.UserID)
+	return v
+}
+
+func (pa *ParsedArgs) Channel(name string) slack.ChannelID {
+	v, _ := pa.values[name].(slack.ChannelID)
+	return v
+}
+
+func (pa *ParsedArgs) MessageID(name string) slack.MessageID {
+	v, _ := pa.values[name].(slack.MessageID)
+	return v
+}
+
+// Rest returns the arguments collected by the Rest parameter.
+func (pa *ParsedArgs) Rest() []string {
+	return pa.rest
+}
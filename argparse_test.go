@@ -0,0 +1,40 @@
+package marvin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArgParser(t *testing.T) {
+	parser := NewArgParser("test").
+		Flag("local", ".", ArgBool, "local").
+		Flag("wait", "", ArgDuration, "wait").
+		Arg("name", ArgString).
+		Rest("value", false)
+
+	p, fail := parser.Parse(nil, &CommandArguments{Arguments: []string{"-.", "--wait=5m", "foo", "a", "--local"}})
+	if fail != nil {
+		t.Fatal(fail.Message)
+	}
+	if !p.Bool("local") || p.Duration("wait") != 5*time.Minute || p.String("name") != "foo" {
+		t.Errorf("wrong values: %v", p.values)
+	}
+	// flags are not parsed in the Rest parameter
+	if rest := p.Rest(); len(rest) != 2 || rest[1] != "--local" {
+		t.Errorf("wrong rest: %v", rest)
+	}
+
+	_, fail = parser.Parse(nil, &CommandArguments{Arguments: []string{"--wait"}})
+	if fail == nil {
+		t.Error("expected failure for flag without value")
+	}
+	_, fail = parser.Parse(nil, &CommandArguments{Arguments: []string{"--bogus", "x"}})
+	if fail == nil {
+		t.Error("expected failure for unknown flag")
+	}
+
+	expect := "Usage: `@marvin test [--local] [--wait <duration>] <name> [value...]`\n\t`--local`, `-.`: local\n\t`--wait`: wait"
+	if u := parser.Usage(); u != expect {
+		t.Errorf("wrong usage:\n%s\n%s", u, expect)
+	}
+}
@@ -0,0 +1,53 @@
+package marvin
+
+import (
+	"context"
+	"time"
+
+	"github.com/riking/marvin/slack"
+)
+
+// AsyncCommandTimeout is how long an asynchronous command may run.
+const AsyncCommandTimeout = 1 * time.Hour
+
+// ProgressFunc reports the progress of an asynchronous command. The message
+// replaces the command's placeholder reply.
+type ProgressFunc func(msg string)
+
+// AsyncFunc is the long-running part of a command. The context is cancelled
+// when the user cancels the command, and the returned result replaces the
+// placeholder reply.
+type AsyncFunc func(ctx context.Context, progress ProgressFunc) CommandResult
+
+// CmdAsync creates a CmdResultInProgress result, for commands that take
+// longer than a reply should. msg is shown until f reports progress or
+// finishes.
+//
+// Commands should do their argument checking before returning CmdAsync, so
+// that usage errors are reported right away. f must use its own context,
+// as args.Ctx ends when the command returns.
+func CmdAsync(args *CommandArguments, msg string, f AsyncFunc) CommandResult {
+	return CommandResult{Args: args, Message: msg, Code: CmdResultInProgress, Async: f}
+}
+
+// RunningCommand describes an asynchronous command that has not finished.
+type RunningCommand struct {
+	ID      int64
+	User    slack.UserID
+	Channel slack.ChannelID
+	// Command is the command line, as given.
+	Command  string
+	Started  time.Time
+	Progress string
+}
+
+// WaitCommand finishes an in-progress result through Team.StartCommand and
+// returns its final result. It is for callers that cannot show progress,
+// such as pipelines and slash commands. Other results are returned as-is.
+func WaitCommand(t Team, result CommandResult) CommandResult {
+	if result.Code != CmdResultInProgress {
+		return result
+	}
+	_, done := t.StartCommand(result, nil)
+	return <-done
+}
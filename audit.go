@@ -0,0 +1,47 @@
+package marvin
+
+import (
+	"time"
+
+	"github.com/riking/marvin/slack"
+)
+
+// PermAuditView allows reading the audit log, through `@marvin audit` or the
+// web viewer.
+var PermAuditView = Permission{Name: "audit.view", Level: AccessLevelAdmin}
+
+// AuditRedacted replaces Before and After values that must not be stored,
+// such as protected configuration values.
+const AuditRedacted = "(redacted)"
+
+// AuditEntry records a privileged action. Modules fill in Module, Action,
+// Target, Before and After; Team.Audit fills in the rest from the source.
+type AuditEntry struct {
+	ID   int64
+	Time time.Time
+
+	Actor   slack.UserID
+	Channel slack.ChannelID
+	MsgTS   slack.MessageTS
+
+	// Module is the module whose state was changed.
+	Module ModuleID
+	// Conventionally the command path, e.g. "config.set".
+	Action string
+	// What was acted on, e.g. "factoid.rules" or a channel ID.
+	Target string
+	// Before and After are the old and new values, if the action changed one.
+	Before string
+	After  string
+}
+
+// AuditFilter selects entries from the audit log. Zero fields match anything.
+type AuditFilter struct {
+	Actor  slack.UserID
+	Module ModuleID
+	Action string
+	// BeforeID returns only entries older than the given entry, for paging.
+	BeforeID int64
+	// Limit defaults to 50.
+	Limit int
+}
@@ -95,15 +95,15 @@ func messagePrinter(team marvin.Team, dumpMessages bool) func(msg slack.RTMRawMe
 	}
 }
 
-func readyTeam(cfg *ini.File, name string) (marvin.Team, *rtm.Client, error) {
+func readyTeam(cfg *ini.File, name string) (*controller.Team, error) {
 	teamConfig := marvin.LoadTeamConfig(cfg.Section(name))
 	team, err := controller.NewTeam(teamConfig)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "NewTeam")
+		return nil, errors.Wrap(err, "NewTeam")
 	}
 	l, err := net.Listen("tcp4", teamConfig.HTTPListen)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "listen tcp")
+		return nil, errors.Wrap(err, "listen tcp")
 	}
 	client := rtm.NewClient(team)
 	client.RegisterRawHandler("main.go", messagePrinter(team, false),
@@ -111,11 +111,11 @@ func readyTeam(cfg *ini.File, name string) (marvin.Team, *rtm.Client, error) {
 
 	team.ConnectRTM(client)
 	if !team.EnableModules() {
-		return nil, nil, errors.Errorf("Some modules failed to load, exiting")
+		return nil, errors.Errorf("Some modules failed to load, exiting")
 	}
 	team.ConnectHTTP(l)
 
-	return team, client, nil
+	return team, nil
 }
 
 func main() {
@@ -137,18 +137,21 @@ func main() {
 	}
 
 	teamNames := strings.Split(*teamNamesStr, ",")
-	teams := make([]marvin.Team, len(teamNames))
-	rtmClients := make([]*rtm.Client, len(teamNames))
+	teams := make([]*controller.Team, len(teamNames))
 	for i, name := range teamNames {
-		teams[i], rtmClients[i], err = readyTeam(cfg, name)
+		teams[i], err = readyTeam(cfg, name)
 		if err != nil {
 			util.LogError(err)
 			os.Exit(9)
 		}
 	}
 
-	for _, v := range rtmClients {
-		go v.Start()
+	for _, v := range teams {
+		err = v.StartEvents()
+		if err != nil {
+			util.LogError(errors.Wrapf(err, "[%s] start events", v.Domain()))
+			os.Exit(9)
+		}
 	}
 
 	signalCh := make(chan os.Signal)
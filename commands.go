@@ -19,8 +19,12 @@ type CommandArguments struct {
 
 	IsEdit         bool
 	IsUndo         bool
+	Confirmed      bool // the user agreed to a CmdConfirm prompt
 	PreviousResult *CommandResult
 	ModuleData     interface{}
+
+	// number of aliases expanded so far, to stop cycles
+	aliasDepth int
 }
 
 // Pop moves the first element of Arguments to Command and returns the new
@@ -50,8 +54,36 @@ const (
 	CmdResultNoSuchCommand
 	CmdResultPrintUsage
 	CmdResultPrintHelp
+	// CmdResultInProgress is returned by CmdAsync. The command keeps running
+	// after the result is returned.
+	CmdResultInProgress
+	// CmdResultNeedsConfirm is returned by CmdConfirm. The command has not
+	// done anything yet.
+	CmdResultNeedsConfirm
 )
 
+func (c CommandResultCode) String() string {
+	switch c {
+	case CmdResultOK:
+		return "ok"
+	case CmdResultFailure:
+		return "failure"
+	case CmdResultError:
+		return "error"
+	case CmdResultNoSuchCommand:
+		return "no_such_command"
+	case CmdResultPrintUsage:
+		return "usage"
+	case CmdResultPrintHelp:
+		return "help"
+	case CmdResultInProgress:
+		return "in_progress"
+	case CmdResultNeedsConfirm:
+		return "needs_confirm"
+	}
+	return "unknown"
+}
+
 const UndoSimple = 2
 const UndoCustom = util.TriYes
 
@@ -69,8 +101,26 @@ type CommandResult struct {
 	ReplyType ReplyType
 	Sent      bool
 
+	// Suggestions are full command lines the user may have meant, for
+	// CmdResultNoSuchCommand results.
+	Suggestions []string
+
 	CanEdit util.TriValue
 	CanUndo util.TriValue
+
+	// Async is the rest of the work for CmdResultInProgress results.
+	Async AsyncFunc
+}
+
+// CmdConfirm asks the user to confirm a destructive command before it runs.
+// msg says what the command will do. Once the user agrees, the command is
+// run again with the same arguments and Confirmed set:
+//
+//	if !args.Confirmed {
+//		return marvin.CmdConfirm(args, "This will forget `lunch`.")
+//	}
+func CmdConfirm(args *CommandArguments, msg string) CommandResult {
+	return CommandResult{Args: args, Message: msg, Code: CmdResultNeedsConfirm}
 }
 
 // CmdError includes the Err field for the CmdResultError code.
@@ -99,6 +149,12 @@ func CmdUsage(args *CommandArguments, usage string) CommandResult {
 	return CommandResult{Args: args, Message: usage, Code: CmdResultPrintUsage}
 }
 
+// WithSuggestions attaches "did you mean" suggestions to the result.
+func (r CommandResult) WithSuggestions(suggestions []string) CommandResult {
+	r.Suggestions = suggestions
+	return r
+}
+
 func (r CommandResult) WithEdit() CommandResult {
 	r.CanEdit = util.TriYes
 	return r
@@ -124,6 +180,41 @@ func (r CommandResult) WithNoUndo() CommandResult {
 	return r
 }
 
+// Editable reports whether editing the message that ran the command should
+// run it again. Unless the command says otherwise, only commands that did
+// nothing, like failures and usage messages, can be edited.
+func (r CommandResult) Editable() bool {
+	switch r.CanEdit {
+	case util.TriYes:
+		return true
+	case util.TriNo:
+		return false
+	}
+	switch r.Code {
+	case CmdResultFailure, CmdResultNoSuchCommand, CmdResultPrintUsage, CmdResultPrintHelp:
+		return true
+	}
+	return false
+}
+
+// Undoable reports whether the command can be undone, and if so whether it
+// needs a custom undo or only the removal of its replies.
+func (r CommandResult) Undoable() (canUndo, custom bool) {
+	switch r.CanUndo {
+	case UndoCustom:
+		return true, true
+	case UndoSimple:
+		return true, false
+	case util.TriNo:
+		return false, false
+	}
+	switch r.Code {
+	case CmdResultFailure, CmdResultNoSuchCommand, CmdResultPrintUsage, CmdResultPrintHelp:
+		return true, false
+	}
+	return false, false
+}
+
 // WithReplyType explicitly sets where the response should be directed.
 //
 // The caller can override this if desired, but it will be respected for all
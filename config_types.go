@@ -0,0 +1,132 @@
+package marvin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigType is the type of a module configuration value.  Values are always
+// stored as strings; the type controls what strings are accepted.
+type ConfigType int
+
+const (
+	ConfTypeString ConfigType = iota
+	ConfTypeDuration
+	ConfTypeInt
+	ConfTypeBool
+	ConfTypeChannel
+	ConfTypeUser
+	ConfTypeEmoji
+	ConfTypeRegexp
+	ConfTypeEnum
+)
+
+func (ct ConfigType) String() string {
+	switch ct {
+	case ConfTypeString:
+		return "string"
+	case ConfTypeDuration:
+		return "duration"
+	case ConfTypeInt:
+		return "int"
+	case ConfTypeBool:
+		return "bool"
+	case ConfTypeChannel:
+		return "channel"
+	case ConfTypeUser:
+		return "user"
+	case ConfTypeEmoji:
+		return "emoji"
+	case ConfTypeRegexp:
+		return "regexp"
+	case ConfTypeEnum:
+		return "enum"
+	}
+	return fmt.Sprintf("ConfigType(%d)", int(ct))
+}
+
+// ConfigSchema describes a module configuration key.  It is passed to
+// ModuleConfig.AddTyped.
+type ConfigSchema struct {
+	Type        ConfigType
+	Description string
+	// Protected keys may only be viewed by admins over DMs.
+	Protected bool
+	// Choices lists the accepted values for ConfTypeEnum.
+	Choices []string
+	// Validator is an optional extra check, run after the type check.  The
+	// returned error is shown to the user.
+	Validator func(value string) error
+}
+
+var (
+	rgxConfChannel = regexp.MustCompile(`^[CGD][A-Z0-9]+$`)
+	rgxConfUser    = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+	rgxConfEmoji   = regexp.MustCompile(`^[a-z0-9_+'-]+(::skin-tone-[2-6])?$`)
+)
+
+// Validate checks that the value is acceptable for the schema.  The empty
+// string is always accepted for channel, user and regexp keys, and means
+// "not set".
+func (s ConfigSchema) Validate(value string) error {
+	switch s.Type {
+	case ConfTypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("must be a duration, like `10s` or `1h30m`")
+		}
+	case ConfTypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+	case ConfTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be `true` or `false`")
+		}
+	case ConfTypeChannel:
+		if value != "" && !rgxConfChannel.MatchString(value) {
+			return fmt.Errorf("must be a channel, like `#general`")
+		}
+	case ConfTypeUser:
+		if value != "" && !rgxConfUser.MatchString(value) {
+			return fmt.Errorf("must be a user, like `@marvin`")
+		}
+	case ConfTypeEmoji:
+		if !rgxConfEmoji.MatchString(value) {
+			return fmt.Errorf("must be an emoji name, like `white_check_mark`")
+		}
+	case ConfTypeRegexp:
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("must be a valid regular expression: %v", err)
+		}
+	case ConfTypeEnum:
+		found := false
+		for _, v := range s.Choices {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of: `%s`", strings.Join(s.Choices, "`, `"))
+		}
+	}
+	if s.Validator != nil {
+		return s.Validator(value)
+	}
+	return nil
+}
+
+// ErrConfInvalid is an error return from ModuleConfig.Set when the value does
+// not pass validation.
+type ErrConfInvalid struct {
+	Key    string
+	Reason error
+}
+
+// Error implements the error interface.
+func (e ErrConfInvalid) Error() string {
+	return fmt.Sprintf("%s %v", e.Key, e.Reason)
+}
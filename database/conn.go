@@ -9,6 +9,9 @@ import (
 
 type Conn struct {
 	*sql.DB
+
+	// needed for Listen, which opens its own connection
+	connect string
 }
 
 // Dial constructs a database connection for Marvin.
@@ -18,7 +21,8 @@ func Dial(connect string) (*Conn, error) {
 		return nil, errors.Wrap(err, "failed to connect")
 	}
 	c := &Conn{
-		DB: db,
+		DB:      db,
+		connect: connect,
 	}
 	err = c.setupMigrate()
 	if err != nil {
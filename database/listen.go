@@ -0,0 +1,72 @@
+package database
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin/util"
+)
+
+// Listener receives NOTIFY payloads on a dedicated connection.
+type Listener struct {
+	l    *pq.Listener
+	stop chan struct{}
+}
+
+// Listen opens a dedicated connection and runs LISTEN on the channel.
+//
+// onNotify is called, on a single goroutine, with the payload of every
+// notification. Notifications sent while the connection was down are lost, so
+// onReconnect is called after the connection is re-established.
+func (c *Conn) Listen(channel string, onNotify func(payload string), onReconnect func()) (*Listener, error) {
+	l := pq.NewListener(c.connect, 1*time.Second, 1*time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			util.LogBad("database listener:", err)
+		}
+	})
+	err := l.Listen(channel)
+	if err != nil {
+		l.Close()
+		return nil, errors.Wrapf(err, "listen %s", channel)
+	}
+
+	listener := &Listener{l: l, stop: make(chan struct{})}
+	go listener.run(onNotify, onReconnect)
+	return listener, nil
+}
+
+func (l *Listener) run(onNotify func(payload string), onReconnect func()) {
+	for {
+		select {
+		case <-l.stop:
+			return
+		case n, ok := <-l.l.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// pq sends nil after reconnecting
+				onReconnect()
+				continue
+			}
+			onNotify(n.Extra)
+		case <-time.After(90 * time.Second):
+			go l.l.Ping()
+		}
+	}
+}
+
+// Close stops listening and closes the connection.
+func (l *Listener) Close() error {
+	close(l.stop)
+	return l.l.Close()
+}
+
+// Notify sends a notification with the payload to all listeners on the
+// channel.
+func (c *Conn) Notify(channel, payload string) error {
+	_, err := c.Exec(`SELECT pg_notify($1, $2)`, channel, payload)
+	return errors.Wrapf(err, "notify %s", channel)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -27,6 +28,28 @@ const (
 	ModuleStateErrorEnabling
 )
 
+func (s ModuleState) String() string {
+	switch s {
+	case ModuleStateConstructed:
+		return "constructed"
+	case ModuleStateLoaded:
+		return "loaded"
+	case ModuleStateEnabled:
+		return "enabled"
+	case ModuleStateDisabled:
+		return "disabled"
+	case ModuleStateErrorLoading:
+		return "error loading"
+	case ModuleStateErrorEnabling:
+		return "error enabling"
+	}
+	return fmt.Sprintf("ModuleState(%d)", int(s))
+}
+
+// ConfTurnOffModule is the value of a key in the "modules" config that keeps
+// the module with that identifier disabled across restarts.
+const ConfTurnOffModule = "off"
+
 type Module interface {
 	// Modules should declare a constant named 'Identifier' in their package
 	// and return it from this function.
@@ -41,6 +64,7 @@ type Module interface {
 }
 
 type ModuleStatus interface {
+	Identifier() ModuleID
 	Instance() Module
 	State() ModuleState
 	// Returns non-nil if Degraded returns true.
@@ -49,6 +73,8 @@ type ModuleStatus interface {
 	IsLoaded() bool
 	IsEnabled() bool
 	Degraded() bool
+	// DependsOn lists the modules this module requested with DependModule.
+	DependsOn() []ModuleID
 }
 
 type ModuleConfig interface {
@@ -75,6 +101,19 @@ type ModuleConfig interface {
 	Set(key, value string) error
 	// SetDefault resets the configuration for the given key to the default.
 	SetDefault(key string) error
+	// GetForChannel gets a module configuration value for use in a specific
+	// channel.  A channel override is preferred, then the team-wide value,
+	// then the default.  An empty channel acts like Get().
+	GetForChannel(key string, channel slack.ChannelID) (string, error)
+	// SetForChannel sets an override that only applies in the given channel.
+	SetForChannel(key string, channel slack.ChannelID, value string) error
+	// SetDefaultForChannel removes the override for the given channel.
+	SetDefaultForChannel(key string, channel slack.ChannelID) error
+	// ListChannelOverrides returns all channel overrides for the key.
+	ListChannelOverrides(key string) (map[slack.ChannelID]string, error)
+	// ListOverrides returns every key that has a team-wide override,
+	// including keys with no default.
+	ListOverrides() (map[string]string, error)
 	// Add initializes the default value for a key for use with Get().  This
 	// must be called during the module Load phase.
 	Add(key, defaultValue string)
@@ -82,15 +121,28 @@ type ModuleConfig interface {
 	// sets the key as protected.  This must be called during the module Load
 	// phase.
 	AddProtect(key, defaultValue string, protect bool)
+	// AddTyped initializes the default value for a key, and declares its type,
+	// description, and validation.  This must be called during the module
+	// Load phase.
+	AddTyped(key, defaultValue string, schema ConfigSchema)
 	// OnModify registers a callback for when a key is modified.
 	OnModify(f func(key string))
 
+	// Validate checks a value against the key's schema.  The error is an
+	// ErrConfInvalid.  Keys added without a schema accept any value.
+	// Set() and SetForChannel() call Validate before writing.
+	Validate(key, value string) error
+
 	// ListDefaults returns the defaults map.  This cannot be called during the
 	// module Load phase.
 	ListDefaults() map[string]string
 	// ListDefaults returns the protected-keys map.  This cannot be called
 	// during the module Load phase.
 	ListProtected() map[string]bool
+	// ListSchemas returns the schema of every key with a default.  Keys added
+	// without a schema have a ConfTypeString schema.  This cannot be called
+	// during the module Load phase.
+	ListSchemas() map[string]ConfigSchema
 }
 
 // ErrConfProtected is an error return from
@@ -143,6 +195,12 @@ type SubCommandFunc func(t Team, args *CommandArguments) CommandResult
 type CommandRegistration interface {
 	RegisterCommand(name string, c SubCommand)
 	RegisterCommandFunc(name string, c SubCommandFunc, help string) SubCommand
+	RegisterCommandPerm(name string, c SubCommand, perm Permission)
+	RegisterCommandFuncPerm(name string, c SubCommandFunc, help string, perm Permission) SubCommand
+	// RegisterAlias makes name expand to another command. See
+	// ParentCommand.RegisterAlias.
+	RegisterAlias(name string, expansion string) error
+	UnregisterAlias(name string)
 	UnregisterCommand(name string)
 }
 
@@ -200,6 +258,13 @@ type Team interface {
 	GetAllModules() []ModuleStatus
 	// GetAllModules() returns the status of all enabled modules.
 	GetAllEnabledModules() []ModuleStatus
+	// EnableModule enables a loaded or disabled module. All of its
+	// dependencies must already be enabled.
+	EnableModule(modID ModuleID) error
+	// DisableModule disables a module, after first disabling every enabled
+	// module that depends on it. The dependents that were disabled are
+	// returned.
+	DisableModule(modID ModuleID) ([]ModuleID, error)
 
 	SendMessage
 	ReactMessage(msgID slack.MessageID, emojiName string) error
@@ -208,6 +273,9 @@ type Team interface {
 	// the token parameter is already defined, the existing value is used.
 	SlackAPIPostRaw(method string, form url.Values) (*http.Response, error)
 	SlackAPIPostJSON(method string, form url.Values, result interface{}) error
+	// SlackAPIQueueStats returns a snapshot of the queue that Web API calls
+	// wait in to stay under Slack's rate limits.
+	SlackAPIQueueStats() slack.APIQueueStats
 
 	ArchiveURL(msgID slack.MessageID) string
 
@@ -219,7 +287,61 @@ type Team interface {
 	GetRTMClient() interface{}
 
 	CommandRegistration
+	// DispatchCommand runs a command, after checking the permissions it
+	// requires. Arguments containing PipeSeparator are run as a pipeline.
 	DispatchCommand(args *CommandArguments) CommandResult
+	// StartCommand runs the Async part of a CmdResultInProgress result in
+	// the background, calling progress (which may be nil) as it reports.
+	// The final result is sent on the returned channel.
+	StartCommand(result CommandResult, progress ProgressFunc) (id int64, done <-chan CommandResult)
+	// CancelCommand cancels a running asynchronous command. It returns false
+	// if the command is not running.
+	CancelCommand(id int64) bool
+	// RunningCommands lists the asynchronous commands that have not
+	// finished.
+	RunningCommands() []RunningCommand
+
+	// CheckPermission reports whether the source may use the permission,
+	// through its access level or through a role.
+	CheckPermission(source ActionSource, perm Permission) bool
+	// ListPermissions returns every permission declared by registered
+	// commands.
+	ListPermissions() []Permission
+	// GrantRole gives the user a role. An empty channel grants it everywhere.
+	GrantRole(role string, user slack.UserID, channel slack.ChannelID) error
+	// RevokeRole removes a grant made with GrantRole.
+	RevokeRole(role string, user slack.UserID, channel slack.ChannelID) error
+	// SetRolePermission adds or removes a permission from a role.
+	SetRolePermission(role string, permission string, granted bool) error
+	// ListRoles maps every role to its permissions.
+	ListRoles() (map[string][]string, error)
+	// ListRoleGrants lists the roles given to a user.
+	ListRoleGrants(user slack.UserID) ([]RoleGrant, error)
+
+	// Audit records a privileged action taken by the source. Errors are
+	// logged, not returned, so that a failed write never blocks the action.
+	Audit(source ActionSource, entry AuditEntry)
+	// ListAudit returns audit log entries matching the filter, newest first.
+	ListAudit(filter AuditFilter) ([]AuditEntry, error)
+
+	// RegisterJobHandler sets the function that runs the module's jobs with
+	// the given name. Jobs only run while their module is enabled.
+	RegisterJobHandler(mod ModuleID, name string, h JobHandler)
+	// ScheduleJob saves a job to run once at runAt. The payload is stored as
+	// JSON, and may be nil.
+	ScheduleJob(mod ModuleID, name string, runAt time.Time, payload interface{}) (int64, error)
+	// ScheduleRecurringJob saves a job that runs on the schedule, which is
+	// parsed by ParseSchedule. A module has at most one recurring job with a
+	// name; calling this again replaces its schedule and payload.
+	//
+	// Saved jobs run on only one of the processes sharing the database, so
+	// work on a process's own memory needs a ticker instead.
+	ScheduleRecurringJob(mod ModuleID, name string, schedule string, payload interface{}) error
+	// CancelJob deletes a saved job.
+	CancelJob(id int64) error
+	// ListJobs returns saved jobs, soonest first. An empty module lists the
+	// jobs of every module.
+	ListJobs(mod ModuleID) ([]Job, error)
 
 	// Add a new HTTP route handler.
 	HandleHTTP(path string, handler http.Handler) *mux.Route
@@ -0,0 +1,42 @@
+package marvin
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobMaxAttempts is how many times a job is tried before it is given up on.
+// A failed one-shot job is kept, marked Failed, so that it shows up in
+// `@marvin jobs`; a failed recurring job waits for its next run.
+const JobMaxAttempts = 8
+
+// A JobHandler runs one job. Jobs are run at least once: a job that was
+// running when Marvin stopped runs again after a restart, so handlers should
+// be safe to repeat. Returning an error retries the job with backoff.
+type JobHandler func(t Team, job *Job) error
+
+// Job is a unit of work saved by Team.ScheduleJob or
+// Team.ScheduleRecurringJob.
+type Job struct {
+	ID     int64
+	Module ModuleID
+	// Name selects the handler registered with Team.RegisterJobHandler.
+	Name string
+	// Payload is the JSON encoding of the value given when scheduling.
+	Payload string
+	// Schedule is empty for one-shot jobs.
+	Schedule string
+	RunAt    time.Time
+
+	// Attempts counts tries since the job last succeeded, including the
+	// current one.
+	Attempts  int
+	LastError string
+	Failed    bool
+	Running   bool
+}
+
+// Decode unmarshals the payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
@@ -17,6 +17,10 @@ type G struct {
 	Ctx      context.Context
 	PrintBuf bytes.Buffer
 	actS     marvin.ActionSource
+
+	// RequestLimit, if set, is called before each HTTP request the script
+	// makes. Returning false refuses the request.
+	RequestLimit func() bool
 }
 
 func NewLua(ctx context.Context, team marvin.Team, actionSource marvin.ActionSource) *G {
@@ -72,7 +76,7 @@ func (g *G) OpenLibraries() {
 	OpenFuncs(L)
 	OpenJson(L)
 	OpenIntra(g, L)
-	OpenRequests(L)
+	OpenRequests(g, L)
 	OpenTime(L)
 
 	(*LUser)(nil).SetupMetatable(L)
@@ -10,8 +10,9 @@ import (
 	"github.com/yuin/gopher-lua"
 )
 
-func OpenRequests(L *lua.LState) int {
+func OpenRequests(g *G, L *lua.LState) int {
 	mod := L.RegisterModule("requests", requestsFuncs).(*lua.LTable)
+	mod.RawSetString("request", L.NewFunction(g.luaRequestLimited))
 
 	L.Push(mod)
 	return 1
@@ -27,8 +28,6 @@ var requestsFuncs = map[string]lua.LGFunction{
 	"put":     requestsHelperBody(http.MethodPut),
 	"options": requestsHelperBody(http.MethodOptions),
 	"patch":   requestsHelperBody(http.MethodPatch),
-
-	"request": luaRequest,
 }
 
 func requestsHelperNoBody(method string) func(L *lua.LState) int {
@@ -75,6 +74,16 @@ func luaToStringArray(L *lua.LState, lv lua.LValue) []string {
 	}
 }
 
+// luaRequestLimited asks g.RequestLimit before each request.
+func (g *G) luaRequestLimited(L *lua.LState) int {
+	if g.RequestLimit != nil && !g.RequestLimit() {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("too many requests, slow down"))
+		return 2
+	}
+	return luaRequest(L)
+}
+
 func luaRequest(L *lua.LState) int {
 	pUrl, pHeaders, pData, pMethod, pOptions := L.Get(1), L.Get(2), L.Get(3), L.Get(4), L.Get(5)
 	var urlStr string
@@ -0,0 +1,329 @@
+// Package metrics keeps counters, gauges and histograms for the bot and
+// serves them in the Prometheus text exposition format.
+//
+// Metrics are registered with the package-level registry when they are
+// created, so they are normally declared as package variables:
+//
+//	var metricCommands = metrics.NewCounterVec("marvin_commands_total",
+//		"Commands run, by command and result.", "command", "result")
+//
+//	metricCommands.With("factoid", "ok").Inc()
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type collector interface {
+	name() string
+	write(buf *bytes.Buffer)
+}
+
+var (
+	registryLock sync.Mutex
+	registry     []collector
+)
+
+func register(c collector) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, v := range registry {
+		if v.name() == c.name() {
+			panic(fmt.Sprintf("metrics: %s registered twice", c.name()))
+		}
+	}
+	registry = append(registry, c)
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryLock.Lock()
+		collectors := make([]collector, len(registry))
+		copy(collectors, registry)
+		registryLock.Unlock()
+		sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+		var buf bytes.Buffer
+		for _, c := range collectors {
+			c.write(&buf)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	})
+}
+
+// ---
+
+// vec holds one value per combination of label values.
+type vec struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+
+	lock   sync.Mutex
+	values map[string]interface{}
+}
+
+func (v *vec) name() string { return v.metricName }
+
+func (v *vec) get(labelValues []string, mk func() interface{}) interface{} {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	m, ok := v.values[key]
+	if !ok {
+		m = mk()
+		v.values[key] = m
+	}
+	return m
+}
+
+func (v *vec) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", v.metricName, escapeHelp(v.help), v.metricName, v.typ)
+}
+
+// each calls f for every label combination, in order.
+func (v *vec) each(f func(labelValues []string, m interface{})) {
+	v.lock.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ms := make([]interface{}, len(keys))
+	for i, k := range keys {
+		ms[i] = v.values[k]
+	}
+	v.lock.Unlock()
+
+	for i, k := range keys {
+		var values []string
+		if len(v.labels) > 0 {
+			values = strings.Split(k, "\xff")
+		}
+		f(values, ms[i])
+	}
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var parts []string
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", n, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escapeLabel drops characters %q would escape differently than Prometheus.
+func escapeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+func escapeHelp(s string) string {
+	return strings.Replace(strings.Replace(s, `\`, `\\`, -1), "\n", `\n`, -1)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// ---
+
+// A Counter only goes up.
+type Counter struct {
+	lock  sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() { c.Add(1) }
+
+func (c *Counter) Add(n float64) {
+	c.lock.Lock()
+	c.value += n
+	c.lock.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.value
+}
+
+type CounterVec struct{ vec }
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec{metricName: name, help: help, typ: "counter", labels: labels, values: make(map[string]interface{})}}
+	register(c)
+	return c
+}
+
+// NewCounter makes a counter without labels.
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).With()
+}
+
+func (c *CounterVec) With(labelValues ...string) *Counter {
+	return c.get(labelValues, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.writeHeader(buf)
+	c.each(func(labelValues []string, m interface{}) {
+		fmt.Fprintf(buf, "%s%s %s\n", c.metricName, formatLabels(c.labels, labelValues), formatFloat(m.(*Counter).get()))
+	})
+}
+
+// ---
+
+// A Gauge is a value that can go up and down.
+type Gauge struct {
+	lock  sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(n float64) {
+	g.lock.Lock()
+	g.value = n
+	g.lock.Unlock()
+}
+
+func (g *Gauge) Add(n float64) {
+	g.lock.Lock()
+	g.value += n
+	g.lock.Unlock()
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) get() float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.value
+}
+
+type GaugeVec struct{ vec }
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vec{metricName: name, help: help, typ: "gauge", labels: labels, values: make(map[string]interface{})}}
+	register(g)
+	return g
+}
+
+// NewGauge makes a gauge without labels.
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).With()
+}
+
+func (g *GaugeVec) With(labelValues ...string) *Gauge {
+	return g.get(labelValues, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+func (g *GaugeVec) write(buf *bytes.Buffer) {
+	g.writeHeader(buf)
+	g.each(func(labelValues []string, m interface{}) {
+		fmt.Fprintf(buf, "%s%s %s\n", g.metricName, formatLabels(g.labels, labelValues), formatFloat(m.(*Gauge).get()))
+	})
+}
+
+// ---
+
+// DefBuckets are histogram buckets, in seconds, for typical request times.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// A Histogram counts observations into buckets.
+type Histogram struct {
+	lock    sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince records the time since start, in seconds.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		vec:     vec{metricName: name, help: help, typ: "histogram", labels: labels, values: make(map[string]interface{})},
+		buckets: buckets,
+	}
+	register(h)
+	return h
+}
+
+// NewHistogram makes a histogram without labels.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return NewHistogramVec(name, help, buckets).With()
+}
+
+func (h *HistogramVec) With(labelValues ...string) *Histogram {
+	return h.get(labelValues, func() interface{} {
+		return &Histogram{buckets: h.buckets, counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.writeHeader(buf)
+	h.each(func(labelValues []string, m interface{}) {
+		hist := m.(*Histogram)
+		hist.lock.Lock()
+		defer hist.lock.Unlock()
+		for i, upper := range hist.buckets {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", h.metricName,
+				formatLabels(h.labels, labelValues, "le", formatFloat(upper)), hist.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, labelValues, "le", "+Inf"), hist.count)
+		labels := formatLabels(h.labels, labelValues)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", h.metricName, labels, formatFloat(hist.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", h.metricName, labels, hist.count)
+	})
+}
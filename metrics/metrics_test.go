@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	c := NewCounterVec("test_calls_total", "Calls.", "method")
+	c.With("chat.postMessage").Inc()
+	c.With("chat.postMessage").Inc()
+	h := NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1})
+	h.Observe(0.5)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	for _, expect := range []string{
+		"# TYPE test_calls_total counter\n",
+		`test_calls_total{method="chat.postMessage"} 2` + "\n",
+		`test_duration_seconds_bucket{le="0.1"} 0` + "\n",
+		`test_duration_seconds_bucket{le="1"} 1` + "\n",
+		`test_duration_seconds_bucket{le="+Inf"} 1` + "\n",
+		"test_duration_seconds_sum 0.5\n",
+		"test_duration_seconds_count 1\n",
+	} {
+		if !strings.Contains(body, expect) {
+			t.Errorf("missing %q in output:\n%s", expect, body)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package _all
 
 import (
+	_ "github.com/riking/marvin/modules/alias"
 	_ "github.com/riking/marvin/modules/antiflood"
 	_ "github.com/riking/marvin/modules/atcommand"
 	_ "github.com/riking/marvin/modules/autoinvite"
@@ -11,10 +12,13 @@ import (
 	_ "github.com/riking/marvin/modules/debug"
 	_ "github.com/riking/marvin/modules/factoid"
 	_ "github.com/riking/marvin/modules/githook"
+	_ "github.com/riking/marvin/modules/interactivity"
 	_ "github.com/riking/marvin/modules/on_reaction"
 	_ "github.com/riking/marvin/modules/paste"
+	_ "github.com/riking/marvin/modules/reminders"
 	_ "github.com/riking/marvin/modules/restart"
 	_ "github.com/riking/marvin/modules/rss"
+	_ "github.com/riking/marvin/modules/slashcommand"
 	//_ "github.com/riking/marvin/modules/timedpin"
 	_ "github.com/riking/marvin/modules/weblogin"
 )
@@ -0,0 +1,217 @@
+package alias
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+func init() {
+	marvin.RegisterModule(NewAliasModule)
+}
+
+const Identifier = "alias"
+
+var PermAliasManage = marvin.Permission{Name: "alias.manage", Level: marvin.AccessLevelAdmin}
+
+type AliasModule struct {
+	team marvin.Team
+
+	// names registered with the team, for Disable
+	lock       sync.Mutex
+	registered []string
+}
+
+func NewAliasModule(t marvin.Team) marvin.Module {
+	mod := &AliasModule{
+		team: t,
+	}
+	return mod
+}
+
+func (mod *AliasModule) Identifier() marvin.ModuleID {
+	return Identifier
+}
+
+func (mod *AliasModule) Load(t marvin.Team) {
+	t.DB().MustMigrate(Identifier, 1542153600, sqlMigrate1)
+	t.DB().SyntaxCheck(
+		sqlListAliases,
+		sqlAddAlias,
+		sqlRemoveAlias,
+	)
+}
+
+const (
+	helpAdd    = "`alias add <name> <expansion>` makes `@marvin <name>` run the expansion. Use `$1`, `$2`... for the arguments, or `$@` for all of them."
+	helpRemove = "`alias remove <name>` removes an alias."
+	helpList   = "`alias list` lists the aliases made with `alias add`."
+)
+
+func (mod *AliasModule) Enable(t marvin.Team) {
+	parent := marvin.NewParentCommand().WithHelp(
+		"The `alias` command manages shortcuts for other commands.\n" +
+			"For example, `@marvin alias add deploy-status factoid get status $1` makes `@marvin deploy-status prod` run `@marvin factoid get status prod`.\n" +
+			helpAdd + "\n" + helpRemove + "\n" + helpList,
+	)
+	parent.RegisterCommandFuncPerm("add", mod.CommandAdd, helpAdd, PermAliasManage)
+	parent.RegisterCommandFuncPerm("remove", mod.CommandRemove, helpRemove, PermAliasManage)
+	parent.RegisterCommandFunc("list", mod.CommandList, helpList)
+	t.RegisterCommand("alias", parent)
+
+	aliases, err := mod.listAliases()
+	if err != nil {
+		util.LogError(errors.Wrap(err, "loading aliases"))
+		return
+	}
+	for _, v := range aliases {
+		err = t.RegisterAlias(v.Name, v.Expansion)
+		if err != nil {
+			util.LogWarn("alias:", v.Name, err)
+			continue
+		}
+		mod.registered = append(mod.registered, v.Name)
+	}
+}
+
+func (mod *AliasModule) Disable(t marvin.Team) {
+	t.UnregisterCommand("alias")
+	mod.lock.Lock()
+	for _, v := range mod.registered {
+		t.UnregisterAlias(v)
+	}
+	mod.registered = nil
+	mod.lock.Unlock()
+}
+
+// ---
+
+const (
+	sqlMigrate1 = `
+	CREATE TABLE module_alias_aliases (
+		id         SERIAL PRIMARY KEY,
+		name       varchar(64) NOT NULL UNIQUE,
+		expansion  text NOT NULL,
+		created_by varchar(15) NOT NULL,
+		created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	sqlListAliases = `SELECT name, expansion, created_by FROM module_alias_aliases ORDER BY name`
+
+	// $1 = name $2 = expansion $3 = user
+	sqlAddAlias = `
+	INSERT INTO module_alias_aliases (name, expansion, created_by)
+	VALUES ($1, $2, $3)`
+
+	// $1 = name
+	sqlRemoveAlias = `DELETE FROM module_alias_aliases WHERE name = $1`
+)
+
+type userAlias struct {
+	Name      string
+	Expansion string
+	CreatedBy string
+}
+
+func (mod *AliasModule) listAliases() ([]userAlias, error) {
+	rows, err := mod.team.DB().Query(sqlListAliases)
+	if err != nil {
+		return nil, errors.Wrap(err, "list aliases")
+	}
+	defer rows.Close()
+
+	var result []userAlias
+	for rows.Next() {
+		var a userAlias
+		err = rows.Scan(&a.Name, &a.Expansion, &a.CreatedBy)
+		if err != nil {
+			return nil, errors.Wrap(err, "list aliases")
+		}
+		result = append(result, a)
+	}
+	return result, errors.Wrap(rows.Err(), "list aliases")
+}
+
+var rgxAliasName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+var (
+	argsAdd    = marvin.NewArgParser("alias add").Arg("name", marvin.ArgString).Rest("expansion", true)
+	argsRemove = marvin.NewArgParser("alias remove").Arg("name", marvin.ArgString)
+)
+
+func (mod *AliasModule) CommandAdd(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	p, fail := argsAdd.Parse(t, args)
+	if fail != nil {
+		return *fail
+	}
+	name := p.String("name")
+	expansion := strings.Join(p.Rest(), " ")
+	if !rgxAliasName.MatchString(name) {
+		return marvin.CmdFailuref(args, "Alias names may only contain lowercase letters, numbers, `-` and `_`.").WithSimpleUndo()
+	}
+
+	// Registering first checks for conflicts and cycles
+	err := t.RegisterAlias(name, expansion)
+	if err != nil {
+		return marvin.CmdFailuref(args, "Could not add alias: %v", err).WithSimpleUndo()
+	}
+	_, err = t.DB().Exec(sqlAddAlias, name, expansion, string(args.Source.UserID()))
+	if err != nil {
+		t.UnregisterAlias(name)
+		return marvin.CmdError(args, err, "Database error")
+	}
+	mod.lock.Lock()
+	mod.registered = append(mod.registered, name)
+	mod.lock.Unlock()
+	return marvin.CmdSuccess(args, fmt.Sprintf("`%s` now runs `%s`.", name, expansion)).WithNoUndo()
+}
+
+func (mod *AliasModule) CommandRemove(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	p, fail := argsRemove.Parse(t, args)
+	if fail != nil {
+		return *fail
+	}
+	name := p.String("name")
+
+	res, err := t.DB().Exec(sqlRemoveAlias, name)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return marvin.CmdFailuref(args, "`%s` is not an alias made with `alias add`.", name).WithSimpleUndo()
+	}
+	t.UnregisterAlias(name)
+	mod.lock.Lock()
+	for i, v := range mod.registered {
+		if v == name {
+			mod.registered = append(mod.registered[:i], mod.registered[i+1:]...)
+			break
+		}
+	}
+	mod.lock.Unlock()
+	return marvin.CmdSuccess(args, fmt.Sprintf("Removed alias `%s`.", name)).WithNoUndo()
+}
+
+func (mod *AliasModule) CommandList(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	aliases, err := mod.listAliases()
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	} else if len(aliases) == 0 {
+		return marvin.CmdSuccess(args, "No aliases have been added.").WithSimpleUndo()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Aliases:\n")
+	for _, v := range aliases {
+		fmt.Fprintf(&buf, "`%s` → `%s` _(by %v)_\n", v.Name, v.Expansion, t.UserName(slack.UserID(v.CreatedBy)))
+	}
+	return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+}
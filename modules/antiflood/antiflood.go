@@ -3,6 +3,7 @@ package antiflood
 import (
 	"fmt"
 	"github.com/riking/marvin/util"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +22,13 @@ type API interface {
 	marvin.Module
 
 	CheckChannel(channelID slack.ChannelID) bool
+	// CheckUser takes a token from the user's buckets for the action, which
+	// is something like "command:factoid" or "factoid:name". It returns
+	// false if the user is going too fast.
+	CheckUser(userID slack.UserID, channelID slack.ChannelID, action string) bool
+	// Throttled reacts to a message that was not acted on because of
+	// CheckUser.
+	Throttled(msgID slack.MessageID)
 }
 
 var _ API = &AntifloodModule{}
@@ -34,12 +42,24 @@ type AntifloodModule struct {
 
 	recentChannels map[slack.ChannelID]time.Time
 	antifloodMutex sync.Mutex
+
+	userBuckets   map[slack.UserID]*tokenBucket
+	actionBuckets map[actionKey]*tokenBucket
+	lastPrune     time.Time
+}
+
+type actionKey struct {
+	user    slack.UserID
+	channel slack.ChannelID
+	action  string
 }
 
 func NewAntifloodModule(t marvin.Team) marvin.Module {
 	mod := &AntifloodModule{
 		team:           t,
 		recentChannels: make(map[slack.ChannelID]time.Time),
+		userBuckets:    make(map[slack.UserID]*tokenBucket),
+		actionBuckets:  make(map[actionKey]*tokenBucket),
 	}
 	return mod
 }
@@ -50,7 +70,35 @@ func (mod *AntifloodModule) Identifier() marvin.ModuleID {
 
 func (mod *AntifloodModule) Load(t marvin.Team) {
 	c := mod.team.ModuleConfig(Identifier)
-	c.AddProtect(confKeyMsgThreshold, confThresholdDefault, true)
+	c.AddTyped(confKeyMsgThreshold, confThresholdDefault, marvin.ConfigSchema{
+		Type:        marvin.ConfTypeDuration,
+		Description: "Minimum time between automatic messages in a channel",
+		Protected:   true,
+	})
+	c.AddTyped(confKeyUserRate, "3s", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeDuration,
+		Description: "Time for a user to earn back one command, across all channels",
+		Protected:   true,
+	})
+	c.AddTyped(confKeyUserBurst, "10", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeInt,
+		Description: "Commands a user may run at once before being throttled",
+		Protected:   true,
+	})
+	c.AddTyped(confKeyActionRate, "15s", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeDuration,
+		Description: "Time for a user to earn back one use of the same command or factoid in a channel",
+		Protected:   true,
+	})
+	c.AddTyped(confKeyActionBurst, "4", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeInt,
+		Description: "Uses of the same command or factoid in a channel before being throttled",
+		Protected:   true,
+	})
+	c.AddTyped(confKeyThrottleEmoji, "hourglass_flowing_sand", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeEmoji,
+		Description: "Reaction for a message that was ignored because the user is going too fast",
+	})
 	c.OnModify(func(key string) {
 		if strings.Compare(key, confKeyMsgThreshold) == 0 {
 			go mod.ReloadConfig()
@@ -70,8 +118,17 @@ func (mod *AntifloodModule) Disable(t marvin.Team) {
 const (
 	confKeyMsgThreshold  = "threshold"
 	confThresholdDefault = "10s"
+
+	confKeyUserRate      = "user-rate"
+	confKeyUserBurst     = "user-burst"
+	confKeyActionRate    = "action-rate"
+	confKeyActionBurst   = "action-burst"
+	confKeyThrottleEmoji = "throttle-emoji"
 )
 
+// pruneInterval is how often full buckets are dropped.
+const pruneInterval = 10 * time.Minute
+
 func (mod *AntifloodModule) ReloadConfig() {
 	val, _ := mod.team.ModuleConfig(Identifier).Get(confKeyMsgThreshold)
 	threshold, err := time.ParseDuration(val)
@@ -85,12 +142,31 @@ func (mod *AntifloodModule) ReloadConfig() {
 	mod.threshold = threshold
 }
 
+// channelThreshold returns the threshold for the channel, which may be
+// overridden with `config set --channel`.
+func (mod *AntifloodModule) channelThreshold(channelID slack.ChannelID) (time.Duration, bool) {
+	val, err := mod.team.ModuleConfig(Identifier).GetForChannel(confKeyMsgThreshold, channelID)
+	if err != nil {
+		util.LogError(err)
+		return 0, false
+	}
+	threshold, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, false
+	}
+	return threshold, true
+}
+
 func (mod *AntifloodModule) CheckChannel(channelID slack.ChannelID) bool {
 	if channelID[0] == 'C' || channelID[0] == 'G' {
+		threshold, ok := mod.channelThreshold(channelID)
 		mod.antifloodMutex.Lock()
 		defer mod.antifloodMutex.Unlock()
+		if !ok {
+			threshold = mod.threshold
+		}
 		if val, ok := mod.recentChannels[channelID]; ok {
-			if !val.Before(time.Now().Add(-mod.threshold)) || val.Unix() == 0 {
+			if !val.Before(time.Now().Add(-threshold)) || val.Unix() == 0 {
 				return false
 			} else {
 				mod.recentChannels[channelID] = time.Now()
@@ -106,3 +182,86 @@ func (mod *AntifloodModule) CheckChannel(channelID slack.ChannelID) bool {
 	}
 	return true
 }
+
+// bucketConfig reads a rate and burst pair for the channel. Bad values turn
+// off the limit.
+func (mod *AntifloodModule) bucketConfig(rateKey, burstKey string, channelID slack.ChannelID) (time.Duration, int, bool) {
+	conf := mod.team.ModuleConfig(Identifier)
+	rateStr, _ := conf.GetForChannel(rateKey, channelID)
+	burstStr, _ := conf.GetForChannel(burstKey, channelID)
+	rate, err := time.ParseDuration(rateStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst <= 0 {
+		return 0, 0, false
+	}
+	return rate, burst, true
+}
+
+func (mod *AntifloodModule) CheckUser(userID slack.UserID, channelID slack.ChannelID, action string) bool {
+	if mod.team.UserLevel(userID) >= marvin.AccessLevelController {
+		return true
+	}
+	userRate, userBurst, userOK := mod.bucketConfig(confKeyUserRate, confKeyUserBurst, "")
+	actionRate, actionBurst, actionOK := mod.bucketConfig(confKeyActionRate, confKeyActionBurst, channelID)
+
+	now := time.Now()
+	mod.antifloodMutex.Lock()
+	defer mod.antifloodMutex.Unlock()
+
+	if now.Sub(mod.lastPrune) > pruneInterval {
+		mod.prune(now, userRate, userBurst, actionRate, actionBurst)
+	}
+
+	key := actionKey{user: userID, channel: channelID, action: action}
+	if actionOK {
+		b := mod.actionBuckets[key]
+		if b == nil {
+			b = newTokenBucket(actionBurst, now)
+			mod.actionBuckets[key] = b
+		}
+		if ok, _ := b.take(now, actionRate, actionBurst); !ok {
+			return false
+		}
+	}
+	if userOK {
+		b := mod.userBuckets[userID]
+		if b == nil {
+			b = newTokenBucket(userBurst, now)
+			mod.userBuckets[userID] = b
+		}
+		if ok, _ := b.take(now, userRate, userBurst); !ok {
+			// Give back the action token, nothing was done
+			if b, ok := mod.actionBuckets[key]; ok && actionOK {
+				b.tokens++
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// prune drops buckets that have refilled. Must be called with the lock held.
+func (mod *AntifloodModule) prune(now time.Time, userRate time.Duration, userBurst int, actionRate time.Duration, actionBurst int) {
+	mod.lastPrune = now
+	for k, b := range mod.userBuckets {
+		if b.idle(now, userRate, userBurst) {
+			delete(mod.userBuckets, k)
+		}
+	}
+	for k, b := range mod.actionBuckets {
+		if b.idle(now, actionRate, actionBurst) {
+			delete(mod.actionBuckets, k)
+		}
+	}
+}
+
+func (mod *AntifloodModule) Throttled(msgID slack.MessageID) {
+	emoji, _ := mod.team.ModuleConfig(Identifier).GetForChannel(confKeyThrottleEmoji, msgID.ChannelID)
+	if emoji == "" {
+		return
+	}
+	util.LogIfError(mod.team.ReactMessage(msgID, emoji))
+}
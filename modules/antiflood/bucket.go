@@ -0,0 +1,44 @@
+package antiflood
+
+import (
+	"time"
+)
+
+// A tokenBucket allows burst actions at once, then one action per rate.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), last: now}
+}
+
+// take removes a token if one is available. If not, it returns the time until
+// the next token.
+func (b *tokenBucket) take(now time.Time, rate time.Duration, burst int) (bool, time.Duration) {
+	b.refill(now, rate, burst)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(rate))
+}
+
+func (b *tokenBucket) refill(now time.Time, rate time.Duration, burst int) {
+	if rate > 0 {
+		b.tokens += float64(now.Sub(b.last)) / float64(rate)
+	} else {
+		b.tokens = float64(burst)
+	}
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+}
+
+// idle reports whether the bucket is full and can be dropped.
+func (b *tokenBucket) idle(now time.Time, rate time.Duration, burst int) bool {
+	b.refill(now, rate, burst)
+	return b.tokens >= float64(burst)
+}
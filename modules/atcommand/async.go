@@ -0,0 +1,128 @@
+package atcommand
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+// asyncProgressInterval limits how often a placeholder reply is edited.
+const asyncProgressInterval = 3 * time.Second
+
+const helpCancel = "`cancel <id>` stops a command that is still running. The ID is in the command's reply."
+
+// asyncReply is the placeholder reply of a command that is still running.
+type asyncReply struct {
+	lock       sync.Mutex
+	msg        ReplyActionSentMessage
+	prefix     string
+	suffix     string
+	lastUpdate time.Time
+}
+
+func (ar *asyncReply) progress(mod *AtCommandModule) marvin.ProgressFunc {
+	return func(msg string) {
+		ar.lock.Lock()
+		defer ar.lock.Unlock()
+		if ar.msg.Text == "" || time.Since(ar.lastUpdate) < asyncProgressInterval {
+			return
+		}
+		ar.lastUpdate = time.Now()
+		ar.msg.Update(mod, SanitizeForChannel(ar.prefix+msg+ar.suffix))
+	}
+}
+
+// startAsync runs the rest of an in-progress command in the background,
+// with a placeholder reply that shows its progress.
+func (mod *AtCommandModule) startAsync(fciResult *FinishedCommandInfo, source marvin.ActionSource, rtm slack.SlackTextMessage, result marvin.CommandResult) {
+	reply := &asyncReply{}
+	id, done := mod.team.StartCommand(result, reply.progress(mod))
+	fciResult.RunningCommand = id
+
+	busyEmoji := mod.GetEmojiForResponse(result)
+	go mod.team.ReactMessage(rtm.MessageID(), busyEmoji)
+	fciResult.AddEmojiReaction(rtm.MessageID(), busyEmoji)
+
+	reply.prefix = fmt.Sprintf("%v: ", rtm.UserID())
+	reply.suffix = fmt.Sprintf(" _(`@marvin cancel %d` to stop)_", id)
+	text := reply.prefix + result.Message + reply.suffix
+	ts, err := mod.sendReply(rtm.ChannelID(), replyThread(rtm, result.ReplyType), result.ReplyType, SanitizeForChannel(text))
+	if err != nil {
+		util.LogError(err)
+	} else {
+		reply.lock.Lock()
+		reply.msg = ReplyActionSentMessage{MessageID: slack.MsgID(rtm.ChannelID(), ts), Text: text}
+		reply.lastUpdate = time.Now()
+		fciResult.ActionChanMsg = reply.msg
+		reply.lock.Unlock()
+	}
+
+	go mod.finishAsync(fciResult, source, reply, done)
+}
+
+func (mod *AtCommandModule) finishAsync(fciResult *FinishedCommandInfo, source marvin.ActionSource, reply *asyncReply, done <-chan marvin.CommandResult) {
+	result := <-done
+
+	// No more progress updates
+	reply.lock.Lock()
+	reply.msg = ReplyActionSentMessage{}
+	reply.lock.Unlock()
+
+	fciResult.Lock.Lock()
+	defer fciResult.Lock.Unlock()
+	fciResult.RunningCommand = 0
+	fciResult.CommandResult = result
+	fciResult.ChangeEmoji(mod, []ReplyActionEmoji{
+		{MessageID: fciResult.OriginalMsg.MessageID(), Emoji: mod.GetEmojiForResponse(result)},
+	})
+	mod.updateReplies(fciResult, source, result)
+}
+
+// handleDelete cancels a running command, or one waiting for confirmation,
+// when its message is deleted.
+func (mod *AtCommandModule) handleDelete(rtm slack.RTMRawMessage) {
+	msgID := slack.MsgID(rtm.ChannelID(), slack.MessageTS(rtm.StringField("deleted_ts")))
+
+	mod.recentCommandsLock.Lock()
+	fciMeta, ok := mod.recentCommands[msgID]
+	mod.recentCommandsLock.Unlock()
+	if !ok {
+		return
+	}
+
+	fciMeta.Lock.Lock()
+	mod.dropConfirms(fciMeta, "command removed")
+	id := fciMeta.RunningCommand
+	fciMeta.Lock.Unlock()
+	if id != 0 {
+		util.LogGood("Cancelling command", id, "as its message was deleted")
+		mod.team.CancelCommand(id)
+	}
+}
+
+func (mod *AtCommandModule) CommandCancel(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) != 1 {
+		return marvin.CmdUsage(args, helpCancel).WithSimpleUndo()
+	}
+	id, err := strconv.ParseInt(args.Arguments[0], 10, 64)
+	if err != nil {
+		return marvin.CmdFailuref(args, "'%s' is not a command ID.", args.Arguments[0]).WithSimpleUndo()
+	}
+
+	for _, v := range t.RunningCommands() {
+		if v.ID != id {
+			continue
+		}
+		if v.User != args.Source.UserID() && args.Source.AccessLevel() < marvin.AccessLevelAdmin {
+			return marvin.CmdFailuref(args, "Only %v or an admin can cancel command %d.", v.User, id).WithSimpleUndo()
+		}
+		t.CancelCommand(id)
+		return marvin.CmdSuccess(args, fmt.Sprintf("Cancelling `%s`.", v.Command)).WithNoUndo()
+	}
+	return marvin.CmdFailuref(args, "Command %d is not running.", id).WithSimpleUndo()
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/modules/antiflood"
+	"github.com/riking/marvin/modules/on_reaction"
 	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/util"
 )
@@ -26,8 +27,7 @@ func init() {
 const Identifier = "atcommand"
 
 type AtCommandModule struct {
-	team    marvin.Team
-	enabled int
+	team marvin.Team
 
 	rgxLock     sync.RWMutex
 	mentionRgx2 *regexp.Regexp
@@ -35,12 +35,19 @@ type AtCommandModule struct {
 
 	recentCommandsLock sync.Mutex
 	recentCommands     map[slack.MessageID]*FinishedCommandInfo
+	janitorStop        chan struct{}
+
+	onReact marvin.Module
+
+	confirmLock     sync.Mutex
+	pendingConfirms map[slack.MessageID]*pendingConfirm
 }
 
 func NewAtCommandModule(t marvin.Team) marvin.Module {
 	mod := &AtCommandModule{
-		team:           t,
-		recentCommands: make(map[slack.MessageID]*FinishedCommandInfo),
+		team:            t,
+		recentCommands:  make(map[slack.MessageID]*FinishedCommandInfo),
+		pendingConfirms: make(map[slack.MessageID]*pendingConfirm),
 	}
 	return mod
 }
@@ -50,42 +57,65 @@ func (mod *AtCommandModule) Identifier() marvin.ModuleID {
 }
 
 func (mod *AtCommandModule) Load(t marvin.Team) {
+	t.DependModule(mod, on_reaction.Identifier, &mod.onReact)
 	mod.mentionRgx1 = regexp.MustCompile(fmt.Sprintf(`<@%s>`, mod.team.BotUser()))
 	mod.mentionRgx2 = regexp.MustCompile(fmt.Sprintf(`(?m:(?:\n|^)\s*(<@%s>)\s+())`, mod.team.BotUser()))
 
 	c := mod.team.ModuleConfig(Identifier)
-	c.Add(confKeyEmojiHi, "wave")
-	c.Add(confKeyEmojiOk, "white_check_mark")
-	c.Add(confKeyEmojiFail, "negative_squared_cross_mark")
-	c.Add(confKeyEmojiError, "warning")
-	c.Add(confKeyEmojiUnkCmd, "question")
-	c.Add(confKeyEmojiUsage, "confused")
-	c.Add(confKeyEmojiHelp, "memo")
+	emoji := func(desc string) marvin.ConfigSchema {
+		return marvin.ConfigSchema{Type: marvin.ConfTypeEmoji, Description: desc}
+	}
+	c.AddTyped(confKeyEmojiHi, "wave", emoji("Reaction when Marvin is mentioned without a command"))
+	c.AddTyped(confKeyEmojiOk, "white_check_mark", emoji("Reaction for a successful command"))
+	c.AddTyped(confKeyEmojiFail, "negative_squared_cross_mark", emoji("Reaction for a failed command"))
+	c.AddTyped(confKeyEmojiError, "warning", emoji("Reaction for a command that hit an error"))
+	c.AddTyped(confKeyEmojiUnkCmd, "question", emoji("Reaction for an unknown command"))
+	c.AddTyped(confKeyEmojiUsage, "confused", emoji("Reaction when usage is printed"))
+	c.AddTyped(confKeyEmojiHelp, "memo", emoji("Reaction when help is printed"))
+	c.AddTyped(confKeyEmojiBusy, "hourglass_flowing_sand", emoji("Reaction while a command is still running"))
+	c.AddTyped(confKeyEmojiConfirm, "raised_hand", emoji("Reaction while a command waits for confirmation"))
+	c.AddTyped(confKeyEmojiUndo, "leftwards_arrow_with_hook", emoji("React to your own command with this to undo it"))
+	c.AddTyped(confKeyEmojiRerun, "repeat", emoji("React to your own command with this to run it again"))
 }
 
 func (mod *AtCommandModule) Enable(t marvin.Team) {
 	t.OnEvent(Identifier, "hello", mod.OnHello)
 	t.OnNormalMessage(Identifier, mod.HandleMessage)
 	t.OnSpecialMessage(Identifier, []string{"message_changed", "message_deleted"}, mod.HandleEdit)
-	mod.enabled += 1
-	go mod.janitorRecentMessages(mod.enabled)
+	t.OnEvent(Identifier, "reaction_added", mod.HandleReaction)
+	mod.janitorStop = make(chan struct{})
+	go mod.janitorRecentMessages(mod.janitorStop)
+	t.RegisterCommandFuncPerm("batch", mod.CommandBatch, helpBatch, PermBatch)
+	t.RegisterCommandFunc("cancel", mod.CommandCancel, helpCancel)
+	if onReact := mod.onReactAPI(); onReact != nil {
+		onReact.RegisterFunc(mod.onConfirmReaction, Identifier)
+	}
 }
 
 func (mod *AtCommandModule) Disable(t marvin.Team) {
-	mod.enabled += 1
 	t.OffAllEvents(Identifier)
+	close(mod.janitorStop)
+	t.UnregisterCommand("batch")
+	t.UnregisterCommand("cancel")
+	if onReact := mod.onReactAPI(); onReact != nil {
+		onReact.Unregister(Identifier)
+	}
 }
 
 // -----
 
 const (
-	confKeyEmojiHi     = "emoji-hi"
-	confKeyEmojiOk     = "emoji-ok"
-	confKeyEmojiFail   = "emoji-fail"
-	confKeyEmojiError  = "emoji-error"
-	confKeyEmojiUnkCmd = "emoji-unknown"
-	confKeyEmojiUsage  = "emoji-usage"
-	confKeyEmojiHelp   = "emoji-help"
+	confKeyEmojiHi      = "emoji-hi"
+	confKeyEmojiOk      = "emoji-ok"
+	confKeyEmojiFail    = "emoji-fail"
+	confKeyEmojiError   = "emoji-error"
+	confKeyEmojiUnkCmd  = "emoji-unknown"
+	confKeyEmojiUsage   = "emoji-usage"
+	confKeyEmojiHelp    = "emoji-help"
+	confKeyEmojiBusy    = "emoji-busy"
+	confKeyEmojiConfirm = "emoji-confirm"
+	confKeyEmojiUndo    = "emoji-undo"
+	confKeyEmojiRerun   = "emoji-rerun"
 )
 
 func (mod *AtCommandModule) OnHello(_rtm slack.RTMRawMessage) {
@@ -127,6 +157,31 @@ func (rsm ReplyActionSentMessage) Update(mod *AtCommandModule, newText string) e
 	return util.LogIfError(mod.team.SlackAPIPostJSON("chat.update", form, nil))
 }
 
+// replyThread returns the thread that channel replies to msg should go in, or
+// "" to reply at the top level.
+func replyThread(msg slack.SlackTextMessage, rt marvin.ReplyType) slack.MessageTS {
+	if threadTS := msg.ThreadTS(); threadTS != "" {
+		return threadTS
+	}
+	if rt&marvin.ReplyTypeFlagInThread != 0 {
+		return msg.MessageTS()
+	}
+	return ""
+}
+
+// sendReply posts a message in the channel, inside threadTS if it is set.
+// sendReply posts a command reply. Replies skip ahead of other queued
+// messages, like feed posts.
+func (mod *AtCommandModule) sendReply(channel slack.ChannelID, threadTS slack.MessageTS, rt marvin.ReplyType, text string) (slack.MessageTS, error) {
+	ts, _, err := mod.team.SendComplexMessage(channel, slack.OutgoingSlackMessage{
+		Text:      text,
+		ThreadTS:  threadTS,
+		Broadcast: threadTS != "" && rt&marvin.ReplyTypeFlagBroadcast != 0,
+		Priority:  slack.PriorityHigh,
+	})
+	return ts, err
+}
+
 type FinishedCommandInfo struct {
 	MyTimestamp time.Time
 
@@ -139,6 +194,8 @@ type FinishedCommandInfo struct {
 	CommandArgs   *marvin.CommandArguments
 	CommandResult marvin.CommandResult
 	FailedUndo    bool
+	// RunningCommand is the ID of the command while it runs asynchronously.
+	RunningCommand int64
 
 	ActionEmoji    []ReplyActionEmoji
 	ActionChanMsg  ReplyActionSentMessage
@@ -152,6 +209,7 @@ func (fci *FinishedCommandInfo) AddEmojiReaction(messageID slack.MessageID, emoj
 }
 
 func (fci *FinishedCommandInfo) ChangeEmoji(mod *AtCommandModule, new []ReplyActionEmoji) {
+	var undo, add []ReplyActionEmoji
 	for _, v := range fci.ActionEmoji {
 		match := false
 		for _, v2 := range new {
@@ -161,7 +219,7 @@ func (fci *FinishedCommandInfo) ChangeEmoji(mod *AtCommandModule, new []ReplyAct
 			}
 		}
 		if !match {
-			go v.Undo(mod)
+			undo = append(undo, v)
 		}
 	}
 	for _, v := range new {
@@ -173,16 +231,36 @@ func (fci *FinishedCommandInfo) ChangeEmoji(mod *AtCommandModule, new []ReplyAct
 			}
 		}
 		if !match {
-			go mod.team.ReactMessage(v.MessageID, v.Emoji)
+			add = append(add, v)
 		}
 	}
 	fci.ActionEmoji = new
+
+	// The API queue orders these; one goroutine is enough
+	if len(undo) > 0 || len(add) > 0 {
+		go func() {
+			for _, v := range undo {
+				v.Undo(mod)
+			}
+			for _, v := range add {
+				mod.team.ReactMessage(v.MessageID, v.Emoji)
+			}
+		}()
+	}
 }
 
-func (mod *AtCommandModule) janitorRecentMessages(epoch int) {
+// janitorRecentMessages forgets old commands until stop is closed. Every
+// process has its own recentCommands, so this is not a scheduled job.
+func (mod *AtCommandModule) janitorRecentMessages(stop <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
 	for {
-		time.Sleep(30 * time.Minute)
-		mod._cleanRecentMessages()
+		select {
+		case <-ticker.C:
+			mod._cleanRecentMessages()
+		case <-stop:
+			return
+		}
 	}
 }
 
@@ -205,7 +283,7 @@ type parseMessageReturn struct {
 }
 
 func (mod *AtCommandModule) ParseMessage(rtm slack.SlackTextMessage) (result parseMessageReturn) {
-	factoidChars, _ := mod.team.ModuleConfig("factoid").Get("factoid-char")
+	factoidChars, _ := mod.team.ModuleConfig("factoid").GetForChannel("factoid-char", rtm.ChannelID())
 	if rtm.Text() == "" || strings.ContainsAny(rtm.Text()[:1], factoidChars) {
 		return
 	}
@@ -253,10 +331,6 @@ func (mod *AtCommandModule) HandleMessage(_rtm slack.RTMRawMessage) {
 	if !rtm.AssertText() {
 		return
 	}
-	if _, isThread := _rtm["thread_ts"]; isThread {
-		// TODO thread operation
-		return
-	}
 
 	userLvl := mod.team.UserLevel(rtm.UserID())
 	if userLvl < marvin.AccessLevelNormal {
@@ -276,13 +350,18 @@ func (mod *AtCommandModule) HandleMessage(_rtm slack.RTMRawMessage) {
 		mod.recentCommands[_rtm.MessageID()] = fciResult
 		mod.recentCommandsLock.Unlock()
 
-		reactEmoji, _ := mod.team.ModuleConfig(Identifier).Get(confKeyEmojiHi)
+		reactEmoji, _ := mod.team.ModuleConfig(Identifier).GetForChannel(confKeyEmojiHi, rtm.ChannelID())
 		fciResult.AddEmojiReaction(rtm.MessageID(), reactEmoji)
 		mod.team.ReactMessage(rtm.MessageID(), reactEmoji)
 		return
 	} else if parseResult.argSplit != nil || parseResult.splitErr != nil {
-		if userLvl < marvin.AccessLevelAdmin &&
-			!mod.team.GetModule(antiflood.Identifier).(antiflood.API).CheckChannel(rtm.ChannelID()) {
+		flood := mod.team.GetModule(antiflood.Identifier).(antiflood.API)
+		if userLvl < marvin.AccessLevelAdmin && !flood.CheckChannel(rtm.ChannelID()) {
+			return
+		}
+		if len(parseResult.argSplit) > 0 &&
+			!flood.CheckUser(rtm.UserID(), rtm.ChannelID(), "command:"+strings.ToLower(parseResult.argSplit[0])) {
+			flood.Throttled(rtm.MessageID())
 			return
 		}
 		mod.recentCommandsLock.Lock()
@@ -299,6 +378,10 @@ func (mod *AtCommandModule) HandleMessage(_rtm slack.RTMRawMessage) {
 }
 
 func (mod *AtCommandModule) HandleEdit(_rtm slack.RTMRawMessage) {
+	if _rtm.Subtype() == "message_deleted" {
+		mod.handleDelete(_rtm)
+		return
+	}
 	if _rtm.Subtype() != "message_changed" {
 		return
 	}
@@ -309,10 +392,6 @@ func (mod *AtCommandModule) HandleEdit(_rtm slack.RTMRawMessage) {
 	if rtm.EditingUserID() == "" {
 		return // unfurl edit
 	}
-	if _, isThread := _rtm["thread_ts"]; isThread {
-		// TODO thread operation
-		return
-	}
 
 	msgID := rtm.MessageID()
 	time.Sleep(50 * time.Millisecond) // slack is out-of-order sometimes
@@ -386,59 +465,18 @@ func (mod *AtCommandModule) HandleEdit(_rtm slack.RTMRawMessage) {
 }
 
 func (mod *AtCommandModule) canEdit(fciMeta *FinishedCommandInfo) (canEdit bool) {
-
-	if fciMeta.CommandResult.CanEdit == util.TriYes {
-		// Custom edit
-		return true
-	} else if fciMeta.CommandResult.CanEdit == util.TriNo {
-		return false
-	} else { // TriDefault
-		switch fciMeta.CommandResult.Code {
-		case marvin.CmdResultFailure:
-			return true
-		case marvin.CmdResultOK:
-			return false
-		case marvin.CmdResultError:
-			return false // error
-		case marvin.CmdResultNoSuchCommand, marvin.CmdResultPrintUsage, marvin.CmdResultPrintHelp:
-			return true
-		}
-	}
-	panic("unrecognized command result code")
+	return fciMeta.CommandResult.Editable()
 }
 
 func (mod *AtCommandModule) canUndo(fciMeta *FinishedCommandInfo) (canUndo, custom bool) {
 	if fciMeta.FailedUndo {
 		return false, false
 	}
-
-	if fciMeta.CommandResult.CanEdit == util.TriNo {
-		// Undo not supported
-		return false, false
-	} else if fciMeta.CommandResult.CanEdit == marvin.UndoCustom {
-		// Custom undo
-		return true, true
-	} else if fciMeta.CommandResult.CanEdit == marvin.UndoSimple {
-		return true, false
-	} else { // TriDefault
-		switch fciMeta.CommandResult.Code {
-		case marvin.CmdResultOK:
-			// Undo not supported
-			return false, false
-		case marvin.CmdResultFailure:
-			return true, false
-		case marvin.CmdResultError:
-			return false, false // error
-		case marvin.CmdResultNoSuchCommand, marvin.CmdResultPrintUsage, marvin.CmdResultPrintHelp:
-			return true, false
-		}
-	}
-	panic("unrecognized command result code")
+	return fciMeta.CommandResult.Undoable()
 }
 
 func (mod *AtCommandModule) EditCommand(fciMeta *FinishedCommandInfo, source marvin.ActionSource) {
 	imChannel, _ := mod.team.GetIM(source.UserID())
-	logChannel := mod.team.TeamConfig().LogChannel
 
 	canEdit := mod.canEdit(fciMeta)
 
@@ -477,7 +515,7 @@ func (mod *AtCommandModule) EditCommand(fciMeta *FinishedCommandInfo, source mar
 		PreviousResult: &fciMeta.CommandResult,
 		ModuleData:     fciMeta.CommandResult.Args.ModuleData,
 	}
-	result := mod.team.DispatchCommand(args)
+	result := marvin.WaitCommand(mod.team, mod.team.DispatchCommand(args))
 	fciMeta.CommandResult = result
 	fciMeta.CommandArgs = args
 
@@ -487,18 +525,32 @@ func (mod *AtCommandModule) EditCommand(fciMeta *FinishedCommandInfo, source mar
 	newEmojiAry = append(newEmojiAry, ReplyActionEmoji{MessageID: fciMeta.OriginalMsg.MessageID(), Emoji: "fast_forward"})
 	fciMeta.ChangeEmoji(mod, newEmojiAry)
 
+	mod.updateReplies(fciMeta, source, result)
+}
+
+// updateReplies sends the replies for a new result of the command, editing
+// the earlier replies where there are any.
+func (mod *AtCommandModule) updateReplies(fciMeta *FinishedCommandInfo, source marvin.ActionSource, result marvin.CommandResult) {
+	imChannel, _ := mod.team.GetIM(source.UserID())
+	logChannel := mod.team.TeamConfig().LogChannel
+
 	didSendMessageChannel := false
 	didSendMessageIM := false
+	threadTS := replyThread(fciMeta.OriginalMsg, result.ReplyType)
+	imThreadTS := slack.MessageTS("")
+	if source.ChannelID() == imChannel {
+		imThreadTS = threadTS
+	}
 	sendMessageChannel := func(msg string) {
 		didSendMessageChannel = true
 		if fciMeta.ActionChanMsg.Text != "" {
 			fciMeta.ActionChanMsg.Update(mod, msg)
 		} else {
-			ts, _, err := mod.team.SendMessage(source.ChannelID(), SanitizeForChannel(msg))
+			ts, err := mod.sendReply(source.ChannelID(), threadTS, result.ReplyType, SanitizeForChannel(msg))
 			if err != nil {
 				util.LogError(err)
 			}
-			fciMeta.ActionChanMsg = ReplyActionSentMessage{MessageID: slack.MsgID(imChannel, ts), Text: msg}
+			fciMeta.ActionChanMsg = ReplyActionSentMessage{MessageID: slack.MsgID(source.ChannelID(), ts), Text: msg}
 		}
 	}
 	sendMessageIM := func(msg string) {
@@ -506,7 +558,7 @@ func (mod *AtCommandModule) EditCommand(fciMeta *FinishedCommandInfo, source mar
 		if fciMeta.ActionPMMsg.Text != "" {
 			fciMeta.ActionPMMsg.Update(mod, msg)
 		} else {
-			ts, _, err := mod.team.SendMessage(imChannel, SanitizeLoose(msg))
+			ts, err := mod.sendReply(imChannel, imThreadTS, result.ReplyType, SanitizeLoose(msg))
 			if err != nil {
 				util.LogError(err)
 			}
@@ -514,7 +566,7 @@ func (mod *AtCommandModule) EditCommand(fciMeta *FinishedCommandInfo, source mar
 		}
 	}
 	sendMessageIMLog := func(msg string) {
-		_, _, err := mod.team.SendMessage(imChannel, SanitizeLoose(msg))
+		_, err := mod.sendReply(imChannel, imThreadTS, result.ReplyType, SanitizeLoose(msg))
 		if err != nil {
 			util.LogError(err)
 		}
@@ -558,7 +610,7 @@ func (mod *AtCommandModule) UndoCommand(fciMeta *FinishedCommandInfo, source mar
 		return
 	}
 	if fciMeta.parseResult.wave {
-		reactEmoji, _ := mod.team.ModuleConfig(Identifier).Get(confKeyEmojiHi)
+		reactEmoji, _ := mod.team.ModuleConfig(Identifier).GetForChannel(confKeyEmojiHi, fciMeta.OriginalMsg.ChannelID())
 		newEmoji = append(newEmoji, ReplyActionEmoji{MessageID: fciMeta.OriginalMsg.MessageID(), Emoji: reactEmoji})
 	}
 	if canUndo && !customUndo {
@@ -598,7 +650,7 @@ func (mod *AtCommandModule) UndoCommand(fciMeta *FinishedCommandInfo, source mar
 		IsUndo:         true,
 		IsEdit:         false,
 	}
-	result := mod.team.DispatchCommand(args)
+	result := marvin.WaitCommand(mod.team, mod.team.DispatchCommand(args))
 
 	resultEmoji := mod.GetEmojiForResponse(result)
 	newEmoji = append(newEmoji, ReplyActionEmoji{MessageID: fciMeta.OriginalMsg.MessageID(), Emoji: resultEmoji})
@@ -607,16 +659,17 @@ func (mod *AtCommandModule) UndoCommand(fciMeta *FinishedCommandInfo, source mar
 
 	logChannel := mod.team.TeamConfig().LogChannel
 	didSendMessageChannel := false
+	threadTS := replyThread(fciMeta.OriginalMsg, result.ReplyType)
 	sendMessageChannel := func(msg string) {
 		if fciMeta.ActionChanMsg.Text != "" {
 			didSendMessageChannel = true
 			fciMeta.ActionChanMsg.Update(mod, msg)
 		} else {
-			ts, _, err := mod.team.SendMessage(source.ChannelID(), SanitizeForChannel(msg))
+			ts, err := mod.sendReply(source.ChannelID(), threadTS, result.ReplyType, SanitizeForChannel(msg))
 			if err != nil {
 				util.LogError(err)
 			}
-			fciMeta.ActionChanMsg = ReplyActionSentMessage{MessageID: slack.MsgID(imChannel, ts), Text: msg}
+			fciMeta.ActionChanMsg = ReplyActionSentMessage{MessageID: slack.MsgID(source.ChannelID(), ts), Text: msg}
 		}
 	}
 	sendMessageIM := func(msg string) {
@@ -674,6 +727,17 @@ func (mod *AtCommandModule) ProcessInitialCommandMessage(fciResult *FinishedComm
 		result = mod.team.DispatchCommand(args)
 	}
 	fciResult.CommandResult = result
+	if result.Code == marvin.CmdResultInProgress {
+		mod.startAsync(fciResult, source, rtm, result)
+		return
+	}
+	if result.Code == marvin.CmdResultNeedsConfirm {
+		if mod.askConfirm(fciResult, source, rtm, result) {
+			return
+		}
+		result = confirmUnavailable(result)
+		fciResult.CommandResult = result
+	}
 
 	reactEmoji := mod.GetEmojiForResponse(result)
 	var wg sync.WaitGroup
@@ -685,8 +749,13 @@ func (mod *AtCommandModule) ProcessInitialCommandMessage(fciResult *FinishedComm
 
 	logChannel := mod.team.TeamConfig().LogChannel
 	imChannel, _ := mod.team.GetIM(rtm.UserID())
+	threadTS := replyThread(rtm, result.ReplyType)
+	imThreadTS := slack.MessageTS("")
+	if rtm.ChannelID() == imChannel {
+		imThreadTS = threadTS
+	}
 	sendMessageChannel := func(msg string) {
-		ts, _, err := mod.team.SendMessage(rtm.ChannelID(), SanitizeForChannel(msg))
+		ts, err := mod.sendReply(rtm.ChannelID(), threadTS, result.ReplyType, SanitizeForChannel(msg))
 		if err != nil {
 			util.LogError(err)
 		} else {
@@ -694,7 +763,7 @@ func (mod *AtCommandModule) ProcessInitialCommandMessage(fciResult *FinishedComm
 		}
 	}
 	sendMessageIM := func(msg string) {
-		ts, _, err := mod.team.SendMessage(imChannel, SanitizeLoose(msg))
+		ts, err := mod.sendReply(imChannel, imThreadTS, result.ReplyType, SanitizeLoose(msg))
 		if err != nil {
 			util.LogError(err)
 		} else {
@@ -702,7 +771,7 @@ func (mod *AtCommandModule) ProcessInitialCommandMessage(fciResult *FinishedComm
 		}
 	}
 	sendMessageIMLog := func(msg string) {
-		ts, _, err := mod.team.SendMessage(imChannel, SanitizeLoose(msg))
+		ts, err := mod.sendReply(imChannel, imThreadTS, result.ReplyType, SanitizeLoose(msg))
 		if err != nil {
 			util.LogError(err)
 		} else {
@@ -815,12 +884,16 @@ func (mod *AtCommandModule) SendReplyMessages(
 			util.LogError(result.Err)
 		}
 	case marvin.CmdResultNoSuchCommand:
-		if replyChannel {
-			// Nothing
+		didYouMean := marvin.FormatSuggestions("", result.Suggestions)
+		if replyChannel && didYouMean != "" {
+			sendMessageChannel(fmt.Sprintf("%v: %s", source.UserID(), didYouMean))
 		}
 		if replyIM || replyIMPrimary {
-			sendMessageIMLog(fmt.Sprintf("I didn't quite understand that, sorry.\nYou said: [%s]",
-				strings.Join(result.Args.OriginalArguments, "] [")))
+			if didYouMean != "" {
+				didYouMean += "\n"
+			}
+			sendMessageIMLog(fmt.Sprintf("I didn't quite understand that, sorry.\n%sYou said: [%s]",
+				didYouMean, strings.Join(result.Args.OriginalArguments, "] [")))
 		}
 		if replyLog {
 			sendMessageLog(fmt.Sprintf("No such command from %v\nArgs: [%s]\nLink: %s",
@@ -849,6 +922,9 @@ func (mod *AtCommandModule) SendReplyMessages(
 var rgxTakeCodeBlock = regexp.MustCompile(`^&amp;(\d+)$`)
 var rgxCodeBlock = regexp.MustCompile("(?m:^)```\n?(?s:(.*?))\n?```()(?m:$|\\s)")
 
+// ParseArgs splits the line starting at startIdx into arguments, replacing
+// `&N` with the contents of the Nth code block. A `|` between spaces stays a
+// separate argument, and Team.DispatchCommand runs it as a pipeline.
 func ParseArgs(raw string, startIdx int) ([]string, error) {
 	endOfLine := strings.IndexByte(raw[startIdx:], '\n')
 	if endOfLine == -1 {
@@ -921,21 +997,30 @@ func SanitizeForChannel(msg string) string {
 
 func (mod *AtCommandModule) GetEmojiForResponse(result marvin.CommandResult) string {
 	var reactEmoji string
+	var channel slack.ChannelID
+	if result.Args != nil && result.Args.Source != nil {
+		channel = result.Args.Source.ChannelID()
+	}
+	conf := mod.team.ModuleConfig(Identifier)
 	switch result.Code {
 	case marvin.CmdResultOK:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiOk)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiOk, channel)
 	case marvin.CmdResultFailure:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiFail)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiFail, channel)
 	case marvin.CmdResultError:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiError)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiError, channel)
 	case marvin.CmdResultNoSuchCommand:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiUnkCmd)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiUnkCmd, channel)
 	case marvin.CmdResultPrintUsage:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiUsage)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiUsage, channel)
 	case marvin.CmdResultPrintHelp:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiHelp)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiHelp, channel)
+	case marvin.CmdResultInProgress:
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiBusy, channel)
+	case marvin.CmdResultNeedsConfirm:
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiConfirm, channel)
 	default:
-		reactEmoji, _ = mod.team.ModuleConfig(Identifier).Get(confKeyEmojiError)
+		reactEmoji, _ = conf.GetForChannel(confKeyEmojiError, channel)
 	}
 	return reactEmoji
 }
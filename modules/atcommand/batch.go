@@ -0,0 +1,162 @@
+package atcommand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+var PermBatch = marvin.Permission{Name: "command.batch", Level: marvin.AccessLevelAdmin}
+
+// maxBatchLines limits the size of one batch.
+const maxBatchLines = 100
+
+const helpBatch = "`batch [--stop] [&1]` runs each following line of the message, or each line of the code block, as a command.\n" +
+	"Blank lines and lines starting with `#` are skipped. With `--stop`, the batch ends at the first command that does not succeed."
+
+// ctxKeyBatch marks the context of commands run by a batch, however they
+// were reached, so that a batch inside one can be refused.
+type ctxKeyBatch struct{}
+
+var argsBatch = marvin.NewArgParser("batch").
+	Flag("stop", "", marvin.ArgBool, "stop at the first command that does not succeed").
+	Rest("script", false)
+
+func (mod *AtCommandModule) CommandBatch(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	ctx := args.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Value(ctxKeyBatch{}) != nil {
+		return marvin.CmdFailuref(args, "A batch cannot run another batch.").WithSimpleUndo()
+	}
+	ctx = context.WithValue(ctx, ctxKeyBatch{}, true)
+
+	p, fail := argsBatch.Parse(t, args)
+	if fail != nil {
+		return *fail
+	}
+
+	script := strings.Join(p.Rest(), " ")
+	if script == "" {
+		script = mod.scriptFromMessage(args.Source)
+	}
+	lines := parseScript(script, t.BotUser())
+	if len(lines) == 0 {
+		return marvin.CmdUsage(args, "Usage: "+helpBatch).WithSimpleUndo()
+	} else if len(lines) > maxBatchLines {
+		return marvin.CmdFailuref(args, "A batch can have at most %d commands; this one has %d.", maxBatchLines, len(lines)).WithSimpleUndo()
+	}
+
+	var buf bytes.Buffer
+	failed := false
+	canUndo := true
+	stopped := -1
+	for i, line := range lines {
+		lineArgs := &marvin.CommandArguments{
+			Source:            args.Source,
+			Arguments:         line,
+			OriginalArguments: line,
+			Ctx:               ctx,
+		}
+		result := marvin.WaitCommand(t, t.DispatchCommand(lineArgs))
+		fmt.Fprintf(&buf, ":%s: `%s`", mod.GetEmojiForResponse(result), strings.Join(line, " "))
+		if result.Message != "" {
+			fmt.Fprintf(&buf, " %s", firstLine(result.Message))
+		}
+		if result.Code == marvin.CmdResultNeedsConfirm {
+			buf.WriteString(" _(needs confirmation; run it on its own)_")
+		}
+		if result.Err != nil {
+			fmt.Fprintf(&buf, ": %v", errors.Cause(result.Err))
+			util.LogError(result.Err)
+		}
+		buf.WriteByte('\n')
+
+		if ok, custom := result.Undoable(); !ok || custom {
+			canUndo = false
+		}
+		if result.Code != marvin.CmdResultOK {
+			failed = true
+			if p.Bool("stop") {
+				stopped = i
+				break
+			}
+		}
+	}
+	if stopped >= 0 && stopped+1 < len(lines) {
+		fmt.Fprintf(&buf, "Stopped; skipped %d more commands.\n", len(lines)-stopped-1)
+	}
+
+	// Errors are reported per line, so the batch is at worst a failure
+	result := marvin.CmdSuccess(args, strings.TrimSuffix(buf.String(), "\n"))
+	if failed {
+		result.Code = marvin.CmdResultFailure
+	}
+	result = result.WithReplyType(marvin.ReplyTypeInChannel)
+	if canUndo {
+		return result.WithSimpleUndo().WithNoEdit()
+	}
+	return result.WithNoUndo().WithNoEdit()
+}
+
+// scriptFromMessage returns the lines after the batch command, or the code
+// blocks of the message if it has any.
+func (mod *AtCommandModule) scriptFromMessage(source marvin.ActionSource) string {
+	um, ok := source.(marvin.ActionSourceUserMessage)
+	if !ok {
+		return ""
+	}
+	text := um.Msg.Text()
+	if blocks := rgxCodeBlock.FindAllStringSubmatch(text, -1); len(blocks) > 0 {
+		var parts []string
+		for _, v := range blocks {
+			parts = append(parts, v[1])
+		}
+		return strings.Join(parts, "\n")
+	}
+
+	mod.rgxLock.RLock()
+	m := mod.mentionRgx2.FindStringIndex(text)
+	mod.rgxLock.RUnlock()
+	start := 0
+	if m != nil {
+		start = m[1]
+	}
+	end := strings.IndexByte(text[start:], '\n')
+	if end == -1 {
+		return ""
+	}
+	return text[start+end+1:]
+}
+
+// parseScript splits a batch script into command lines, skipping blank lines
+// and comments, and a leading mention of the bot on each line.
+func parseScript(script string, bot slack.UserID) [][]string {
+	var lines [][]string
+	mention := fmt.Sprintf("<@%s>", bot)
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, mention))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		argSplit, _ := ParseArgs(line, 0)
+		lines = append(lines, argSplit)
+	}
+	return lines
+}
+
+func firstLine(msg string) string {
+	if i := strings.IndexByte(msg, '\n'); i != -1 {
+		return msg[:i] + " …"
+	}
+	return msg
+}
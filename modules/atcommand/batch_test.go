@@ -0,0 +1,59 @@
+package atcommand
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+)
+
+const testBot slack.UserID = "U0000BOT0"
+
+func TestParseScript(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		expect [][]string
+	}{
+		{"empty", "", nil},
+		{"one line", "factoid get hello", [][]string{{"factoid", "get", "hello"}}},
+		{"blank lines", "\n  \nwhoami\n\n\t\nwhereami\n", [][]string{{"whoami"}, {"whereami"}}},
+		{"comments", "# set up\nwhoami\n  # indented comment\n", [][]string{{"whoami"}}},
+		{"mention", "<@U0000BOT0> whoami\n  <@U0000BOT0>   whereami", [][]string{{"whoami"}, {"whereami"}}},
+		{"mention only", "<@U0000BOT0>\n<@U0000BOT0> # comment", nil},
+		{"other mention", "<@U12345678> whoami", [][]string{{"<@U12345678>", "whoami"}}},
+	}
+	for _, c := range cases {
+		if got := parseScript(c.script, testBot); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.expect)
+		}
+	}
+}
+
+func TestScriptFromMessage(t *testing.T) {
+	mod := &AtCommandModule{
+		mentionRgx2: regexp.MustCompile(fmt.Sprintf(`(?m:(?:\n|^)\s*(<@%s>)\s+())`, testBot)),
+	}
+	cases := []struct {
+		name   string
+		text   string
+		expect string
+	}{
+		{"after mention", "<@U0000BOT0> batch\nwhoami\nwhereami", "whoami\nwhereami"},
+		{"text before mention", "please run these\n<@U0000BOT0> batch --stop\nwhoami", "whoami"},
+		{"no following lines", "<@U0000BOT0> batch", ""},
+		{"code block", "<@U0000BOT0> batch\n```\nwhoami\nwhereami\n```", "whoami\nwhereami"},
+		{"two code blocks", "<@U0000BOT0> batch\n```\nwhoami\n```\nand\n```\nwhereami\n```", "whoami\nwhereami"},
+	}
+	for _, c := range cases {
+		source := marvin.ActionSourceUserMessage{Msg: slack.RTMRawMessage{
+			"type": "message", "channel": "C0000GEN0", "user": "U12345678", "text": c.text, "ts": "1.000001",
+		}}
+		if got := mod.scriptFromMessage(source); got != c.expect {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.expect)
+		}
+	}
+}
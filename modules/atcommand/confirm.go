@@ -0,0 +1,206 @@
+package atcommand
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/on_reaction"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+// confirmTimeout is how long a confirmation prompt waits for an answer.
+const confirmTimeout = 2 * time.Minute
+
+const (
+	emojiConfirmYes = "white_check_mark"
+	emojiConfirmNo  = "x"
+)
+
+// pendingConfirm is a command waiting on a confirmation prompt.
+type pendingConfirm struct {
+	fci     *FinishedCommandInfo
+	source  marvin.ActionSource
+	rtm     slack.SlackTextMessage
+	summary string
+}
+
+func (mod *AtCommandModule) onReactAPI() on_reaction.API {
+	if mod.onReact != nil {
+		return mod.onReact.(on_reaction.API)
+	}
+	return nil
+}
+
+// askConfirm posts the confirmation prompt for a CmdResultNeedsConfirm
+// result. It returns false if the prompt could not be set up.
+func (mod *AtCommandModule) askConfirm(fciResult *FinishedCommandInfo, source marvin.ActionSource, rtm slack.SlackTextMessage, result marvin.CommandResult) bool {
+	onReact := mod.onReactAPI()
+	if onReact == nil {
+		return false
+	}
+
+	text := fmt.Sprintf("%v: %s\nReact with :%s: to go ahead or :%s: to cancel. This expires in %v.",
+		rtm.UserID(), result.Message, emojiConfirmYes, emojiConfirmNo, confirmTimeout)
+	ts, err := mod.sendReply(rtm.ChannelID(), replyThread(rtm, result.ReplyType), result.ReplyType, SanitizeForChannel(text))
+	if err != nil {
+		util.LogError(err)
+		return false
+	}
+	prompt := ReplyActionSentMessage{MessageID: slack.MsgID(rtm.ChannelID(), ts), Text: text}
+	err = onReact.ListenMessage(prompt.MessageID, Identifier, nil)
+	if err != nil {
+		util.LogError(err)
+		prompt.Update(mod, "(removed)")
+		return false
+	}
+	fciResult.ActionChanMsg = prompt
+	go func() {
+		mod.team.ReactMessage(prompt.MessageID, emojiConfirmYes)
+		mod.team.ReactMessage(prompt.MessageID, emojiConfirmNo)
+	}()
+
+	confirmEmoji := mod.GetEmojiForResponse(result)
+	go mod.team.ReactMessage(rtm.MessageID(), confirmEmoji)
+	fciResult.AddEmojiReaction(rtm.MessageID(), confirmEmoji)
+
+	mod.confirmLock.Lock()
+	mod.pendingConfirms[prompt.MessageID] = &pendingConfirm{
+		fci:     fciResult,
+		source:  source,
+		rtm:     rtm,
+		summary: result.Message,
+	}
+	mod.confirmLock.Unlock()
+	time.AfterFunc(confirmTimeout, func() {
+		mod.closeConfirm(mod.takeConfirm(prompt.MessageID), "timed out")
+	})
+	return true
+}
+
+func (mod *AtCommandModule) takeConfirm(promptID slack.MessageID) *pendingConfirm {
+	mod.confirmLock.Lock()
+	defer mod.confirmLock.Unlock()
+	pc := mod.pendingConfirms[promptID]
+	delete(mod.pendingConfirms, promptID)
+	return pc
+}
+
+// onConfirmReaction answers a prompt when the user who ran the command
+// reacts to it. Reactions from anyone else, including the bot's own, are
+// ignored.
+func (mod *AtCommandModule) onConfirmReaction(event *on_reaction.ReactionEvent, _ []byte) error {
+	if !event.IsAdded {
+		return nil
+	}
+	var yes bool
+	switch event.EmojiName {
+	case emojiConfirmYes, "heavy_check_mark":
+		yes = true
+	case emojiConfirmNo:
+		yes = false
+	default:
+		return nil
+	}
+
+	mod.confirmLock.Lock()
+	pc, ok := mod.pendingConfirms[event.MessageID]
+	if !ok || pc.source.UserID() != event.UserID {
+		mod.confirmLock.Unlock()
+		return nil
+	}
+	delete(mod.pendingConfirms, event.MessageID)
+	mod.confirmLock.Unlock()
+
+	if yes {
+		go mod.runConfirmed(pc)
+	} else {
+		mod.closeConfirm(pc, "cancelled")
+	}
+	return nil
+}
+
+// closeConfirm marks a prompt as answered without running the command.
+func (mod *AtCommandModule) closeConfirm(pc *pendingConfirm, why string) {
+	if pc == nil {
+		return
+	}
+	pc.fci.Lock.Lock()
+	defer pc.fci.Lock.Unlock()
+	mod.closeConfirmLocked(pc, why)
+}
+
+func (mod *AtCommandModule) closeConfirmLocked(pc *pendingConfirm, why string) {
+	pc.fci.ActionChanMsg.Update(mod, SanitizeForChannel(fmt.Sprintf("%v: ~%s~ _(%s)_", pc.rtm.UserID(), pc.summary, why)))
+	pc.fci.ChangeEmoji(mod, nil)
+}
+
+// dropConfirms closes the prompts of a command so they can no longer run
+// it. The caller must hold fciMeta.Lock.
+func (mod *AtCommandModule) dropConfirms(fciMeta *FinishedCommandInfo, why string) {
+	var dropped []*pendingConfirm
+	mod.confirmLock.Lock()
+	for k, pc := range mod.pendingConfirms {
+		if pc.fci == fciMeta {
+			dropped = append(dropped, pc)
+			delete(mod.pendingConfirms, k)
+		}
+	}
+	mod.confirmLock.Unlock()
+	for _, pc := range dropped {
+		mod.closeConfirmLocked(pc, why)
+	}
+}
+
+// confirmUnavailable replaces a CmdResultNeedsConfirm result when the
+// prompt cannot be posted.
+func confirmUnavailable(result marvin.CommandResult) marvin.CommandResult {
+	return marvin.CmdFailuref(result.Args, "%s\nThat needs confirmation, which is not available right now.", result.Message)
+}
+
+// runConfirmed runs a command again now that the user has agreed to it. The
+// ModuleData of the waiting result is passed on, so that a pipeline resumes
+// at the stage that asked.
+func (mod *AtCommandModule) runConfirmed(pc *pendingConfirm) {
+	fci := pc.fci
+	fci.Lock.Lock()
+	defer fci.Lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	args := &marvin.CommandArguments{
+		OriginalArguments: fci.CommandArgs.OriginalArguments,
+		Arguments:         fci.CommandArgs.OriginalArguments,
+		Source:            pc.source,
+		Ctx:               ctx,
+		Confirmed:         true,
+	}
+	if fci.CommandResult.Args != nil {
+		args.ModuleData = fci.CommandResult.Args.ModuleData
+	}
+	fci.CommandArgs = args
+	result := mod.team.DispatchCommand(args)
+	fci.CommandResult = result
+
+	if result.Code == marvin.CmdResultInProgress || result.Code == marvin.CmdResultNeedsConfirm {
+		fci.ActionChanMsg.Update(mod, SanitizeForChannel(fmt.Sprintf("%v: %s _(confirmed)_", pc.rtm.UserID(), pc.summary)))
+		fci.ActionChanMsg = ReplyActionSentMessage{}
+		fci.ChangeEmoji(mod, nil)
+		if result.Code == marvin.CmdResultInProgress {
+			mod.startAsync(fci, pc.source, pc.rtm, result)
+			return
+		}
+		// A later pipeline stage wants its own confirmation
+		if mod.askConfirm(fci, pc.source, pc.rtm, result) {
+			return
+		}
+		result = confirmUnavailable(result)
+		fci.CommandResult = result
+	}
+	fci.ChangeEmoji(mod, []ReplyActionEmoji{
+		{MessageID: fci.OriginalMsg.MessageID(), Emoji: mod.GetEmojiForResponse(result)},
+	})
+	mod.updateReplies(fci, pc.source, result)
+}
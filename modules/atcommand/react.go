@@ -0,0 +1,89 @@
+package atcommand
+
+import (
+	"strings"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/antiflood"
+	"github.com/riking/marvin/slack"
+)
+
+// HandleReaction undoes or re-runs a command when its author reacts to the
+// command message, as an alternative to deleting or editing it.
+func (mod *AtCommandModule) HandleReaction(rtm slack.RTMRawMessage) {
+	var msg struct {
+		User       slack.UserID `json:"user"`
+		TargetUser slack.UserID `json:"item_user"`
+		Item       struct {
+			Type    string          `json:"type"`
+			Channel slack.ChannelID `json:"channel"`
+			TS      slack.MessageTS `json:"ts"`
+		}
+	}
+	rtm.ReMarshal(&msg)
+	if msg.Item.Type != "message" || msg.User != msg.TargetUser || msg.User == mod.team.BotUser() {
+		return
+	}
+
+	conf := mod.team.ModuleConfig(Identifier)
+	undoEmoji, _ := conf.GetForChannel(confKeyEmojiUndo, msg.Item.Channel)
+	rerunEmoji, _ := conf.GetForChannel(confKeyEmojiRerun, msg.Item.Channel)
+	reaction := rtm.StringField("reaction")
+	if reaction != undoEmoji && reaction != rerunEmoji {
+		return
+	}
+
+	mod.recentCommandsLock.Lock()
+	fciMeta, ok := mod.recentCommands[slack.MsgID(msg.Item.Channel, msg.Item.TS)]
+	mod.recentCommandsLock.Unlock()
+	if !ok {
+		return
+	}
+
+	fciMeta.Lock.Lock()
+	defer fciMeta.Lock.Unlock()
+	if !fciMeta.FoundCommand || fciMeta.RunningCommand != 0 || fciMeta.OriginalMsg.UserID() != msg.User {
+		return
+	}
+	if mod.team.UserLevel(msg.User) < marvin.AccessLevelNormal {
+		return
+	}
+
+	source := marvin.ActionSourceUserMessage{Team: mod.team, Msg: fciMeta.OriginalMsg}
+	if fciMeta.CommandResult.Code == marvin.CmdResultNeedsConfirm {
+		// Nothing has run yet; undo withdraws the prompt
+		if reaction == undoEmoji {
+			mod.dropConfirms(fciMeta, "withdrawn")
+		}
+		return
+	}
+	if reaction == undoEmoji {
+		mod.UndoCommand(fciMeta, source)
+		return
+	}
+
+	argSplit := fciMeta.parseResult.argSplit
+	flood := mod.team.GetModule(antiflood.Identifier).(antiflood.API)
+	if len(argSplit) > 0 &&
+		!flood.CheckUser(msg.User, msg.Item.Channel, "command:"+strings.ToLower(argSplit[0])) {
+		flood.Throttled(fciMeta.OriginalMsg.MessageID())
+		return
+	}
+	mod.rerunCommand(fciMeta, source)
+}
+
+// rerunCommand runs the command again, in place of its earlier result. A
+// command that cannot be edited but has a simple undo is undone and then run
+// as if new.
+func (mod *AtCommandModule) rerunCommand(fciMeta *FinishedCommandInfo, source marvin.ActionSource) {
+	if !mod.canEdit(fciMeta) {
+		if canUndo, custom := mod.canUndo(fciMeta); canUndo && !custom {
+			mod.UndoCommand(fciMeta, source)
+			fciMeta.ActionChanMsg = ReplyActionSentMessage{}
+			fciMeta.ActionPMMsg = ReplyActionSentMessage{}
+			mod.ProcessInitialCommandMessage(fciMeta, fciMeta.OriginalMsg)
+			return
+		}
+	}
+	mod.EditCommand(fciMeta, source)
+}
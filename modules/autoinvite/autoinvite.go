@@ -21,6 +21,8 @@ func init() {
 
 const Identifier = "autoinvite"
 
+var PermInvite = marvin.Permission{Name: "invite", Level: marvin.AccessLevelController}
+
 type AutoInviteModule struct {
 	team marvin.Team
 
@@ -53,9 +55,9 @@ func (mod *AutoInviteModule) Load(t marvin.Team) {
 func (mod *AutoInviteModule) Enable(t marvin.Team) {
 	mod.onReactAPI().RegisterHandler(mod, Identifier)
 	mod.team.OnEvent(Identifier, "reaction_added", mod.OnRawReaction)
-	t.RegisterCommandFunc("make-invite", mod.PostInvite, inviteHelp)
-	t.RegisterCommandFunc("revoke-invite", mod.CmdRevokeInvite, revokeHelp)
-	t.RegisterCommandFunc("mass-invite", CmdMassInvite, usageMass)
+	t.RegisterCommandFuncPerm("make-invite", mod.PostInvite, inviteHelp, PermInvite)
+	t.RegisterCommandFuncPerm("revoke-invite", mod.CmdRevokeInvite, revokeHelp, PermInvite)
+	t.RegisterCommandFuncPerm("mass-invite", CmdMassInvite, usageMass, PermInvite)
 	mod.registerHTTP()
 }
 
@@ -246,10 +248,6 @@ func (mod *AutoInviteModule) PostInvite(t marvin.Team, args *marvin.CommandArgum
 	}
 	util.LogDebug("PostInvite", args.Arguments)
 
-	if args.Source.AccessLevel() < marvin.AccessLevelController {
-		return marvin.CmdFailuref(args, "This command has been restricted to bot controller only.")
-	}
-
 	if len(args.Arguments) < 1 {
 		return marvin.CmdUsage(args, inviteHelp)
 	}
@@ -417,10 +415,10 @@ func (mod *AutoInviteModule) CmdRevokeInvite(t marvin.Team, args *marvin.Command
 	if mod.team.TeamConfig().IsReadOnly && args.Source.AccessLevel() < marvin.AccessLevelAdmin {
 		return marvin.CmdFailuref(args, "Marvin is currently on read only.")
 	}
-
-	if args.Source.AccessLevel() < marvin.AccessLevelController {
-		return marvin.CmdFailuref(args, "This command has been restricted to bot controller only.")
+	if !args.Confirmed {
+		return marvin.CmdConfirm(args, fmt.Sprintf("This will revoke every invite posted in %s.", t.ChannelName(args.Source.ChannelID())))
 	}
+
 	stmt, err := mod.team.DB().Prepare(sqlRevokeInvite)
 	if err != nil {
 		return marvin.CmdError(args, err, "database error")
@@ -1,6 +1,7 @@
 package autoinvite
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"sync"
@@ -14,9 +15,6 @@ const usageMass = "*`@marvin mass-invite`* will invite multiple people to a chan
 	"Use the command from the channel you want to invite users to."
 
 func CmdMassInvite(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
-	if args.Source.AccessLevel() < marvin.AccessLevelController {
-		return marvin.CmdFailuref(args, "This command has been restricted to bot controller only.")
-	}
 	if len(args.Arguments) == 0 {
 		return marvin.CmdUsage(args, usageMass).WithSimpleUndo()
 	}
@@ -37,7 +35,16 @@ func CmdMassInvite(t marvin.Team, args *marvin.CommandArguments) marvin.CommandR
 		}
 		userIDs = append(userIDs, uid)
 	}
+	if !args.Confirmed {
+		return marvin.CmdConfirm(args, fmt.Sprintf("This will invite %d users to %s.", len(userIDs), t.ChannelName(args.Source.ChannelID())))
+	}
+
+	return marvin.CmdAsync(args, fmt.Sprintf("Inviting %d users...", len(userIDs)), func(ctx context.Context, progress marvin.ProgressFunc) marvin.CommandResult {
+		return massInvite(ctx, t, args, method, userIDs, progress)
+	})
+}
 
+func massInvite(ctx context.Context, t marvin.Team, args *marvin.CommandArguments, method string, userIDs []slack.UserID, progress marvin.ProgressFunc) marvin.CommandResult {
 	workers := 3
 	if workers > len(userIDs)/2 {
 		workers = (len(userIDs) / 2) + 1
@@ -78,12 +85,16 @@ func CmdMassInvite(t marvin.Team, args *marvin.CommandArguments) marvin.CommandR
 	}
 
 	var firstErr error
-	for _, v := range userIDs {
+send:
+	for i, v := range userIDs {
 		select {
 		case ch <- v:
-			// ok
+			progress(fmt.Sprintf("Inviting users... %d of %d", i+1, len(userIDs)))
 		case firstErr = <-errCh:
-			break
+			break send
+		case <-ctx.Done():
+			firstErr = ctx.Err()
+			break send
 		}
 	}
 	close(ch)
@@ -93,6 +104,12 @@ func CmdMassInvite(t marvin.Team, args *marvin.CommandArguments) marvin.CommandR
 	for i := range counts {
 		total += counts[i]
 	}
+	t.Audit(args.Source, marvin.AuditEntry{
+		Module: Identifier,
+		Action: "mass-invite",
+		Target: string(args.Source.ChannelID()),
+		After:  fmt.Sprintf("%d of %d users invited", total, len(userIDs)),
+	})
 	if firstErr != nil {
 		return marvin.CmdFailuref(args, "Error while inviting: %s\n%d users invited before the error.", firstErr, total)
 	}
@@ -2,7 +2,6 @@ package awake
 
 import (
 	"net/url"
-	"time"
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/util"
@@ -14,10 +13,10 @@ func init() {
 
 const Identifier = "awake"
 
+const jobSetActive = "set-active"
+
 type AwakeModule struct {
-	team   marvin.Team
-	quit   chan struct{}
-	ticker *time.Ticker
+	team marvin.Team
 }
 
 func NewAwakeModule(t marvin.Team) marvin.Module {
@@ -33,38 +32,13 @@ func (mod *AwakeModule) Load(t marvin.Team) {
 }
 
 func (mod *AwakeModule) Enable(t marvin.Team) {
-	mod.ticker = time.NewTicker(20 * time.Minute)
-	mod.quit = make(chan struct{})
-	go mod.onTick()
+	t.RegisterJobHandler(Identifier, jobSetActive, mod.setActive)
+	util.LogIfError(t.ScheduleRecurringJob(Identifier, jobSetActive, "@every 20m", nil))
 }
 
 func (mod *AwakeModule) Disable(t marvin.Team) {
-	mod.ticker.Stop()
-	close(mod.quit)
-
 }
 
-func (mod *AwakeModule) onTick() {
-	var retryChan <-chan time.Time
-
-	run := func() {
-		err := mod.team.SlackAPIPostJSON("users.setActive", url.Values{}, nil)
-		if err != nil {
-			util.LogError(err)
-			retryChan = time.After(5 * time.Minute)
-		} else {
-			retryChan = nil
-		}
-	}
-
-	for {
-		select {
-		case <-mod.ticker.C:
-			run()
-		case <-retryChan:
-			run()
-		case <-mod.quit:
-			return
-		}
-	}
+func (mod *AwakeModule) setActive(t marvin.Team, job *marvin.Job) error {
+	return t.SlackAPIPostJSON("users.setActive", url.Values{}, nil)
 }
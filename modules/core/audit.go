@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+)
+
+const helpAudit = "`audit [--limit n] [--action name] [@user|module]` shows recent privileged actions, optionally only those by one user or affecting one module.\n" +
+	"\tThe full log can be browsed on the web at /audit."
+
+var argsAudit = marvin.NewArgParser("audit").
+	Flag("limit", "n", marvin.ArgInt, "show this many entries (default 10)").
+	Flag("action", "", marvin.ArgString, "only show this action, e.g. `config.set`").
+	OptionalArg("user|module", marvin.ArgString)
+
+const (
+	defaultAuditShow = 10
+	maxAuditShow     = 50
+)
+
+func (mod *DebugModule) registerAuditCommand(t marvin.Team) {
+	t.RegisterCommandFuncPerm("audit", mod.CommandAudit, helpAudit, marvin.PermAuditView)
+}
+
+func (mod *DebugModule) CommandAudit(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	p, fail := argsAudit.Parse(t, args)
+	if fail != nil {
+		return *fail
+	}
+
+	filter := marvin.AuditFilter{
+		Action: p.String("action"),
+		Limit:  defaultAuditShow,
+	}
+	if p.Has("limit") {
+		filter.Limit = int(p.Int("limit"))
+		if filter.Limit < 1 || filter.Limit > maxAuditShow {
+			return marvin.CmdFailuref(args, "--limit must be between 1 and %d", maxAuditShow).WithSimpleUndo()
+		}
+	}
+	if who := p.String("user|module"); who != "" {
+		if t.GetModuleStatus(marvin.ModuleID(who)) != nil {
+			filter.Module = marvin.ModuleID(who)
+		} else if user := t.ResolveUserName(who); user != "" {
+			filter.Actor = user
+		} else {
+			return marvin.CmdFailuref(args, "'%s' is not a module or a user", who).WithSimpleUndo()
+		}
+	}
+
+	entries, err := t.ListAudit(filter)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	if len(entries) == 0 {
+		return marvin.CmdSuccess(args, "No matching audit log entries.").WithSimpleUndo()
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "`%s` %s `%s`", e.Time.Format("2006-01-02 15:04"), auditActorName(t, e.Actor), e.Action)
+		if e.Target != "" {
+			fmt.Fprintf(&buf, " %s", e.Target)
+		}
+		switch {
+		case e.Before != "" && e.After != "":
+			fmt.Fprintf(&buf, ": `%s` → `%s`", e.Before, e.After)
+		case e.Before != "":
+			fmt.Fprintf(&buf, ": was `%s`", e.Before)
+		case e.After != "":
+			fmt.Fprintf(&buf, ": `%s`", e.After)
+		}
+		buf.WriteByte('\n')
+	}
+	return marvin.CmdSuccess(args, strings.TrimSuffix(buf.String(), "\n")).WithSimpleUndo()
+}
+
+// auditActorName shows the name of a Slack user, or the raw actor for other
+// sources like web logins.
+func auditActorName(t marvin.Team, actor slack.UserID) string {
+	if actor == "" || strings.Contains(string(actor), ":") {
+		return string(actor)
+	}
+	return "@" + t.UserName(actor)
+}
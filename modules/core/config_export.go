@@ -0,0 +1,352 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/paste"
+	"github.com/riking/marvin/slack"
+)
+
+const (
+	helpExport = "`export [module]` uploads all configuration overrides, or those of one module, as a paste.\n" +
+		"\tProtected configuration values are never put in the paste; admins get them in the reply when exporting over DMs."
+	helpImport = "`import <paste-url|&1>` shows the changes a `config export` would make, and `import confirm` applies them.\n" +
+		"\tOnly the JSON format written by `config export` is accepted. Keys missing from the import are left alone."
+)
+
+// configExport is the document produced by `config export`. Channels are
+// stored by name, as IDs differ between teams.
+type configExport map[marvin.ModuleID]moduleExport
+
+type moduleExport struct {
+	Values   map[string]string            `json:"values,omitempty"`
+	Channels map[string]map[string]string `json:"channels,omitempty"`
+}
+
+// setValue adds a value to the export, for a channel name or globally if
+// channel is empty.
+func (me *moduleExport) setValue(channel, key, value string) {
+	if channel == "" {
+		if me.Values == nil {
+			me.Values = make(map[string]string)
+		}
+		me.Values[key] = value
+		return
+	}
+	if me.Channels == nil {
+		me.Channels = make(map[string]map[string]string)
+	}
+	if me.Channels[channel] == nil {
+		me.Channels[channel] = make(map[string]string)
+	}
+	me.Channels[channel][key] = value
+}
+
+type configChange struct {
+	Module  marvin.ModuleID
+	Key     string
+	Channel slack.ChannelID
+	Old     string
+	HaveOld bool
+	New     string
+}
+
+type pendingImport struct {
+	changes []configChange
+	expires time.Time
+}
+
+const pendingImportTimeout = 10 * time.Minute
+
+func (mod *DebugModule) pasteAPI() paste.API {
+	ms := mod.team.GetModuleStatus(paste.Identifier)
+	if ms == nil || !ms.IsEnabled() {
+		return nil
+	}
+	api, _ := ms.Instance().(paste.API)
+	return api
+}
+
+func (mod *DebugModule) CommandConfigExport(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	showProtected := args.Source.AccessLevel() >= marvin.AccessLevelAdmin && slack.IsDMChannel(args.Source.ChannelID())
+
+	var modules []marvin.ModuleID
+	switch len(args.Arguments) {
+	case 0:
+		modules = mod.team.ModuleConfigList()
+	case 1:
+		modules = []marvin.ModuleID{marvin.ModuleID(args.Arguments[0])}
+	default:
+		return marvin.CmdUsage(args, "Usage: `@marvin config export [module]`").WithSimpleUndo()
+	}
+
+	// Protected values never go in the paste, which anyone with the link can
+	// read. Admins get them in the DM reply instead.
+	export := make(configExport)
+	protected := make(configExport)
+	omitted := 0
+	for _, modID := range modules {
+		conf := mod.team.ModuleConfig(modID)
+		if conf == nil {
+			return marvin.CmdFailuref(args, "No such module `%s`", modID).WithSimpleUndo()
+		}
+		isProtected := func(key string) bool {
+			_, _, err := conf.GetIsDefaultNotProtected(key)
+			_, ok := err.(marvin.ErrConfProtected)
+			return ok
+		}
+
+		var me, prot moduleExport
+		overrides, err := conf.ListOverrides()
+		if err != nil {
+			return marvin.CmdError(args, err, "Database error")
+		}
+		for key, value := range overrides {
+			if !isProtected(key) {
+				me.setValue("", key, value)
+			} else if showProtected {
+				prot.setValue("", key, value)
+			} else {
+				omitted++
+			}
+		}
+		for key := range conf.ListDefaults() {
+			chOverrides, err := conf.ListChannelOverrides(key)
+			if err != nil {
+				return marvin.CmdError(args, err, "Database error")
+			}
+			keyProtected := isProtected(key)
+			if keyProtected && !showProtected {
+				omitted += len(chOverrides)
+				continue
+			}
+			for ch, value := range chOverrides {
+				name := "#" + mod.team.ChannelName(ch)
+				if keyProtected {
+					prot.setValue(name, key, value)
+				} else {
+					me.setValue(name, key, value)
+				}
+			}
+		}
+		if me.Values != nil || me.Channels != nil {
+			export[modID] = me
+		}
+		if prot.Values != nil || prot.Channels != nil {
+			protected[modID] = prot
+		}
+	}
+
+	b, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return marvin.CmdError(args, err, "Could not encode configuration")
+	}
+	pasteMod := mod.pasteAPI()
+	if pasteMod == nil {
+		return marvin.CmdFailuref(args, "The `paste` module is not enabled, so the export cannot be uploaded.").WithSimpleUndo()
+	}
+	id, err := pasteMod.CreatePaste(string(b))
+	if err != nil {
+		return marvin.CmdError(args, err, "Could not create paste")
+	}
+
+	msg := fmt.Sprintf("Configuration export: %s", pasteMod.URLForPaste(id))
+	if omitted > 0 {
+		msg += fmt.Sprintf("\n%d protected values were left out. Run the export in a DM to include them.", omitted)
+	}
+	if len(protected) > 0 {
+		b, err = json.MarshalIndent(protected, "", "  ")
+		if err != nil {
+			return marvin.CmdError(args, err, "Could not encode configuration")
+		}
+		msg += fmt.Sprintf("\nProtected values are not in the paste. Import them separately:\n```%s```", b)
+	}
+	return marvin.CmdSuccess(args, msg).WithNoUndo()
+}
+
+var rgxPasteURL = regexp.MustCompile(`^<?https?://\S+/p/([0-9a-z]+)(?:\|[^>]*)?>?$`)
+
+// readImport fetches the document given to `config import`.
+func (mod *DebugModule) readImport(input string) (configExport, error) {
+	if m := rgxPasteURL.FindStringSubmatch(input); m != nil {
+		pasteMod := mod.pasteAPI()
+		if pasteMod == nil {
+			return nil, errors.Errorf("the `paste` module is not enabled")
+		}
+		id, err := strconv.ParseInt(m[1], 36, 64)
+		if err != nil {
+			return nil, errors.Errorf("bad paste ID %s", m[1])
+		}
+		input, err = pasteMod.GetPaste(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load paste")
+		}
+	} else {
+		input = slack.UnescapeTextAll(input)
+	}
+
+	var doc configExport
+	err := json.Unmarshal([]byte(input), &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse import as JSON")
+	}
+	return doc, nil
+}
+
+// diffImport lists the changes needed to apply the document, checking each
+// value against the key's schema.
+func (mod *DebugModule) diffImport(doc configExport) ([]configChange, error) {
+	var changes []configChange
+	for modID, me := range doc {
+		conf := mod.team.ModuleConfig(modID)
+		if conf == nil {
+			return nil, errors.Errorf("no such module `%s`", modID)
+		}
+		for key, value := range me.Values {
+			err := conf.Validate(key, value)
+			if err != nil {
+				return nil, err
+			}
+			old, isDefault, _ := conf.GetIsDefault(key)
+			if !isDefault && old == value {
+				continue
+			}
+			changes = append(changes, configChange{
+				Module: modID, Key: key,
+				Old: old, HaveOld: !isDefault,
+				New: value,
+			})
+		}
+		for name, values := range me.Channels {
+			channel := mod.team.ResolveChannelName(name)
+			if channel == "" {
+				return nil, errors.Errorf("no such channel %s", name)
+			}
+			for key, value := range values {
+				err := conf.Validate(key, value)
+				if err != nil {
+					return nil, err
+				}
+				overrides, err := conf.ListChannelOverrides(key)
+				if err != nil {
+					return nil, err
+				}
+				old, haveOld := overrides[channel]
+				if haveOld && old == value {
+					continue
+				}
+				changes = append(changes, configChange{
+					Module: modID, Key: key, Channel: channel,
+					Old: old, HaveOld: haveOld,
+					New: value,
+				})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Module != changes[j].Module {
+			return changes[i].Module < changes[j].Module
+		}
+		if changes[i].Key != changes[j].Key {
+			return changes[i].Key < changes[j].Key
+		}
+		return changes[i].Channel < changes[j].Channel
+	})
+	return changes, nil
+}
+
+func (mod *DebugModule) CommandConfigImport(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) == 0 {
+		return marvin.CmdUsage(args, "Usage: `@marvin config import <paste-url|&1>`, then `@marvin config import confirm`").WithSimpleUndo()
+	}
+
+	switch args.Arguments[0] {
+	case "confirm":
+		return mod.confirmImport(args)
+	case "cancel":
+		mod.importLock.Lock()
+		delete(mod.pendingImports, args.Source.UserID())
+		mod.importLock.Unlock()
+		return marvin.CmdSuccess(args, "Import cancelled.").WithNoUndo()
+	}
+
+	doc, err := mod.readImport(strings.Join(args.Arguments, " "))
+	if err != nil {
+		return marvin.CmdFailuref(args, "%v", err).WithSimpleUndo()
+	}
+	changes, err := mod.diffImport(doc)
+	if invalid, ok := err.(marvin.ErrConfInvalid); ok {
+		return marvin.CmdFailuref(args, "`%s` %v", invalid.Key, invalid.Reason).WithSimpleUndo()
+	} else if err != nil {
+		return marvin.CmdFailuref(args, "%v", err).WithSimpleUndo()
+	}
+	if len(changes) == 0 {
+		return marvin.CmdSuccess(args, "Nothing to import; all values already match.").WithSimpleUndo()
+	}
+
+	mod.importLock.Lock()
+	mod.pendingImports[args.Source.UserID()] = pendingImport{
+		changes: changes,
+		expires: time.Now().Add(pendingImportTimeout),
+	}
+	mod.importLock.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString("The import will make these changes:\n")
+	showProtected := args.Source.AccessLevel() >= marvin.AccessLevelAdmin && slack.IsDMChannel(args.Source.ChannelID())
+	for _, v := range changes {
+		conf := mod.team.ModuleConfig(v.Module)
+		fmt.Fprintf(&buf, "`%s.%s`", v.Module, v.Key)
+		if v.Channel != "" {
+			fmt.Fprintf(&buf, " in %s", t.FormatChannel(v.Channel))
+		}
+		if _, _, err := conf.GetIsDefaultNotProtected(v.Key); !showProtected && err != nil {
+			buf.WriteString(": _(protected value changed)_\n")
+			continue
+		}
+		if v.HaveOld {
+			fmt.Fprintf(&buf, ": `%s` → `%s`\n", v.Old, v.New)
+		} else {
+			fmt.Fprintf(&buf, ": _(default)_ → `%s`\n", v.New)
+		}
+	}
+	fmt.Fprintf(&buf, "Run `@marvin config import confirm` within %v to apply them.", pendingImportTimeout)
+	return marvin.CmdSuccess(args, buf.String()).WithNoUndo()
+}
+
+func (mod *DebugModule) confirmImport(args *marvin.CommandArguments) marvin.CommandResult {
+	mod.importLock.Lock()
+	pending, ok := mod.pendingImports[args.Source.UserID()]
+	delete(mod.pendingImports, args.Source.UserID())
+	mod.importLock.Unlock()
+
+	if !ok || time.Now().After(pending.expires) {
+		return marvin.CmdFailuref(args, "You have no pending import. Run `@marvin config import <paste-url>` first.").WithSimpleUndo()
+	}
+	for i, v := range pending.changes {
+		conf := mod.team.ModuleConfig(v.Module)
+		if conf == nil {
+			return marvin.CmdFailuref(args, "Import failed after %d of %d changes: module `%s` went away", i, len(pending.changes), v.Module)
+		}
+		err := conf.SetForChannel(v.Key, v.Channel, v.New)
+		if err != nil {
+			return marvin.CmdError(args, err, fmt.Sprintf("Import failed after %d of %d changes", i, len(pending.changes)))
+		}
+		entry := marvin.AuditEntry{Module: v.Module, Action: "config.import", Target: auditConfigTarget(v.Module, v.Key, v.Channel), After: v.New}
+		if v.HaveOld {
+			entry.Before = v.Old
+		}
+		mod.team.Audit(args.Source, redactConfigAudit(conf, v.Key, entry))
+	}
+	return marvin.CmdSuccess(args, fmt.Sprintf("Imported %d configuration values.", len(pending.changes))).WithNoUndo()
+}
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/slack"
@@ -16,10 +19,16 @@ const Identifier = "core"
 
 type DebugModule struct {
 	team marvin.Team
+
+	importLock     sync.Mutex
+	pendingImports map[slack.UserID]pendingImport
 }
 
 func NewDebugModule(t marvin.Team) marvin.Module {
-	mod := &DebugModule{team: t}
+	mod := &DebugModule{
+		team:           t,
+		pendingImports: make(map[slack.UserID]pendingImport),
+	}
 	return mod
 }
 
@@ -31,26 +40,37 @@ func (mod *DebugModule) Load(t marvin.Team) {
 }
 
 const (
-	helpSet = "`set [module] [key] [value]` sets a module configuration value."
-	helpGet = "`get [module] [key]` shows module configuration values.\n" +
+	helpSet = "`set [--channel #channel] [module] [key] [value]` sets a module configuration value.\n" +
+		"\tWith `--channel`, the value only applies in that channel."
+	helpGet = "`get [--channel #channel] [module] [key]` shows module configuration values.\n" +
 		"\tProtected configuration values may only be viewed by admins over DMs."
-	helpList = "`list [module]` lists available module configuration values.\n" +
+	helpList = "`list [module]` lists available module configuration values, with their types.\n" +
 		"\tProtected configuration values are marked by a (*)."
 )
 
 func (mod *DebugModule) Enable(t marvin.Team) {
 	parent := marvin.NewParentCommand().WithHelp(
 		"The `config` command manipulates team-wide configuration. Most subcommands are restricted to admins.\n" +
-			helpSet + "\n" + helpGet + "\n" + helpList,
+			helpSet + "\n" + helpGet + "\n" + helpList + "\n" + helpExport + "\n" + helpImport,
 	)
-	parent.RegisterCommandFunc("set", mod.CommandConfigSet, helpSet)
+	parent.RegisterCommandFuncPerm("set", mod.CommandConfigSet, helpSet, PermConfigSet)
 	parent.RegisterCommandFunc("get", mod.CommandConfigGet, helpGet)
 	parent.RegisterCommandFunc("list", mod.CommandConfigList, helpList)
+	parent.RegisterCommandFunc("export", mod.CommandConfigExport, helpExport)
+	parent.RegisterCommandFuncPerm("import", mod.CommandConfigImport, helpImport, PermConfigImport)
 	t.RegisterCommand("config", parent)
+	mod.registerModuleCommand(t)
+	mod.registerPermCommand(t)
+	mod.registerAuditCommand(t)
+	mod.registerJobsCommand(t)
 }
 
 func (mod *DebugModule) Disable(t marvin.Team) {
 	t.UnregisterCommand("config")
+	t.UnregisterCommand("module")
+	t.UnregisterCommand("perm")
+	t.UnregisterCommand("audit")
+	t.UnregisterCommand("jobs")
 }
 
 // ---
@@ -76,27 +96,79 @@ func (mod *DebugModule) CommandConfigList(t marvin.Team, args *marvin.CommandArg
 		return marvin.CmdFailuref(args, "No such module `%s`", module).WithSimpleUndo()
 	}
 
-	var keyList []string
+	schemas := conf.ListSchemas()
+	keys := make([]string, 0, len(schemas))
+	for key := range schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Configuration values for %s:", module)
+	for _, key := range keys {
+		schema := schemas[key]
+		fmt.Fprintf(&buf, "\n`%s` _%s_", key, schema.Type)
+		if schema.Protected {
+			buf.WriteString(" (\\*)")
+		}
+		if schema.Type == marvin.ConfTypeEnum {
+			fmt.Fprintf(&buf, " [%s]", strings.Join(schema.Choices, ", "))
+		}
+		if schema.Description != "" {
+			fmt.Fprintf(&buf, " - %s", schema.Description)
+		}
+	}
+	return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+}
 
-	prot := conf.ListProtected()
-	for key := range conf.ListDefaults() {
-		isProt := ""
-		if prot[key] {
-			isProt = " (\\*)"
+// normalizeConfigValue converts user input, such as channel mentions, to the
+// stored form for the key's type.
+func (mod *DebugModule) normalizeConfigValue(conf marvin.ModuleConfig, key, value string) string {
+	switch conf.ListSchemas()[key].Type {
+	case marvin.ConfTypeChannel:
+		if ch := mod.team.ResolveChannelName(value); ch != "" {
+			return string(ch)
 		}
-		keyList = append(keyList, fmt.Sprintf(
-			"`%s`%s", key, isProt))
+	case marvin.ConfTypeUser:
+		if u := mod.team.ResolveUserName(value); u != "" {
+			return string(u)
+		}
+	case marvin.ConfTypeEmoji:
+		return strings.Trim(value, ":")
+	case marvin.ConfTypeBool:
+		return strings.ToLower(value)
 	}
+	return value
+}
 
-	return marvin.CmdSuccess(args, fmt.Sprintf("Configuration values for %s:\n%s", module, strings.Join(keyList, ", "))).WithSimpleUndo()
+// parseChannelFlag removes a leading `--channel #name` from the arguments.
+func (mod *DebugModule) parseChannelFlag(args *marvin.CommandArguments) (slack.ChannelID, *marvin.CommandResult) {
+	if len(args.Arguments) == 0 || args.Arguments[0] != "--channel" {
+		return "", nil
+	}
+	if len(args.Arguments) < 2 {
+		r := marvin.CmdUsage(args, "`--channel` must be followed by a channel name").WithSimpleUndo()
+		return "", &r
+	}
+	channel := mod.team.ResolveChannelName(args.Arguments[1])
+	if channel == "" {
+		r := marvin.CmdFailuref(args, "No such channel '%s'", args.Arguments[1]).WithSimpleUndo()
+		return "", &r
+	}
+	args.Arguments = args.Arguments[2:]
+	return channel, nil
 }
 
 func (mod *DebugModule) CommandConfigGet(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	channel, fail := mod.parseChannelFlag(args)
+	if fail != nil {
+		return *fail
+	}
 	switch len(args.Arguments) {
 	default:
 		fallthrough
 	case 0:
-		return marvin.CmdUsage(args, "Usage: `@marvin config get [module] [key]`")
+		return marvin.CmdUsage(args, "Usage: `@marvin config get [--channel #channel] [module] [key]`")
 	case 1:
 		return mod.CommandConfigList(t, args)
 	case 2:
@@ -105,14 +177,18 @@ func (mod *DebugModule) CommandConfigGet(t marvin.Team, args *marvin.CommandArgu
 
 	module := marvin.ModuleID(args.Arguments[0])
 	key := args.Arguments[1]
+	conf := mod.team.ModuleConfig(module)
+	if conf == nil {
+		return marvin.CmdFailuref(args, "No such module `%s`", module).WithSimpleUndo()
+	}
 
 	var val string
 	var isDefault bool
 	var err error
 	if args.Source.AccessLevel() >= marvin.AccessLevelAdmin && slack.IsDMChannel(args.Source.ChannelID()) {
-		val, isDefault, err = mod.team.ModuleConfig(module).GetIsDefault(key)
+		val, isDefault, err = conf.GetIsDefault(key)
 	} else {
-		val, isDefault, err = mod.team.ModuleConfig(module).GetIsDefaultNotProtected(key)
+		val, isDefault, err = conf.GetIsDefaultNotProtected(key)
 	}
 	if _, ok := err.(marvin.ErrConfProtected); ok {
 		return marvin.CmdFailuref(args, "`%s.%s` is a protected configuration value. Viewing is restricted to admin DMs.", module, key).WithSimpleUndo()
@@ -120,43 +196,111 @@ func (mod *DebugModule) CommandConfigGet(t marvin.Team, args *marvin.CommandArgu
 		return marvin.CmdFailuref(args, "`%s.%s` is not a configuration value.", module, key).WithSimpleUndo()
 	} else if err != nil {
 		return marvin.CmdError(args, err, "Database error").WithNoUndo()
-	} else if isDefault {
-		return marvin.CmdSuccess(args, fmt.Sprintf("%s _(default)_", val)).WithSimpleUndo()
+	}
+
+	overrides, err := conf.ListChannelOverrides(key)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error").WithNoUndo()
+	}
+	if channel != "" {
+		if chVal, ok := overrides[channel]; ok {
+			return marvin.CmdSuccess(args, fmt.Sprintf("%s _(in %s)_", chVal, t.FormatChannel(channel))).WithSimpleUndo()
+		}
+	}
+
+	if isDefault {
+		val = fmt.Sprintf("%s _(default)_", val)
+	}
+	if channel == "" && len(overrides) > 0 {
+		var buf bytes.Buffer
+		buf.WriteString(val)
+		buf.WriteString("\nChannel overrides:")
+		for ch, chVal := range overrides {
+			fmt.Fprintf(&buf, "\n%s: %s", t.FormatChannel(ch), chVal)
+		}
+		val = buf.String()
 	}
 	return marvin.CmdSuccess(args, val).WithSimpleUndo()
 }
 
+// auditConfigTarget names a configuration key in the audit log.
+func auditConfigTarget(module marvin.ModuleID, key string, channel slack.ChannelID) string {
+	if channel != "" {
+		return fmt.Sprintf("%s.%s in %s", module, key, channel)
+	}
+	return fmt.Sprintf("%s.%s", module, key)
+}
+
+// currentConfigValue returns the value of the key, or of its override in the
+// channel, without requiring the key to have a default.
+func currentConfigValue(conf marvin.ModuleConfig, key string, channel slack.ChannelID) string {
+	if channel != "" {
+		overrides, _ := conf.ListChannelOverrides(key)
+		return overrides[channel]
+	}
+	value, _, _ := conf.GetIsDefault(key)
+	return value
+}
+
+// redactConfigAudit keeps protected values out of the audit log.
+func redactConfigAudit(conf marvin.ModuleConfig, key string, entry marvin.AuditEntry) marvin.AuditEntry {
+	if _, _, err := conf.GetIsDefaultNotProtected(key); err != nil {
+		if _, ok := err.(marvin.ErrConfProtected); !ok {
+			return entry
+		}
+		entry.Before = marvin.AuditRedacted
+		entry.After = marvin.AuditRedacted
+	}
+	return entry
+}
+
 func (mod *DebugModule) CommandConfigSet(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	channel, fail := mod.parseChannelFlag(args)
+	if fail != nil {
+		return *fail
+	}
 	switch len(args.Arguments) {
 	default:
 		fallthrough
 	case 0, 1:
-		return marvin.CmdUsage(args, "Usage: `@marvin config set {module} {key} [value]`\nIf a value is not specified, the key will be reset to default.").WithSimpleUndo()
+		return marvin.CmdUsage(args, "Usage: `@marvin config set [--channel #channel] {module} {key} [value]`\nIf a value is not specified, the key will be reset to default.").WithSimpleUndo()
 	case 2, 3:
 		break
 	}
-	if args.Source.AccessLevel() < marvin.AccessLevelAdmin {
-		return marvin.CmdFailuref(args, "Sorry, %v, I can't let you do that. `config set` is restricted to admins.", args.Source.UserID()).WithSimpleUndo()
-	}
 
 	module := marvin.ModuleID(args.Arguments[0])
 	key := args.Arguments[1]
 
 	conf := mod.team.ModuleConfig(module)
+	if conf == nil {
+		return marvin.CmdFailuref(args, "'%s' is not a valid module name", module).WithSimpleUndo()
+	}
+	entry := marvin.AuditEntry{Module: module, Action: "config.set", Target: auditConfigTarget(module, key, channel)}
+	entry.Before = currentConfigValue(conf, key, channel)
 	if len(args.Arguments) == 3 {
-		value := args.Arguments[2]
-		err := conf.Set(key, value)
-		if err != nil {
+		value := mod.normalizeConfigValue(conf, key, args.Arguments[2])
+		err := conf.SetForChannel(key, channel, value)
+		if invalid, ok := err.(marvin.ErrConfInvalid); ok {
+			return marvin.CmdFailuref(args, "`%s` %v", invalid.Key, invalid.Reason).WithSimpleUndo()
+		} else if err != nil {
 			return marvin.CmdError(args, err, "Database error")
 		}
+		entry.After = value
+		t.Audit(args.Source, redactConfigAudit(conf, key, entry))
+		if channel != "" {
+			return marvin.CmdSuccess(args, fmt.Sprintf("Configuration value set for %s", t.FormatChannel(channel))).WithNoUndo()
+		}
 		return marvin.CmdSuccess(args, "Configuration value set").WithNoUndo()
-	} else if conf == nil {
-		return marvin.CmdFailuref(args, "'%s' is not a valid module name", module).WithSimpleUndo()
 	} else {
-		err := conf.SetDefault(key)
+		err := conf.SetDefaultForChannel(key, channel)
 		if err != nil {
 			return marvin.CmdError(args, err, "Database error")
 		}
+		entry.After = currentConfigValue(conf, key, channel)
+		t.Audit(args.Source, redactConfigAudit(conf, key, entry))
+		if channel != "" {
+			return marvin.CmdSuccess(args, fmt.Sprintf("Configuration override for %s removed", t.FormatChannel(channel))).WithNoUndo()
+		}
 		return marvin.CmdSuccess(args, "Configuration value reset to default").WithNoUndo()
 	}
 }
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/riking/marvin"
+)
+
+var PermJobsView = marvin.Permission{Name: "jobs.view", Level: marvin.AccessLevelAdmin}
+
+const helpJobs = "`jobs [module]` lists scheduled jobs, with when they next run and their last error, and commands that are still running."
+
+func (mod *DebugModule) registerJobsCommand(t marvin.Team) {
+	t.RegisterCommandFuncPerm("jobs", mod.CommandJobs, helpJobs, PermJobsView)
+}
+
+func (mod *DebugModule) CommandJobs(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	var modID marvin.ModuleID
+	switch len(args.Arguments) {
+	case 0:
+	case 1:
+		modID = marvin.ModuleID(args.Arguments[0])
+		if t.GetModuleStatus(modID) == nil {
+			return marvin.CmdFailuref(args, "No such module `%s`.", modID).WithSimpleUndo()
+		}
+	default:
+		return marvin.CmdUsage(args, "Usage: "+helpJobs).WithSimpleUndo()
+	}
+
+	jobs, err := t.ListJobs(modID)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	var running []marvin.RunningCommand
+	if modID == "" {
+		running = t.RunningCommands()
+	}
+	if len(jobs) == 0 && len(running) == 0 {
+		return marvin.CmdSuccess(args, "No jobs are scheduled.").WithSimpleUndo()
+	}
+
+	var buf bytes.Buffer
+	now := time.Now()
+	for _, c := range running {
+		fmt.Fprintf(&buf, "Command `%d` `%s` by @%s in %s, running for %v: %s\n",
+			c.ID, c.Command, t.UserName(c.User), t.ChannelName(c.Channel),
+			now.Sub(c.Started).Round(time.Second), c.Progress)
+	}
+	for _, j := range jobs {
+		fmt.Fprintf(&buf, "`%d` `%s/%s`", j.ID, j.Module, j.Name)
+		if j.Schedule != "" {
+			fmt.Fprintf(&buf, " (`%s`)", j.Schedule)
+		}
+		switch {
+		case j.Failed:
+			buf.WriteString(" *failed*")
+		case j.Running:
+			buf.WriteString(" running now")
+		case j.RunAt.Before(now):
+			fmt.Fprintf(&buf, " due %v ago", now.Sub(j.RunAt).Round(time.Second))
+		default:
+			fmt.Fprintf(&buf, " in %v", j.RunAt.Sub(now).Round(time.Second))
+		}
+		if j.Attempts > 0 {
+			fmt.Fprintf(&buf, ", %d attempts", j.Attempts)
+		}
+		if j.LastError != "" {
+			fmt.Fprintf(&buf, ", last error: `%s`", j.LastError)
+		}
+		buf.WriteByte('\n')
+	}
+	return marvin.CmdSuccess(args, strings.TrimSuffix(buf.String(), "\n")).WithSimpleUndo()
+}
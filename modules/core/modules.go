@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/atcommand"
+)
+
+const (
+	helpModuleList    = "`module list` lists all modules and their current state."
+	helpModuleStatus  = "`module status <module>` shows the state, error and dependencies of a module."
+	helpModuleEnable  = "`module enable <module>` enables a module and keeps it enabled across restarts."
+	helpModuleDisable = "`module disable <module>` disables a module, and any modules that depend on it, and keeps it disabled across restarts."
+	helpModuleReload  = "`module reload <module>` disables and re-enables a module and its dependents."
+)
+
+// Disabling these would leave no way to turn them back on.
+var undisableableModules = []marvin.ModuleID{Identifier, atcommand.Identifier}
+
+func (mod *DebugModule) registerModuleCommand(t marvin.Team) {
+	parent := marvin.NewParentCommand().WithHelp(
+		"The `module` command manages modules at runtime. Changing module state needs the `module.manage` permission.\n" +
+			helpModuleList + "\n" + helpModuleStatus + "\n" + helpModuleEnable + "\n" + helpModuleDisable + "\n" + helpModuleReload,
+	)
+	parent.RegisterCommandFunc("list", mod.CommandModuleList, helpModuleList)
+	parent.RegisterCommandFunc("status", mod.CommandModuleStatus, helpModuleStatus)
+	parent.RegisterCommandFuncPerm("enable", mod.CommandModuleEnable, helpModuleEnable, PermModuleManage)
+	parent.RegisterCommandFuncPerm("disable", mod.CommandModuleDisable, helpModuleDisable, PermModuleManage)
+	parent.RegisterCommandFuncPerm("reload", mod.CommandModuleReload, helpModuleReload, PermModuleManage)
+	t.RegisterCommand("module", parent)
+}
+
+// offOnBoot reports whether the module is configured to stay disabled on the
+// next restart.
+func (mod *DebugModule) offOnBoot(modID marvin.ModuleID) bool {
+	desired, _, _ := mod.team.ModuleConfig("modules").GetIsDefault(string(modID))
+	return desired == marvin.ConfTurnOffModule
+}
+
+func formatModuleIDs(ids []marvin.ModuleID) string {
+	if len(ids) == 0 {
+		return "(none)"
+	}
+	strs := make([]string, len(ids))
+	for i, v := range ids {
+		strs[i] = fmt.Sprintf("`%s`", v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func (mod *DebugModule) CommandModuleList(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	all := mod.team.GetAllModules()
+	sort.Slice(all, func(i, j int) bool { return all[i].Identifier() < all[j].Identifier() })
+
+	var buf bytes.Buffer
+	buf.WriteString("Modules:\n")
+	for _, ms := range all {
+		fmt.Fprintf(&buf, "`%s` %s", ms.Identifier(), ms.State())
+		if ms.Degraded() {
+			buf.WriteString(" :warning:")
+		}
+		if mod.offOnBoot(ms.Identifier()) {
+			buf.WriteString(" _(off on boot)_")
+		}
+		buf.WriteString("\n")
+	}
+	return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+}
+
+func (mod *DebugModule) CommandModuleStatus(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) != 1 {
+		return marvin.CmdUsage(args, "Usage: `@marvin module status <module>`").WithSimpleUndo()
+	}
+	modID := marvin.ModuleID(args.Arguments[0])
+	ms := mod.team.GetModuleStatus(modID)
+	if ms == nil {
+		return marvin.CmdFailuref(args, "No such module `%s`", modID).WithSimpleUndo()
+	}
+
+	var dependents []marvin.ModuleID
+	for _, other := range mod.team.GetAllModules() {
+		for _, v := range other.DependsOn() {
+			if v == modID {
+				dependents = append(dependents, other.Identifier())
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Module `%s` is %s", modID, ms.State())
+	if mod.offOnBoot(modID) {
+		buf.WriteString(", and will stay disabled on restart")
+	}
+	buf.WriteString(".\n")
+	if ms.Err() != nil {
+		fmt.Fprintf(&buf, "Error: %v\n", ms.Err())
+	}
+	fmt.Fprintf(&buf, "Depends on: %s\n", formatModuleIDs(ms.DependsOn()))
+	fmt.Fprintf(&buf, "Required by: %s", formatModuleIDs(dependents))
+	return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+}
+
+func (mod *DebugModule) checkModuleArgs(args *marvin.CommandArguments, subcommand string) (marvin.ModuleID, *marvin.CommandResult) {
+	if len(args.Arguments) != 1 {
+		r := marvin.CmdUsage(args, fmt.Sprintf("Usage: `@marvin module %s <module>`", subcommand)).WithSimpleUndo()
+		return "", &r
+	}
+	modID := marvin.ModuleID(args.Arguments[0])
+	if mod.team.GetModuleStatus(modID) == nil {
+		r := marvin.CmdFailuref(args, "No such module `%s`", modID).WithSimpleUndo()
+		return "", &r
+	}
+	return modID, nil
+}
+
+func (mod *DebugModule) CommandModuleEnable(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	modID, fail := mod.checkModuleArgs(args, "enable")
+	if fail != nil {
+		return *fail
+	}
+
+	err := mod.team.EnableModule(modID)
+	if err != nil {
+		return marvin.CmdFailuref(args, "%s", err).WithNoUndo()
+	}
+	err = mod.team.ModuleConfig("modules").SetDefault(string(modID))
+	if err != nil {
+		return marvin.CmdError(args, err, "Module enabled, but could not save the setting")
+	}
+	t.Audit(args.Source, marvin.AuditEntry{Module: modID, Action: "module.enable", Target: string(modID)})
+	return marvin.CmdSuccess(args, fmt.Sprintf("Enabled module `%s`.", modID)).WithNoUndo()
+}
+
+func (mod *DebugModule) CommandModuleDisable(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	modID, fail := mod.checkModuleArgs(args, "disable")
+	if fail != nil {
+		return *fail
+	}
+	for _, v := range undisableableModules {
+		if v == modID {
+			return marvin.CmdFailuref(args, "Module `%s` cannot be disabled, as it would not be possible to turn it back on. Try `module reload`.", modID).WithSimpleUndo()
+		}
+	}
+
+	cascaded, err := mod.team.DisableModule(modID)
+	if err != nil {
+		return marvin.CmdError(args, err, "Failed to disable module")
+	}
+	// Dependents are saved as off too, or they would fail to enable on the
+	// next start
+	conf := mod.team.ModuleConfig("modules")
+	for _, v := range append([]marvin.ModuleID{modID}, cascaded...) {
+		err = conf.Set(string(v), marvin.ConfTurnOffModule)
+		if err != nil {
+			return marvin.CmdError(args, err, "Module disabled, but could not save the setting")
+		}
+	}
+	t.Audit(args.Source, marvin.AuditEntry{Module: modID, Action: "module.disable", Target: string(modID)})
+	msg := fmt.Sprintf("Disabled module `%s`.", modID)
+	if len(cascaded) > 0 {
+		msg += fmt.Sprintf(" Also disabled dependent modules, which stay off until enabled again: %s", formatModuleIDs(cascaded))
+	}
+	return marvin.CmdSuccess(args, msg).WithNoUndo()
+}
+
+func (mod *DebugModule) CommandModuleReload(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	modID, fail := mod.checkModuleArgs(args, "reload")
+	if fail != nil {
+		return *fail
+	}
+
+	cascaded, err := mod.team.DisableModule(modID)
+	if err != nil {
+		return marvin.CmdError(args, err, "Failed to disable module")
+	}
+	err = mod.team.EnableModule(modID)
+	if err != nil {
+		return marvin.CmdError(args, err, "Failed to re-enable module")
+	}
+	// Dependents were disabled deepest-first, so enable them in reverse
+	for i := len(cascaded) - 1; i >= 0; i-- {
+		err = mod.team.EnableModule(cascaded[i])
+		if err != nil {
+			return marvin.CmdError(args, err, fmt.Sprintf("Failed to re-enable dependent module %s", cascaded[i]))
+		}
+	}
+	msg := fmt.Sprintf("Reloaded module `%s`.", modID)
+	if len(cascaded) > 0 {
+		msg += fmt.Sprintf(" Also reloaded: %s", formatModuleIDs(cascaded))
+	}
+	return marvin.CmdSuccess(args, msg).WithNoUndo()
+}
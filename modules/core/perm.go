@@ -0,0 +1,225 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+)
+
+var (
+	PermConfigSet    = marvin.Permission{Name: "config.set", Level: marvin.AccessLevelAdmin}
+	PermConfigImport = marvin.Permission{Name: "config.import", Level: marvin.AccessLevelAdmin}
+	PermModuleManage = marvin.Permission{Name: "module.manage", Level: marvin.AccessLevelController}
+	PermPermManage   = marvin.Permission{Name: "perm.manage", Level: marvin.AccessLevelAdmin}
+)
+
+const (
+	helpPermShow   = "`perm show [@user|role]` lists roles and permissions, or shows the roles of a user or the permissions of a role."
+	helpPermGrant  = "`perm grant <role> <@user> [#channel]` gives a user a role, optionally only in one channel."
+	helpPermRevoke = "`perm revoke <role> <@user> [#channel]` takes a role away from a user."
+	helpPermRole   = "`perm role <role> add|remove <permission>` changes the permissions of a role. `*` is every permission."
+)
+
+var rgxRoleName = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+func (mod *DebugModule) registerPermCommand(t marvin.Team) {
+	parent := marvin.NewParentCommand().WithHelp(
+		"The `perm` command manages roles, which give users permissions beyond their access level.\n" +
+			helpPermShow + "\n" + helpPermGrant + "\n" + helpPermRevoke + "\n" + helpPermRole,
+	)
+	parent.RegisterCommandFunc("show", mod.CommandPermShow, helpPermShow)
+	parent.RegisterCommandFuncPerm("grant", mod.CommandPermGrant, helpPermGrant, PermPermManage)
+	parent.RegisterCommandFuncPerm("revoke", mod.CommandPermRevoke, helpPermRevoke, PermPermManage)
+	parent.RegisterCommandFuncPerm("role", mod.CommandPermRole, helpPermRole, PermPermManage)
+	t.RegisterCommand("perm", parent)
+}
+
+func (mod *DebugModule) CommandPermShow(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	roles, err := t.ListRoles()
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+
+	if len(args.Arguments) == 0 {
+		var buf bytes.Buffer
+		buf.WriteString("Permissions:\n")
+		perms := t.ListPermissions()
+		sort.Slice(perms, func(i, j int) bool { return perms[i].Name < perms[j].Name })
+		for i, v := range perms {
+			if i > 0 && perms[i-1].Name == v.Name {
+				continue
+			}
+			fmt.Fprintf(&buf, "`%s` - %s\n", v.Name, v.Level)
+		}
+		buf.WriteString("Roles:\n")
+		if len(roles) == 0 {
+			buf.WriteString("(none)\n")
+		}
+		for _, name := range sortedKeys(roles) {
+			fmt.Fprintf(&buf, "`%s`: `%s`\n", name, strings.Join(roles[name], "` `"))
+		}
+		return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+	}
+
+	if perms, ok := roles[args.Arguments[0]]; ok {
+		return marvin.CmdSuccess(args, fmt.Sprintf("Role `%s` grants: `%s`", args.Arguments[0], strings.Join(perms, "` `"))).WithSimpleUndo()
+	}
+	user := t.ResolveUserName(args.Arguments[0])
+	if user == "" {
+		return marvin.CmdFailuref(args, "'%s' is not a role or a user", args.Arguments[0]).WithSimpleUndo()
+	}
+	grants, err := t.ListRoleGrants(user)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v is in the %s access level.\n", t.UserName(user), t.UserLevel(user))
+	if len(grants) == 0 {
+		buf.WriteString("They have no roles.")
+	}
+	for _, v := range grants {
+		fmt.Fprintf(&buf, "`%s`", v.Role)
+		if v.Channel != "" {
+			fmt.Fprintf(&buf, " in %s", t.FormatChannel(v.Channel))
+		}
+		buf.WriteString("\n")
+	}
+	return marvin.CmdSuccess(args, buf.String()).WithSimpleUndo()
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	argsPermGrant  = marvin.NewArgParser("perm grant").Arg("role", marvin.ArgString).Arg("user", marvin.ArgUser).OptionalArg("channel", marvin.ArgChannel)
+	argsPermRevoke = marvin.NewArgParser("perm revoke").Arg("role", marvin.ArgString).Arg("user", marvin.ArgUser).OptionalArg("channel", marvin.ArgChannel)
+)
+
+// parseGrantArgs parses `<role> <@user> [#channel]`.
+func parseGrantArgs(t marvin.Team, args *marvin.CommandArguments, parser *marvin.ArgParser) (role string, user slack.UserID, channel slack.ChannelID, fail *marvin.CommandResult) {
+	p, fail := parser.Parse(t, args)
+	if fail != nil {
+		return "", "", "", fail
+	}
+	role = p.String("role")
+	if !rgxRoleName.MatchString(role) {
+		r := marvin.CmdFailuref(args, "Role names may only contain lowercase letters, numbers, `-` and `_`.").WithSimpleUndo()
+		return "", "", "", &r
+	}
+	return role, p.User("user"), p.Channel("channel"), nil
+}
+
+// permAboveLevel reports whether a permission is more than a user at the
+// given access level may hand out. The wildcard is above every level.
+func permAboveLevel(all []marvin.Permission, name string, level marvin.AccessLevel) bool {
+	if name == marvin.PermissionWildcard {
+		return true
+	}
+	for _, v := range all {
+		if v.Name == name && v.Level > level {
+			return true
+		}
+	}
+	return false
+}
+
+// roleScope formats a role grant for the audit log.
+func roleScope(role string, channel slack.ChannelID) string {
+	if channel == "" {
+		return role
+	}
+	return role + " in " + string(channel)
+}
+
+func (mod *DebugModule) CommandPermGrant(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	role, user, channel, fail := parseGrantArgs(t, args, argsPermGrant)
+	if fail != nil {
+		return *fail
+	}
+	roles, err := t.ListRoles()
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	all := t.ListPermissions()
+	for _, v := range roles[role] {
+		if permAboveLevel(all, v, args.Source.AccessLevel()) {
+			return marvin.CmdFailuref(args, "Role `%s` grants `%s`, which is above your access level.", role, v).WithSimpleUndo()
+		}
+	}
+	err = t.GrantRole(role, user, channel)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "perm.grant", Target: string(user), After: roleScope(role, channel)})
+	if channel != "" {
+		return marvin.CmdSuccess(args, fmt.Sprintf("Gave %v the `%s` role in %s.", t.UserName(user), role, t.FormatChannel(channel))).WithNoUndo()
+	}
+	return marvin.CmdSuccess(args, fmt.Sprintf("Gave %v the `%s` role.", t.UserName(user), role)).WithNoUndo()
+}
+
+func (mod *DebugModule) CommandPermRevoke(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	role, user, channel, fail := parseGrantArgs(t, args, argsPermRevoke)
+	if fail != nil {
+		return *fail
+	}
+	err := t.RevokeRole(role, user, channel)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "perm.revoke", Target: string(user), Before: roleScope(role, channel)})
+	return marvin.CmdSuccess(args, fmt.Sprintf("Took the `%s` role from %v.", role, t.UserName(user))).WithNoUndo()
+}
+
+func (mod *DebugModule) CommandPermRole(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) != 3 || (args.Arguments[1] != "add" && args.Arguments[1] != "remove") {
+		return marvin.CmdUsage(args, "Usage: `@marvin perm role <role> add|remove <permission>`").WithSimpleUndo()
+	}
+	role, permission := args.Arguments[0], args.Arguments[2]
+	if !rgxRoleName.MatchString(role) {
+		return marvin.CmdFailuref(args, "Role names may only contain lowercase letters, numbers, `-` and `_`.").WithSimpleUndo()
+	}
+
+	add := args.Arguments[1] == "add"
+	if add && permAboveLevel(t.ListPermissions(), permission, args.Source.AccessLevel()) {
+		return marvin.CmdFailuref(args, "You cannot give a role `%s`, as it is above your access level.", permission).WithSimpleUndo()
+	}
+	if add && permission != marvin.PermissionWildcard {
+		found := false
+		for _, v := range t.ListPermissions() {
+			if v.Name == permission {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return marvin.CmdFailuref(args, "No such permission `%s`. See `@marvin perm show`.", permission).WithSimpleUndo()
+		}
+	}
+	err := t.SetRolePermission(role, permission, add)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	entry := marvin.AuditEntry{Module: Identifier, Action: "perm.role", Target: role}
+	if add {
+		entry.After = permission
+	} else {
+		entry.Before = permission
+	}
+	t.Audit(args.Source, entry)
+	if add {
+		return marvin.CmdSuccess(args, fmt.Sprintf("Role `%s` now grants `%s`.", role, permission)).WithNoUndo()
+	}
+	return marvin.CmdSuccess(args, fmt.Sprintf("Role `%s` no longer grants `%s`.", role, permission)).WithNoUndo()
+}
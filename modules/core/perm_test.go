@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/riking/marvin"
+)
+
+func TestPermAboveLevel(t *testing.T) {
+	all := []marvin.Permission{
+		{Name: "factoid.lock", Level: marvin.AccessLevelNormal},
+		PermPermManage,
+		PermModuleManage,
+	}
+	cases := []struct {
+		name  string
+		level marvin.AccessLevel
+		above bool
+	}{
+		{"factoid.lock", marvin.AccessLevelNormal, false},
+		{"perm.manage", marvin.AccessLevelNormal, true},
+		{"perm.manage", marvin.AccessLevelAdmin, false},
+		{"module.manage", marvin.AccessLevelAdmin, true},
+		{"module.manage", marvin.AccessLevelController, false},
+		{marvin.PermissionWildcard, marvin.AccessLevelAdmin, true},
+		{marvin.PermissionWildcard, marvin.AccessLevelController, true},
+		{"unknown", marvin.AccessLevelNormal, false},
+	}
+	for _, c := range cases {
+		if got := permAboveLevel(all, c.name, c.level); got != c.above {
+			t.Errorf("permAboveLevel(%q, %v) = %v, expected %v", c.name, c.level, got, c.above)
+		}
+	}
+}
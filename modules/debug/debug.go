@@ -17,6 +17,8 @@ func init() {
 
 const Identifier = "debug"
 
+var PermEcho = marvin.Permission{Name: "echo", Level: marvin.AccessLevelAdmin}
+
 type DebugModule struct {
 	team marvin.Team
 
@@ -49,12 +51,13 @@ func (mod *DebugModule) Enable(t marvin.Team) {
 	parent.RegisterCommandFunc("do_help", mod.DebugCommandHelp, "`debug do_help` tests the behavior of commands returning help text.")
 	parent.RegisterCommandFunc("success", mod.DebugCommandSuccess, "`debug success` tests the behavior of successful commands.")
 	parent.RegisterCommandFunc("paste", mod.DebugCommandPaste, "`debug paste` tests the paste module.")
+	parent.RegisterCommandFunc("apiqueue", mod.DebugCommandAPIQueue, "`debug apiqueue` shows the state of the outbound Slack API queue.")
 
 	whoami := parent.RegisterCommandFunc("whoami", mod.CommandWhoAmI, "`debug whoami [@user]` prints out your Slack user ID.")
 	whereami := parent.RegisterCommandFunc("whereami", mod.CommandWhereAmI, "`debug whereami` prints out the current channel ID.")
 
 	t.RegisterCommand("debug", parent)
-	t.RegisterCommandFunc("echo", mod.CommandEcho, "`echo` echos back the command arguments to the channel.")
+	t.RegisterCommandFuncPerm("echo", mod.CommandEcho, "`echo` echos back the command arguments to the channel.", PermEcho)
 	t.RegisterCommand("whoami", whoami)
 	t.RegisterCommand("whereami", whereami)
 }
@@ -94,6 +97,16 @@ func (mod *DebugModule) DebugCommandSuccess(t marvin.Team, args *marvin.CommandA
 	return marvin.CmdSuccess(args, "Sample success").WithEdit()
 }
 
+func (mod *DebugModule) DebugCommandAPIQueue(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	stats := t.SlackAPIQueueStats()
+	return marvin.CmdSuccess(args, fmt.Sprintf(
+		"Queued: %d high, %d normal, %d low (oldest %v)\nIn flight: %d\nSent: %d, rate limited: %d, dropped: %d",
+		stats.Queued[slack.PriorityHigh], stats.Queued[slack.PriorityNormal], stats.Queued[slack.PriorityLow],
+		stats.OldestWait.Round(time.Millisecond), stats.InFlight,
+		stats.Sent, stats.RateLimited, stats.Dropped,
+	)).WithSimpleUndo()
+}
+
 func (mod *DebugModule) DebugCommandPaste(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
 	if mod.team.TeamConfig().IsReadOnly {
 		return marvin.CmdFailuref(args, "Marvin is currently on read only.")
@@ -107,9 +120,6 @@ func (mod *DebugModule) DebugCommandPaste(t marvin.Team, args *marvin.CommandArg
 }
 
 func (mod *DebugModule) CommandEcho(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
-	if args.Source.AccessLevel() < marvin.AccessLevelAdmin {
-		return marvin.CmdFailuref(args, "This command is restricted to admins.")
-	}
 	return marvin.CmdSuccess(args, strings.Join(args.Arguments, " ")).WithReplyType(marvin.ReplyTypeFlagOmitUsername).WithEdit()
 }
 
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/modules/antiflood"
 	"github.com/riking/marvin/modules/atcommand"
@@ -47,7 +49,16 @@ func (mod *BangFactoidModule) Load(t marvin.Team) {
 	//if -2 == t.DependModule(mod, Identifier, &mod.factoidModule) {
 	//	panic("Failure in dependency")
 	//}
-	t.ModuleConfig(Identifier).Add("factoid-char", "!")
+	t.ModuleConfig(Identifier).AddTyped("factoid-char", "!", marvin.ConfigSchema{
+		Type:        marvin.ConfTypeString,
+		Description: "Characters that start a factoid command",
+		Validator: func(value string) error {
+			if value == "" {
+				return errors.New("must contain at least one character")
+			}
+			return nil
+		},
+	})
 }
 
 func (mod *BangFactoidModule) Enable(team marvin.Team) {
@@ -72,15 +83,20 @@ func (mod *BangFactoidModule) OnMessage(_rtm slack.RTMRawMessage) {
 	if rtm.UserID() == "USLACKBOT" || rtm.UserID() == mod.team.BotUser() || rtm.ChannelID() == "D00" {
 		return
 	}
-	if _, isThread := _rtm["thread_ts"]; isThread {
-		// TODO thread operation
-		return
-	}
 	result, of := mod.Process(rtm, false)
 	if result == "" {
 		return
 	}
-	sentMsgID, _, err := mod.team.SendMessage(rtm.ChannelID(), " "+atcommand.SanitizeForChannel(result))
+	var sentMsgID slack.MessageTS
+	var err error
+	if threadTS := rtm.ThreadTS(); threadTS != "" {
+		sentMsgID, _, err = mod.team.SendComplexMessage(rtm.ChannelID(), slack.OutgoingSlackMessage{
+			Text:     " " + atcommand.SanitizeForChannel(result),
+			ThreadTS: threadTS,
+		})
+	} else {
+		sentMsgID, _, err = mod.team.SendMessage(rtm.ChannelID(), " "+atcommand.SanitizeForChannel(result))
+	}
 	if err != nil {
 		util.LogError(err)
 		return
@@ -138,7 +154,7 @@ func (mod *BangFactoidModule) Process(rtm slack.SlackTextMessage, isEditing bool
 	if len(rtm.Text()) == 0 {
 		return "", of
 	}
-	fchars, _ := mod.team.ModuleConfig(Identifier).Get("factoid-char")
+	fchars, _ := mod.team.ModuleConfig(Identifier).GetForChannel("factoid-char", rtm.ChannelID())
 	if !strings.ContainsAny(rtm.Text()[:1], fchars) {
 		return "", of
 	}
@@ -148,12 +164,16 @@ func (mod *BangFactoidModule) Process(rtm slack.SlackTextMessage, isEditing bool
 		return "", of
 	}
 	// Check anti flood module.
-	if !isEditing && userLvl < marvin.AccessLevelAdmin &&
-		!mod.team.GetModule(antiflood.Identifier).(antiflood.API).CheckChannel(rtm.ChannelID()) {
+	flood := mod.team.GetModule(antiflood.Identifier).(antiflood.API)
+	if !isEditing && userLvl < marvin.AccessLevelAdmin && !flood.CheckChannel(rtm.ChannelID()) {
 		return "", of
 	}
 	text := slack.UnescapeTextAll(rtm.Text()[1:])
 	line := strings.Split(text, " ")
+	if !isEditing && !flood.CheckUser(rtm.UserID(), rtm.ChannelID(), "factoid:"+strings.ToLower(line[0])) {
+		flood.Throttled(rtm.MessageID())
+		return "", of
+	}
 
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
@@ -161,9 +181,10 @@ func (mod *BangFactoidModule) Process(rtm slack.SlackTextMessage, isEditing bool
 
 	source := &marvin.ActionSourceUserMessage{Team: mod.team, Msg: rtm}
 
-	result, err := mod.team.GetModule(Identifier).(API).RunFactoid(ctx, line, &of, source)
+	factoidAPI := mod.team.GetModule(Identifier).(API)
+	result, err := factoidAPI.RunFactoid(ctx, line, &of, source)
 	if err == ErrNoSuchFactoid {
-		return "", of
+		return mod.suggestFactoids(factoidAPI, rtm.Text()[:1], line[0], rtm.ChannelID()), of
 	} else if err != nil {
 		result = fmt.Sprintf("Error: %s", err)
 	} else if of.NoReply {
@@ -174,3 +195,20 @@ func (mod *BangFactoidModule) Process(rtm slack.SlackTextMessage, isEditing bool
 	util.LogGood(fmt.Sprintf("Factoid result:\n%s\n%s", line, result))
 	return result, of
 }
+
+// minSuggestLen keeps messages like "!!" or "!ok" from getting suggestions.
+const minSuggestLen = 3
+
+// suggestFactoids returns a "Did you mean" line for a factoid name that was
+// not found, or the empty string if nothing is close.
+func (mod *BangFactoidModule) suggestFactoids(factoidAPI API, fchar, name string, channel slack.ChannelID) string {
+	if len(name) < minSuggestLen {
+		return ""
+	}
+	channelOnly, global, err := factoidAPI.ListFactoids("", channel)
+	if err != nil {
+		util.LogError(err)
+		return ""
+	}
+	return marvin.FormatSuggestions(fchar, marvin.SuggestNames(name, append(channelOnly, global...)))
+}
@@ -2,9 +2,12 @@ package factoid
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	flag "github.com/ogier/pflag"
 	"github.com/pkg/errors"
@@ -37,6 +40,8 @@ const (
 	helpList     = "`factoid list [pattern]` lists all factoids with `pattern` in their name."
 	helpForget   = "`factoid forget <name>` forgets the most recent version of a factoid."
 	helpUnforget = "`factoid unforget <name>` un-forgets a previously forgotten factoid."
+	helpLock     = "`factoid lock <name>` stops a factoid from being edited or forgotten. Locking a global factoid requires admin."
+	helpUnlock   = "`factoid unlock <name>` allows a locked factoid to be edited again."
 )
 
 func (mod *FactoidModule) CmdRemember(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
@@ -115,13 +120,44 @@ func (mod *FactoidModule) CmdRemember(t marvin.Team, args *marvin.CommandArgumen
 	return marvin.CmdSuccess(args, "").WithCustomUndo().WithEdit()
 }
 
+// asyncFactoidTimeout limits Lua factoids run by `factoid get`, which run in
+// the background.
+const asyncFactoidTimeout = 5 * time.Minute
+
 func (mod *FactoidModule) CmdGet(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
 	if len(args.Arguments) < 1 {
 		return marvin.CmdUsage(args, helpGet)
 	}
 
+	if mod.isLuaFactoid(args.Arguments[0], args.Source.ChannelID()) {
+		msg := fmt.Sprintf("Running `%s`...", args.Arguments[0])
+		return marvin.CmdAsync(args, msg, func(ctx context.Context, progress marvin.ProgressFunc) marvin.CommandResult {
+			ctx, cancel := context.WithTimeout(ctx, asyncFactoidTimeout)
+			defer cancel()
+			return mod.getFactoid(ctx, args)
+		})
+	}
+	return mod.getFactoid(args.Ctx, args)
+}
+
+// isLuaFactoid reports whether the factoid runs Lua, and so may be slow.
+func (mod *FactoidModule) isLuaFactoid(name string, channel slack.ChannelID) bool {
+	info, err := mod.GetFactoidBare(name, channel)
+	if err != nil {
+		return false
+	}
+	directives, _ := Directives(info.RawSource)
+	for _, v := range directives {
+		if v.Directive == "lua" || v.Directive == "luar" {
+			return true
+		}
+	}
+	return false
+}
+
+func (mod *FactoidModule) getFactoid(ctx context.Context, args *marvin.CommandArguments) marvin.CommandResult {
 	var of OutputFlags
-	result, err := mod.RunFactoid(args.Ctx, args.Arguments, &of, args.Source)
+	result, err := mod.RunFactoid(ctx, args.Arguments, &of, args.Source)
 	if err == ErrNoSuchFactoid {
 		return marvin.CmdFailuref(args, "No such factoid %s", result).WithEdit().WithReplyType(marvin.ReplyTypeInChannel)
 	} else if err != nil {
@@ -278,10 +314,83 @@ func (mod *FactoidModule) CmdForget(t marvin.Team, args *marvin.CommandArguments
 	if factoidInfo.IsLocked {
 		return marvin.CmdFailuref(args, "A locked factoid cannot be forgotten.").WithEdit().WithSimpleUndo()
 	}
+	if !args.Confirmed {
+		return marvin.CmdConfirm(args, fmt.Sprintf("This will forget `%s`:\n%s", factoidName, util.PreviewString(factoidInfo.RawSource, 200)))
+	}
 
 	err = mod.ForgetFactoid(factoidInfo.DbID, true)
 	if err != nil {
 		return marvin.CmdError(args, err, "Error forgetting factoid")
 	}
+	t.Audit(args.Source, marvin.AuditEntry{
+		Module: Identifier,
+		Action: "factoid.forget",
+		Target: factoidAuditTarget(factoidName, factoidInfo.ScopeChannel),
+		Before: factoidInfo.RawSource,
+	})
 	return marvin.CmdSuccess(args, fmt.Sprintf("Forgot `%s` with database ID %d", factoidName, factoidInfo.DbID)).WithNoEdit().WithNoUndo()
 }
+
+// factoidAuditTarget names a factoid in the audit log.
+func factoidAuditTarget(name string, scope slack.ChannelID) string {
+	if scope != "" {
+		return fmt.Sprintf("%s in %s", name, scope)
+	}
+	return name
+}
+
+func (mod *FactoidModule) CmdLock(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	return mod.cmdSetLock(t, args, true)
+}
+
+func (mod *FactoidModule) CmdUnlock(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	return mod.cmdSetLock(t, args, false)
+}
+
+func (mod *FactoidModule) cmdSetLock(t marvin.Team, args *marvin.CommandArguments, lock bool) marvin.CommandResult {
+	if len(args.Arguments) != 1 {
+		if lock {
+			return marvin.CmdUsage(args, helpLock).WithSimpleUndo()
+		}
+		return marvin.CmdUsage(args, helpUnlock).WithSimpleUndo()
+	}
+	factoidName := args.Pop()
+	if len(factoidName) > FactoidNameMaxLen {
+		return marvin.CmdFailuref(args, "Factoid name too long").WithEdit().WithSimpleUndo()
+	}
+
+	factoidInfo, err := mod.GetFactoidInfo(factoidName, args.Source.ChannelID(), false)
+	if err == ErrNoSuchFactoid {
+		return marvin.CmdFailuref(args, "No such factoid").WithEdit().WithSimpleUndo()
+	} else if err != nil {
+		return marvin.CmdError(args, err, "Error retrieving factoid")
+	}
+
+	if factoidInfo.ScopeChannel == "" && args.Source.AccessLevel() < marvin.AccessLevelAdmin {
+		return marvin.CmdFailuref(args, "Only admins can lock or unlock global factoids.").WithSimpleUndo()
+	} else if args.Source.AccessLevel() < marvin.AccessLevelChannelAdmin {
+		return marvin.CmdFailuref(args, "Only channel admins can lock or unlock factoids.").WithSimpleUndo()
+	}
+	if factoidInfo.IsLocked == lock {
+		if lock {
+			return marvin.CmdSuccess(args, fmt.Sprintf("`%s` is already locked.", factoidName)).WithSimpleUndo()
+		}
+		return marvin.CmdSuccess(args, fmt.Sprintf("`%s` is not locked.", factoidName)).WithSimpleUndo()
+	}
+
+	err = mod.LockFactoid(factoidInfo.DbID, lock)
+	if err != nil {
+		return marvin.CmdError(args, err, "Error changing factoid lock")
+	}
+	t.Audit(args.Source, marvin.AuditEntry{
+		Module: Identifier,
+		Action: "factoid.lock",
+		Target: factoidAuditTarget(factoidName, factoidInfo.ScopeChannel),
+		Before: strconv.FormatBool(factoidInfo.IsLocked),
+		After:  strconv.FormatBool(lock),
+	})
+	if lock {
+		return marvin.CmdSuccess(args, fmt.Sprintf("Locked `%s`.", factoidName)).WithNoUndo()
+	}
+	return marvin.CmdSuccess(args, fmt.Sprintf("Unlocked `%s`.", factoidName)).WithNoUndo()
+}
@@ -10,10 +10,14 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/riking/marvin"
+	"github.com/riking/marvin/metrics"
 	"github.com/riking/marvin/util"
 	"github.com/riking/marvin/util/shellquote"
 )
 
+var metricFactoidTime = metrics.NewHistogram("marvin_factoid_duration_seconds",
+	"Time taken to run factoids, including Lua.", metrics.DefBuckets)
+
 // ElevatedActionSource allows for a temporary elevation of user permissions.
 // It modifies the returned AccessLevel, and the Drop method allows the original rights to be restored.
 type ElevatedActionSource struct {
@@ -47,8 +51,14 @@ type OutputFlags struct {
 //   factoidName, ErrNoSuchFactoid - Factoid not found
 //   ErrUser - Something was wrong with the input. Not enough args, recursion limit reached.
 func (mod *FactoidModule) RunFactoid(ctx context.Context, line []string, of *OutputFlags, source marvin.ActionSource) (result string, err error) {
-	ctx, cancel := context.WithTimeout(ctx, 50*time.Second)
-	defer cancel()
+	// Callers with their own deadline, like `factoid get` in the
+	// background, may allow longer
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 50*time.Second)
+		defer cancel()
+	}
+	defer metricFactoidTime.ObserveSince(time.Now())
 	err = util.PCall(func() error {
 		result, err = mod.exec_alias(ctx, line, of, source)
 		return err
@@ -150,7 +160,7 @@ directives_loop:
 				IsEdit:            false,
 				ModuleData:        nil,
 			}
-			result := mod.team.DispatchCommand(args)
+			result := marvin.WaitCommand(mod.team, mod.team.DispatchCommand(args))
 			if result.Err != nil {
 				result.Message = fmt.Sprintf("[Error: %s] %s", result.Err, result.Message)
 			}
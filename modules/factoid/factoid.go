@@ -5,6 +5,7 @@ import (
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/modules/paste"
+	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/util"
 )
 
@@ -12,6 +13,7 @@ type API interface {
 	marvin.Module
 
 	RunFactoid(ctx context.Context, line []string, of *OutputFlags, source marvin.ActionSource) (result string, err error)
+	ListFactoids(match string, channel slack.ChannelID) (channelOnly, global []string, err error)
 }
 
 var _ API = &FactoidModule{}
@@ -62,19 +64,21 @@ func (mod *FactoidModule) Enable(team marvin.Team) {
 	parent := marvin.NewParentCommand()
 	remember := parent.RegisterCommandFunc("remember", mod.CmdRemember, helpRemember)
 	forget := parent.RegisterCommandFunc("forget", mod.CmdForget, helpForget)
-	parent.RegisterCommand("rem", remember)
-	parent.RegisterCommand("r", remember)
-	parent.RegisterCommand("fg", forget)
+	parent.RegisterAlias("rem", "remember")
+	parent.RegisterAlias("r", "remember")
+	parent.RegisterAlias("fg", "forget")
 	parent.RegisterCommandFunc("get", mod.CmdGet, helpGet)
 	parent.RegisterCommandFunc("source", mod.CmdSource, helpSource)
 	parent.RegisterCommandFunc("info", mod.CmdInfo, helpInfo)
 	parent.RegisterCommandFunc("list", mod.CmdList, helpList)
+	parent.RegisterCommandFunc("lock", mod.CmdLock, helpLock)
+	parent.RegisterCommandFunc("unlock", mod.CmdUnlock, helpUnlock)
 
 	team.RegisterCommand("factoid", parent)
-	team.RegisterCommand("f", parent) // TODO RegisterAlias
+	team.RegisterAlias("f", "factoid")
 	team.RegisterCommand("remember", remember)
-	team.RegisterCommand("rem", remember)
-	team.RegisterCommand("r", remember)
+	team.RegisterAlias("rem", "remember")
+	team.RegisterAlias("r", "remember")
 	team.RegisterCommand("forget", forget)
 
 	go mod.workerFDataChan()
@@ -3,19 +3,26 @@ package factoid
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/yuin/gopher-lua"
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/lualib"
+	"github.com/riking/marvin/metrics"
+	"github.com/riking/marvin/modules/antiflood"
 	"github.com/riking/marvin/util"
 )
 
+var metricLuaTime = metrics.NewHistogram("marvin_lua_duration_seconds",
+	"Time taken to run Lua factoids.", metrics.DefBuckets)
+
 type ctxKeyOutputFlags struct{}
 
 func RunFactoidLua(ctx context.Context, mod *FactoidModule, factoidName, factoidSource string, factoidArgs []string, of *OutputFlags, actSource marvin.ActionSource) (string, error) {
 	var result string
+	defer metricLuaTime.ObserveSince(time.Now())
 	err := util.PCall(func() error {
 		var err error
 		result, err = runLua(ctx, mod, factoidName, factoidSource, factoidArgs, of, actSource)
@@ -24,9 +31,19 @@ func RunFactoidLua(ctx context.Context, mod *FactoidModule, factoidName, factoid
 	return result, err
 }
 
+// requestLimit counts the HTTP requests of a Lua factoid against the
+// antiflood limit of the user running it.
+func (mod *FactoidModule) requestLimit(source marvin.ActionSource) func() bool {
+	return func() bool {
+		flood, _ := mod.team.GetModule(antiflood.Identifier).(antiflood.API)
+		return flood == nil || flood.CheckUser(source.UserID(), source.ChannelID(), "requests")
+	}
+}
+
 func runLua(ctx context.Context, mod *FactoidModule, factoidName, factoidSource string, factoidArgs []string, of *OutputFlags, actionSource marvin.ActionSource) (string, error) {
 	ctx = context.WithValue(ctx, ctxKeyOutputFlags{}, of)
 	g := lualib.NewLua(ctx, mod.team, actionSource)
+	g.RequestLimit = mod.requestLimit(actionSource)
 	g.OpenLibraries()
 
 	// Set globals
@@ -0,0 +1,274 @@
+/*
+interactivity provides a public cross-module interface for Block Kit buttons.
+
+A module registers a handler, then posts a message with buttons made by
+ButtonElement. When a user clicks one of the buttons, the handler is called
+with the action name and the opaque data that was attached to the button.
+
+	struct Module {
+		interactivity marvin.Module
+	}
+
+	// Load()
+	team.DependModule(mod, interactivity.Identifier, &mod.interactivity)
+
+	// Enable()
+	mod.interactivity.(interactivity.API).RegisterHandler(mod, Identifier)
+
+	// Disable()
+	if mod.interactivity != nil {
+		mod.interactivity.(interactivity.API).Unregister(Identifier)
+	}
+
+To post a message with a button:
+
+	team.SendComplexMessage(channel, slack.OutgoingSlackMessage{
+		Text: "Accept the invite?",
+		Blocks: []slack.Block{
+			slack.SectionBlock("Accept the invite?"),
+			slack.ActionsBlock(
+				interactivity.ButtonElement(Identifier, "accept", "Accept", jsonBytes),
+			),
+		},
+	})
+
+The Slack app's Interactivity Request URL must point at /slack/interactive.
+*/
+package interactivity
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+// ---
+// Public Interface
+
+// API is the cross-module interface that interactivity presents.
+type API interface {
+	marvin.Module
+
+	RegisterHandler(h ActionHandler, modID marvin.ModuleID)
+	RegisterFunc(f ActionCallbackFunc, modID marvin.ModuleID)
+	Unregister(modID marvin.ModuleID)
+
+	// Respond replaces the message the action was taken on, or adds a new
+	// message if replaceOriginal is false.
+	Respond(event *ActionEvent, msg slack.OutgoingSlackMessage, replaceOriginal bool) error
+}
+
+// ActionEvent contains all the available data about a button click.
+type ActionEvent struct {
+	// The message containing the button
+	slack.MessageID
+	ThreadTS slack.MessageTS
+
+	UserID slack.UserID
+	// The action name passed to ButtonElement
+	Action  string
+	BlockID string
+
+	TriggerID   string
+	ResponseURL string
+}
+
+// ActionHandler is the interface for the callbacks that InteractivityModule exports.
+type ActionHandler interface {
+	OnAction(event *ActionEvent, customData []byte) error
+}
+
+type ActionCallbackFunc func(event *ActionEvent, customData []byte) error
+
+// OnAction implements ActionHandler by calling the function.
+func (f ActionCallbackFunc) OnAction(event *ActionEvent, customData []byte) error {
+	return f(event, customData)
+}
+
+// ActionID returns the action_id that routes an element to the given module.
+func ActionID(modID marvin.ModuleID, action string) string {
+	return string(modID) + actionIDSeparator + action
+}
+
+// ButtonElement makes a button that calls the module's handler with the action
+// name and data when clicked. The data is visible to Slack clients, and must
+// be under 1.5KB.
+func ButtonElement(modID marvin.ModuleID, action, text string, data []byte) slack.BlockElement {
+	return slack.ButtonElement(ActionID(modID, action), text, base64.RawURLEncoding.EncodeToString(data))
+}
+
+// ---
+// Setup
+
+func init() {
+	marvin.RegisterModule(NewInteractivityModule)
+}
+
+const Identifier marvin.ModuleID = "interactivity"
+
+const interactivePath = "/slack/interactive"
+
+const actionIDSeparator = "/"
+
+type InteractivityModule struct {
+	team marvin.Team
+
+	listenLock sync.Mutex
+	listenMap  map[marvin.ModuleID]ActionHandler
+}
+
+func NewInteractivityModule(t marvin.Team) marvin.Module {
+	mod := &InteractivityModule{
+		team:      t,
+		listenMap: make(map[marvin.ModuleID]ActionHandler),
+	}
+	return mod
+}
+
+func (mod *InteractivityModule) Identifier() marvin.ModuleID {
+	return Identifier
+}
+
+func (mod *InteractivityModule) Load(t marvin.Team) {
+	// Routes cannot be removed, so the handler checks that the module is enabled
+	t.Router().Path(interactivePath).Methods(http.MethodPost).HandlerFunc(mod.HTTPInteractive)
+}
+
+func (mod *InteractivityModule) Enable(t marvin.Team) {
+}
+
+func (mod *InteractivityModule) Disable(t marvin.Team) {
+}
+
+// ---
+
+func (mod *InteractivityModule) RegisterHandler(h ActionHandler, modID marvin.ModuleID) {
+	mod.listenLock.Lock()
+	defer mod.listenLock.Unlock()
+
+	mod.listenMap[modID] = h
+}
+
+func (mod *InteractivityModule) RegisterFunc(f ActionCallbackFunc, modID marvin.ModuleID) {
+	mod.RegisterHandler(f, modID)
+}
+
+func (mod *InteractivityModule) Unregister(modID marvin.ModuleID) {
+	mod.listenLock.Lock()
+	delete(mod.listenMap, modID)
+	mod.listenLock.Unlock()
+}
+
+func (mod *InteractivityModule) getHandler(modID marvin.ModuleID) ActionHandler {
+	mod.listenLock.Lock()
+	defer mod.listenLock.Unlock()
+
+	return mod.listenMap[modID]
+}
+
+// ---
+
+// https://api.slack.com/interactivity/handling#payloads
+func (mod *InteractivityModule) HTTPInteractive(w http.ResponseWriter, r *http.Request) {
+	if !mod.team.GetModuleStatus(Identifier).IsEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := slack.VerifyRequest(r, []byte(mod.team.TeamConfig().SigningSecret))
+	if err != nil {
+		util.LogBad("interactivity:", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var payload slack.BlockActionPayload
+	err = json.Unmarshal([]byte(form.Get("payload")), &payload)
+	if err != nil {
+		util.LogBad("interactivity: bad payload:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// Handlers may take longer than Slack's 3 second deadline
+	w.WriteHeader(http.StatusOK)
+
+	if payload.Type != "block_actions" {
+		util.LogDebug("interactivity: ignoring payload type", payload.Type)
+		return
+	}
+	for _, v := range payload.Actions {
+		go mod.dispatchAction(&payload, v)
+	}
+}
+
+func (mod *InteractivityModule) dispatchAction(payload *slack.BlockActionPayload, action slack.BlockAction) {
+	split := strings.SplitN(action.ActionID, actionIDSeparator, 2)
+	if len(split) != 2 {
+		util.LogDebug("interactivity: unrouted action_id", action.ActionID)
+		return
+	}
+	modID := marvin.ModuleID(split[0])
+	handler := mod.getHandler(modID)
+	if handler == nil {
+		util.LogWarn("interactivity: no handler for", modID)
+		return
+	}
+	data, err := base64.RawURLEncoding.DecodeString(action.Value)
+	if err != nil {
+		util.LogBad("interactivity: bad value for", action.ActionID)
+		return
+	}
+
+	event := ActionEvent{
+		MessageID:   slack.MsgID(payload.Container.ChannelID, payload.Container.MessageTS),
+		ThreadTS:    payload.Container.ThreadTS,
+		UserID:      payload.User.ID,
+		Action:      split[1],
+		BlockID:     action.BlockID,
+		TriggerID:   payload.TriggerID,
+		ResponseURL: payload.ResponseURL,
+	}
+	err = util.PCall(func() error {
+		return handler.OnAction(&event, data)
+	})
+	if err != nil {
+		util.LogError(err)
+	}
+	util.LogTeamDebug(mod.team.Domain(), "interactivity: dispatched", action.ActionID)
+}
+
+func (mod *InteractivityModule) Respond(event *ActionEvent, msg slack.OutgoingSlackMessage, replaceOriginal bool) error {
+	if !strings.HasPrefix(event.ResponseURL, "https://") {
+		return errors.Errorf("interactivity: bad response_url '%s'", event.ResponseURL)
+	}
+	var resp = struct {
+		slack.OutgoingSlackMessage
+		ReplaceOriginal bool `json:"replace_original"`
+	}{msg, replaceOriginal}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return errors.Wrap(err, "interactivity: encode response")
+	}
+	httpResp, err := http.Post(event.ResponseURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "interactivity: post response")
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return errors.Errorf("interactivity: response_url returned %s", httpResp.Status)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -63,12 +64,30 @@ func (mod *PasteModule) Load(t marvin.Team) {
 	t.DB().SyntaxCheck(sqlAddPaste, sqlGetPaste, sqlAddLink, sqlGetLink)
 }
 
+const helpPaste = "`paste <text>` saves the text and replies with a link to it. Use it at the end of a pipeline, like `rss list | paste`."
+
 func (mod *PasteModule) Enable(team marvin.Team) {
 	team.Router().Handle("/p/{id}", mod)
 	team.Router().Handle("/l/{id}", mod)
+	team.RegisterCommandFunc("paste", mod.CommandPaste, helpPaste)
 }
 
 func (mod *PasteModule) Disable(team marvin.Team) {
+	team.UnregisterCommand("paste")
+}
+
+func (mod *PasteModule) CommandPaste(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) == 0 {
+		return marvin.CmdUsage(args, helpPaste).WithSimpleUndo()
+	}
+	if t.TeamConfig().IsReadOnly {
+		return marvin.CmdFailuref(args, "Marvin is currently on read only.").WithSimpleUndo()
+	}
+	id, err := mod.CreatePaste(strings.Join(args.Arguments, " "))
+	if err != nil {
+		return marvin.CmdError(args, err, "creating paste")
+	}
+	return marvin.CmdSuccess(args, mod.URLForPaste(id)).WithSimpleUndo()
 }
 
 const idBase = 36
@@ -0,0 +1,412 @@
+package reminders
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/atcommand"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+func init() {
+	marvin.RegisterModule(NewRemindersModule)
+}
+
+const Identifier = "reminders"
+
+// maxPerUser limits how many reminders one person can have waiting.
+const maxPerUser = 25
+
+const timeFormat = "Mon Jan 2 15:04 MST"
+
+const jobDeliver = "deliver"
+
+type RemindersModule struct {
+	team marvin.Team
+}
+
+func NewRemindersModule(t marvin.Team) marvin.Module {
+	mod := &RemindersModule{
+		team: t,
+	}
+	return mod
+}
+
+func (mod *RemindersModule) Identifier() marvin.ModuleID {
+	return Identifier
+}
+
+func (mod *RemindersModule) Load(t marvin.Team) {
+	t.DB().MustMigrate(Identifier, 1792540800, sqlMigrate1)
+	t.DB().SyntaxCheck(
+		sqlInsert,
+		sqlSetJob,
+		sqlGet,
+		sqlDelete,
+		sqlCountByUser,
+		sqlListByUser,
+	)
+}
+
+const helpRemind = "`remind me|@user|#channel <when> <what>` sends a message at a later time.\n" +
+	"<when> can be `in 2h30m`, `in 3 days`, `at 5pm`, `tomorrow 9am`, `friday 14:00`, `2026-12-24 18:00`, " +
+	"or repeating: `every day 9am`, `every weekday 10:00`, `every monday noon`, `every 4h`.\n" +
+	"Times are in your Slack timezone.\n" +
+	"`remind list` shows your reminders, and `remind cancel <id>` cancels one."
+
+func (mod *RemindersModule) Enable(t marvin.Team) {
+	t.RegisterJobHandler(Identifier, jobDeliver, mod.deliver)
+	t.RegisterCommandFunc("remind", mod.CommandRemind, helpRemind)
+}
+
+func (mod *RemindersModule) Disable(t marvin.Team) {
+	t.UnregisterCommand("remind")
+}
+
+// ---
+
+const (
+	sqlMigrate1 = `
+	CREATE TABLE module_reminders_reminders (
+		id          SERIAL PRIMARY KEY,
+		created_by  varchar(15) NOT NULL,
+		target_user varchar(15) NOT NULL DEFAULT '',
+		channel     varchar(15) NOT NULL DEFAULT '',
+		message     text NOT NULL,
+		schedule    text NOT NULL DEFAULT '',
+		tz          text NOT NULL,
+		next_run    timestamptz NOT NULL,
+		job_id      bigint NOT NULL DEFAULT 0,
+		created_at  timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	// $1 = created_by $2 = target_user $3 = channel $4 = message
+	// $5 = schedule $6 = tz $7 = next_run
+	sqlInsert = `
+	INSERT INTO module_reminders_reminders
+	(created_by, target_user, channel, message, schedule, tz, next_run)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id`
+
+	// $1 = id $2 = job_id $3 = next_run
+	sqlSetJob = `
+	UPDATE module_reminders_reminders
+	SET job_id = $2, next_run = $3
+	WHERE id = $1`
+
+	// $1 = id
+	sqlGet = `
+	SELECT id, created_by, target_user, channel, message, schedule, tz, next_run, job_id
+	FROM module_reminders_reminders
+	WHERE id = $1`
+
+	// $1 = id
+	sqlDelete = `DELETE FROM module_reminders_reminders WHERE id = $1`
+
+	// $1 = user
+	sqlCountByUser = `SELECT COUNT(*) FROM module_reminders_reminders WHERE created_by = $1`
+
+	// $1 = user
+	sqlListByUser = `
+	SELECT id, created_by, target_user, channel, message, schedule, tz, next_run, job_id
+	FROM module_reminders_reminders
+	WHERE created_by = $1 OR target_user = $1
+	ORDER BY next_run`
+)
+
+type reminder struct {
+	ID         int64
+	CreatedBy  slack.UserID
+	TargetUser slack.UserID
+	Channel    slack.ChannelID
+	Message    string
+	Schedule   string
+	TZ         string
+	NextRun    time.Time
+	JobID      int64
+}
+
+// deliverJob is the payload of a deliver job.
+type deliverJob struct {
+	ID int64 `json:"id"`
+}
+
+func scanReminder(row interface {
+	Scan(dest ...interface{}) error
+}) (reminder, error) {
+	var r reminder
+	err := row.Scan(&r.ID, &r.CreatedBy, &r.TargetUser, &r.Channel, &r.Message,
+		&r.Schedule, &r.TZ, &r.NextRun, &r.JobID)
+	return r, err
+}
+
+func (mod *RemindersModule) getReminder(id int64) (reminder, error) {
+	return scanReminder(mod.team.DB().QueryRow(sqlGet, id))
+}
+
+func (mod *RemindersModule) listReminders(user slack.UserID) ([]reminder, error) {
+	rows, err := mod.team.DB().Query(sqlListByUser, string(user))
+	if err != nil {
+		return nil, errors.Wrap(err, "list reminders")
+	}
+	defer rows.Close()
+
+	var result []reminder
+	for rows.Next() {
+		r, err := scanReminder(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "list reminders")
+		}
+		result = append(result, r)
+	}
+	return result, errors.Wrap(rows.Err(), "list reminders")
+}
+
+// schedule queues the delivery of r at r.NextRun.
+func (mod *RemindersModule) schedule(r reminder) error {
+	jobID, err := mod.team.ScheduleJob(Identifier, jobDeliver, r.NextRun, deliverJob{ID: r.ID})
+	if err != nil {
+		return err
+	}
+	_, err = mod.team.DB().Exec(sqlSetJob, r.ID, jobID, r.NextRun)
+	return errors.Wrap(err, "save reminder job")
+}
+
+// userLocation returns the timezone from the user's Slack profile.
+func userLocation(t marvin.Team, user slack.UserID) *time.Location {
+	info, err := t.UserInfo(user)
+	if err != nil || info.Tz == "" {
+		return util.TZ42USA()
+	}
+	loc, err := time.LoadLocation(info.Tz)
+	if err != nil {
+		return util.TZ42USA()
+	}
+	return loc
+}
+
+func (mod *RemindersModule) deliver(t marvin.Team, job *marvin.Job) error {
+	var v deliverJob
+	err := job.Decode(&v)
+	if err != nil {
+		return errors.Wrap(err, "reminder: bad payload")
+	}
+	r, err := mod.getReminder(v.ID)
+	if err == sql.ErrNoRows || (err == nil && r.JobID != 0 && r.JobID != job.ID) {
+		// Cancelled, or replaced by a newer job
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "load reminder")
+	}
+
+	var channel slack.ChannelID
+	var msg string
+	switch {
+	case r.TargetUser == "":
+		channel = r.Channel
+		msg = fmt.Sprintf("Reminder from %v: %s", r.CreatedBy, r.Message)
+	case r.TargetUser == r.CreatedBy:
+		msg = fmt.Sprintf("Reminder: %s", r.Message)
+	default:
+		msg = fmt.Sprintf("%v asked me to remind you: %s", r.CreatedBy, r.Message)
+	}
+	if channel == "" {
+		channel, err = t.GetIM(r.TargetUser)
+		if err != nil {
+			return errors.Wrap(err, "open IM for reminder")
+		}
+	}
+	if r.Schedule != "" {
+		msg += fmt.Sprintf(" _(reminder %d, repeats `%s`)_", r.ID, r.Schedule)
+	}
+	// The text was written by a user, so it must not ping the channel
+	_, _, err = t.SendMessage(channel, atcommand.SanitizeForChannel(msg))
+	if err != nil {
+		return errors.Wrap(err, "send reminder")
+	}
+
+	if r.Schedule == "" {
+		_, err = t.DB().Exec(sqlDelete, r.ID)
+		return errors.Wrap(err, "delete reminder")
+	}
+	loc, err := time.LoadLocation(r.TZ)
+	if err != nil {
+		loc = util.TZ42USA()
+	}
+	sched, err := marvin.ParseSchedule(r.Schedule)
+	if err != nil {
+		return errors.Wrapf(err, "reminder %d", r.ID)
+	}
+	r.NextRun = sched.Next(time.Now().In(loc))
+	return mod.schedule(r)
+}
+
+// ---
+
+func (mod *RemindersModule) CommandRemind(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) == 0 {
+		return marvin.CmdUsage(args, helpRemind).WithSimpleUndo()
+	}
+	switch args.Arguments[0] {
+	case "list":
+		return mod.CommandList(t, args)
+	case "cancel":
+		return mod.CommandCancel(t, args)
+	}
+	return mod.CommandAdd(t, args)
+}
+
+func (mod *RemindersModule) CommandAdd(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) < 3 {
+		return marvin.CmdUsage(args, helpRemind).WithSimpleUndo()
+	}
+	self := args.Source.UserID()
+	r := reminder{CreatedBy: self}
+
+	targetArg := args.Arguments[0]
+	switch {
+	case targetArg == "me":
+		r.TargetUser = self
+	case strings.HasPrefix(targetArg, "#") || strings.HasPrefix(targetArg, "<#"):
+		r.Channel = t.ResolveChannelName(targetArg)
+		if r.Channel == "" {
+			return marvin.CmdFailuref(args, "No such channel '%s'.", targetArg).WithSimpleUndo()
+		}
+		if !t.UserInChannels(self, r.Channel)[r.Channel] {
+			return marvin.CmdFailuref(args, "You can only set reminders for channels you are in.").WithSimpleUndo()
+		}
+	default:
+		r.TargetUser = t.ResolveUserName(targetArg)
+		if r.TargetUser == "" {
+			return marvin.CmdFailuref(args, "Who is '%s'? Remind `me`, `@user` or `#channel`.", targetArg).WithSimpleUndo()
+		}
+	}
+
+	loc := userLocation(t, self)
+	words := make([]string, len(args.Arguments)-1)
+	for i, v := range args.Arguments[1:] {
+		words[i] = strings.ToLower(v)
+	}
+	w, n, err := parseWhen(words, time.Now().In(loc))
+	if err != nil {
+		return marvin.CmdFailuref(args, "%v", err).WithSimpleUndo()
+	}
+	rest := args.Arguments[1+n:]
+	if len(rest) > 0 && strings.ToLower(rest[0]) == "to" {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return marvin.CmdFailuref(args, "What should the reminder say?").WithSimpleUndo()
+	}
+	r.Message = strings.Join(rest, " ")
+	r.Schedule = w.Schedule
+	r.TZ = loc.String()
+	r.NextRun = w.At
+	if r.NextRun.IsZero() {
+		return marvin.CmdFailuref(args, "That time never comes.").WithSimpleUndo()
+	}
+
+	var count int
+	err = t.DB().QueryRow(sqlCountByUser, string(self)).Scan(&count)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	if count >= maxPerUser {
+		return marvin.CmdFailuref(args, "You already have %d reminders. Cancel some first.", count).WithSimpleUndo()
+	}
+
+	err = t.DB().QueryRow(sqlInsert, string(r.CreatedBy), string(r.TargetUser), string(r.Channel),
+		r.Message, r.Schedule, r.TZ, r.NextRun).Scan(&r.ID)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	err = mod.schedule(r)
+	if err != nil {
+		t.DB().Exec(sqlDelete, r.ID)
+		return marvin.CmdError(args, err, "Could not schedule reminder")
+	}
+
+	var who string
+	switch {
+	case r.TargetUser == self:
+		who = "you"
+	case r.TargetUser != "":
+		who = "@" + t.UserName(r.TargetUser)
+	default:
+		who = t.ChannelName(r.Channel)
+	}
+	msg := fmt.Sprintf("Okay, I'll remind %s at %s (in %v).", who,
+		r.NextRun.Format(timeFormat), time.Until(r.NextRun).Round(time.Minute))
+	if r.Schedule != "" {
+		msg += fmt.Sprintf(" It repeats `%s`.", r.Schedule)
+	}
+	msg += fmt.Sprintf(" Cancel with `@marvin remind cancel %d`.", r.ID)
+	return marvin.CmdSuccess(args, msg).WithNoUndo()
+}
+
+func (mod *RemindersModule) CommandList(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	self := args.Source.UserID()
+	list, err := mod.listReminders(self)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	if len(list) == 0 {
+		return marvin.CmdSuccess(args, "You have no reminders.").WithSimpleUndo()
+	}
+
+	loc := userLocation(t, self)
+	var buf bytes.Buffer
+	for _, r := range list {
+		fmt.Fprintf(&buf, "`%d` %s", r.ID, r.NextRun.In(loc).Format(timeFormat))
+		switch {
+		case r.TargetUser == "":
+			fmt.Fprintf(&buf, " in %s", t.ChannelName(r.Channel))
+		case r.TargetUser != self:
+			fmt.Fprintf(&buf, " for @%s", t.UserName(r.TargetUser))
+		case r.CreatedBy != self:
+			fmt.Fprintf(&buf, " from @%s", t.UserName(r.CreatedBy))
+		}
+		if r.Schedule != "" {
+			fmt.Fprintf(&buf, " (repeats `%s`)", r.Schedule)
+		}
+		fmt.Fprintf(&buf, ": %s\n", r.Message)
+	}
+	return marvin.CmdSuccess(args, strings.TrimSuffix(buf.String(), "\n")).WithSimpleUndo()
+}
+
+func (mod *RemindersModule) CommandCancel(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
+	if len(args.Arguments) != 2 {
+		return marvin.CmdUsage(args, "`remind cancel <id>` cancels a reminder. See `remind list` for the IDs.").WithSimpleUndo()
+	}
+	id, err := strconv.ParseInt(args.Arguments[1], 10, 64)
+	if err != nil {
+		return marvin.CmdFailuref(args, "'%s' is not a reminder ID.", args.Arguments[1]).WithSimpleUndo()
+	}
+	r, err := mod.getReminder(id)
+	if err == sql.ErrNoRows {
+		return marvin.CmdFailuref(args, "No reminder with ID %d.", id).WithSimpleUndo()
+	} else if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	self := args.Source.UserID()
+	if r.CreatedBy != self && r.TargetUser != self && args.Source.AccessLevel() < marvin.AccessLevelAdmin {
+		return marvin.CmdFailuref(args, "Reminder %d is not yours to cancel.", id).WithSimpleUndo()
+	}
+
+	_, err = t.DB().Exec(sqlDelete, id)
+	if err != nil {
+		return marvin.CmdError(args, err, "Database error")
+	}
+	if r.JobID != 0 {
+		util.LogIfError(t.CancelJob(r.JobID))
+	}
+	return marvin.CmdSuccess(args, fmt.Sprintf("Cancelled reminder %d: %s", id, r.Message)).WithNoUndo()
+}
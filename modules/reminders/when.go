@@ -0,0 +1,253 @@
+package reminders
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+)
+
+// minRepeat is the shortest interval accepted for "every <duration>".
+const minRepeat = 15 * time.Minute
+
+// defaultHour is used when a day is given without a time of day.
+const defaultHour = 9
+
+// when is the result of parseWhen.
+type when struct {
+	At time.Time
+	// Schedule is a marvin.ParseSchedule spec for repeating reminders.
+	Schedule string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var units = map[string]time.Duration{
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+var rgxTimeOfDay = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+// parseWhen reads a time from the start of words, which are lowercased.
+// now must be in the location the time is meant in. It returns the number
+// of words used.
+//
+// Accepted forms:
+//
+//	in 2h30m | in 2 hours 10 minutes
+//	[today|tomorrow|friday|2006-01-02] [at] 9am|9:30pm|14:00|noon|midnight
+//	every 2h | every day|weekday|monday [at] <time>
+func parseWhen(words []string, now time.Time) (when, int, error) {
+	if len(words) == 0 {
+		return when{}, 0, errors.New("missing a time")
+	}
+	switch words[0] {
+	case "in":
+		d, n := parseDuration(words[1:])
+		if n == 0 {
+			return when{}, 0, errors.Errorf("'%s' is not a duration, like `2h30m` or `2 hours`", strings.Join(words[1:], " "))
+		}
+		return when{At: now.Add(d)}, n + 1, nil
+	case "every":
+		return parseEvery(words[1:], now)
+	}
+
+	var day time.Time
+	var hasDay bool
+	i := 0
+	switch w := words[0]; {
+	case w == "today":
+		day, hasDay = now, true
+	case w == "tomorrow":
+		day, hasDay = now.AddDate(0, 0, 1), true
+	case weekdayOK(w):
+		// the weekday itself is resolved after the time of day
+		hasDay = true
+	default:
+		if d, err := time.ParseInLocation("2006-01-02", w, now.Location()); err == nil {
+			day, hasDay = d, true
+		}
+	}
+	if hasDay {
+		i++
+	}
+
+	hour, min, n := parseTimeOfDay(words[i:])
+	if n == 0 && !hasDay {
+		return when{}, 0, errors.Errorf("'%s' is not a time I understand. Try `in 2h`, `tomorrow 9am` or `friday 14:00`.", words[0])
+	}
+	i += n
+	if n == 0 {
+		hour, min = defaultHour, 0
+	}
+
+	if wd, ok := weekdays[words[0]]; ok {
+		at := atTime(now, hour, min)
+		for at.Weekday() != wd || !at.After(now) {
+			at = atTime(at.AddDate(0, 0, 1), hour, min)
+		}
+		return when{At: at}, i, nil
+	}
+	if !hasDay {
+		at := atTime(now, hour, min)
+		if !at.After(now) {
+			at = atTime(now.AddDate(0, 0, 1), hour, min)
+		}
+		return when{At: at}, i, nil
+	}
+	at := atTime(day, hour, min)
+	if !at.After(now) {
+		return when{}, 0, errors.Errorf("%s is in the past", at.Format(timeFormat))
+	}
+	return when{At: at}, i, nil
+}
+
+func parseEvery(words []string, now time.Time) (when, int, error) {
+	if len(words) == 0 {
+		return when{}, 0, errors.New("every what?")
+	}
+	if d, n := parseDuration(words); n > 0 {
+		if d < minRepeat {
+			return when{}, 0, errors.Errorf("reminders can repeat at most every %v", minRepeat)
+		}
+		return makeEvery(fmt.Sprintf("@every %v", d), now, n+1)
+	}
+
+	var dow string
+	switch w := words[0]; {
+	case w == "day":
+		dow = "*"
+	case w == "weekday":
+		dow = "1-5"
+	case weekdayOK(w):
+		dow = strconv.Itoa(int(weekdays[w]))
+	default:
+		return when{}, 0, errors.Errorf("'every %s' is not a schedule I understand. Try `every day 9am`, `every monday 14:00` or `every 2h`.", w)
+	}
+	hour, min, n := parseTimeOfDay(words[1:])
+	if n == 0 {
+		hour, min = defaultHour, 0
+	}
+	return makeEvery(fmt.Sprintf("%d %d * * %s", min, hour, dow), now, n+2)
+}
+
+func makeEvery(spec string, now time.Time, n int) (when, int, error) {
+	sched, err := marvin.ParseSchedule(spec)
+	if err != nil {
+		return when{}, 0, err
+	}
+	return when{At: sched.Next(now), Schedule: spec}, n, nil
+}
+
+func weekdayOK(w string) bool {
+	_, ok := weekdays[w]
+	return ok
+}
+
+// parseDuration reads "2h30m" or "2 hours 30 minutes" style durations.
+func parseDuration(words []string) (time.Duration, int) {
+	var total time.Duration
+	i := 0
+	for i < len(words) {
+		if d, err := time.ParseDuration(words[i]); err == nil && d > 0 {
+			total += d
+			i++
+			continue
+		}
+		if i+1 >= len(words) {
+			break
+		}
+		unit, ok := units[words[i+1]]
+		if !ok {
+			break
+		}
+		var count int
+		if words[i] == "a" || words[i] == "an" {
+			count = 1
+		} else if c, err := strconv.Atoi(words[i]); err == nil && c > 0 {
+			count = c
+		} else {
+			break
+		}
+		total += time.Duration(count) * unit
+		i += 2
+		if i < len(words) && words[i] == "and" && i+1 < len(words) {
+			if _, err := strconv.Atoi(words[i+1]); err == nil {
+				i++
+			}
+		}
+	}
+	return total, i
+}
+
+// parseTimeOfDay reads "[at] 9am", "9:30 pm", "14:00", "noon" or "midnight".
+// A bare number is only taken as an hour after "at".
+func parseTimeOfDay(words []string) (hour, min, n int) {
+	i := 0
+	at := len(words) > 0 && words[0] == "at"
+	if at {
+		i++
+	}
+	if i >= len(words) {
+		return 0, 0, 0
+	}
+	switch words[i] {
+	case "noon":
+		return 12, 0, i + 1
+	case "midnight":
+		return 0, 0, i + 1
+	}
+	m := rgxTimeOfDay.FindStringSubmatch(words[i])
+	if m == nil {
+		return 0, 0, 0
+	}
+	i++
+	hour, _ = strconv.Atoi(m[1])
+	min, _ = strconv.Atoi(m[2])
+	ampm := m[3]
+	if ampm == "" && i < len(words) && (words[i] == "am" || words[i] == "pm") {
+		ampm = words[i]
+		i++
+	}
+	if ampm == "" && m[2] == "" && !at {
+		return 0, 0, 0
+	}
+	if min > 59 {
+		return 0, 0, 0
+	}
+	switch ampm {
+	case "":
+		if hour > 23 {
+			return 0, 0, 0
+		}
+	default:
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0
+		}
+		hour %= 12
+		if ampm == "pm" {
+			hour += 12
+		}
+	}
+	return hour, min, i
+}
+
+func atTime(day time.Time, hour, min int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, day.Location())
+}
@@ -0,0 +1,51 @@
+package reminders
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// Wednesday afternoon
+	now := time.Date(2026, time.January, 7, 15, 20, 0, 0, time.UTC)
+	day := func(d, h, m int) time.Time {
+		return time.Date(2026, time.January, d, h, m, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		input    string
+		at       time.Time
+		schedule string
+		used     int
+	}{
+		{"in 2h30m to eat", now.Add(150 * time.Minute), "", 2},
+		{"in 2 hours and 10 minutes stretch", now.Add(130 * time.Minute), "", 6},
+		{"in a day check", now.Add(24 * time.Hour), "", 3},
+		{"at 5pm leave", day(7, 17, 0), "", 2},
+		{"9:30 am standup", day(8, 9, 30), "", 2},
+		{"tomorrow to call", day(8, 9, 0), "", 1},
+		{"tomorrow at noon lunch", day(8, 12, 0), "", 3},
+		{"friday 14:00 demo", day(9, 14, 0), "", 2},
+		{"wednesday 10am next week", day(14, 10, 0), "", 2},
+		{"2026-02-01 18:00 party", time.Date(2026, time.February, 1, 18, 0, 0, 0, time.UTC), "", 2},
+		{"every day 9am water plants", day(8, 9, 0), "0 9 * * *", 3},
+		{"every weekday at 16:00 log hours", day(7, 16, 0), "0 16 * * 1-5", 4},
+		{"every 4h stand up", now.Add(4 * time.Hour), "@every 4h0m0s", 2},
+	}
+	for _, c := range cases {
+		w, n, err := parseWhen(strings.Fields(c.input), now)
+		if err != nil {
+			t.Errorf("%q: %v", c.input, err)
+			continue
+		}
+		if !w.At.Equal(c.at) || w.Schedule != c.schedule || n != c.used {
+			t.Errorf("%q: got %v %q %d, expected %v %q %d", c.input, w.At, w.Schedule, n, c.at, c.schedule, c.used)
+		}
+	}
+
+	for _, input := range []string{"soon", "in a while", "5 things", "2025-01-01 old", "every 1m", "at 25:00"} {
+		if _, _, err := parseWhen(strings.Fields(input), now); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package restart
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 
 	"github.com/riking/marvin"
@@ -13,6 +16,8 @@ var recompileSemaphore = make(chan struct{}, 1)
 
 const Identifier = "restart"
 
+var PermRestart = marvin.Permission{Name: "restart", Level: marvin.AccessLevelController}
+
 func init() {
 	marvin.RegisterModule(NewRestartModule)
 	recompileSemaphore <- struct{}{}
@@ -37,23 +42,19 @@ func (mod *RestartModule) Load(t marvin.Team) {
 }
 
 func (mod *RestartModule) Enable(team marvin.Team) {
-	team.RegisterCommandFunc("restart", mod.RestartCommand,
+	team.RegisterCommandFuncPerm("restart", mod.RestartCommand,
 		"`@marvin restart`"+
-			"This restarts the active Marvin instance.\n")
-	team.RegisterCommandFunc("recompile", mod.RecompileCommand,
+			"This restarts the active Marvin instance.\n", PermRestart)
+	team.RegisterCommandFuncPerm("recompile", mod.RecompileCommand,
 		"`@marvin recompile [restart]`"+
 			"This recompiles Marvin, pulling the latest changes.\n"+
-			"With optional parameter, restarts the server after a successful compile.\n")
+			"With optional parameter, restarts the server after a successful compile.\n", PermRestart)
 }
 
 func (mod *RestartModule) Disable(t marvin.Team) {
 }
 
 func (mod *RestartModule) RecompileCommand(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
-	if args.Source.AccessLevel() < marvin.AccessLevelController {
-		return marvin.CmdFailuref(args, "This command is restricted to controllers only.")
-	}
-
 	// This will check if it can take a buffer slot, if not, it means there's a recompile in progress.
 	// Otherwise it will recompile.
 	select {
@@ -63,27 +64,31 @@ func (mod *RestartModule) RecompileCommand(t marvin.Team, args *marvin.CommandAr
 		return marvin.CmdFailuref(args, "There is a recompile in progress.")
 	}
 
-	// defer reinserting the token until the recompile command is finished.
-	defer func() { recompileSemaphore <- struct{}{} }()
-	stdout, err := mod.Recompile()
-	if err != nil {
-		return marvin.CmdError(args, err, fmt.Sprintf("Failed to recompile: \n%s", stdout))
-	}
-
-	mod.team.SendMessage(mod.team.TeamConfig().LogChannel, fmt.Sprintf("Successfully recompiled: \n%s", stdout))
-
-	if len(args.Arguments) == 1 && args.Arguments[0] == "restart" {
-		go mod.Restart()
-		return marvin.CmdSuccess(args, "Successfully recompiled, restarting.")
-	}
-	return marvin.CmdSuccess(args, "Successfully recompiled; not restarting.")
+	return marvin.CmdAsync(args, "Recompiling...", func(ctx context.Context, progress marvin.ProgressFunc) marvin.CommandResult {
+		// defer reinserting the token until the recompile command is finished.
+		defer func() { recompileSemaphore <- struct{}{} }()
+		stdout, err := mod.Recompile(ctx, progress)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "recompile", After: "failed"})
+			return marvin.CmdError(args, err, fmt.Sprintf("Failed to recompile: \n%s", stdout))
+		}
+
+		mod.team.SendMessage(mod.team.TeamConfig().LogChannel, fmt.Sprintf("Successfully recompiled: \n%s", stdout))
+		t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "recompile", After: "ok"})
+
+		if len(args.Arguments) == 1 && args.Arguments[0] == "restart" {
+			t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "restart"})
+			go mod.Restart()
+			return marvin.CmdSuccess(args, "Successfully recompiled, restarting.")
+		}
+		return marvin.CmdSuccess(args, "Successfully recompiled; not restarting.")
+	})
 }
 
 func (mod *RestartModule) RestartCommand(t marvin.Team, args *marvin.CommandArguments) marvin.CommandResult {
-	if args.Source.AccessLevel() < marvin.AccessLevelController {
-		return marvin.CmdFailuref(args, "This command is restricted to controllers only.")
-	}
-
 	select {
 	case <-recompileSemaphore:
 		break
@@ -93,16 +98,42 @@ func (mod *RestartModule) RestartCommand(t marvin.Team, args *marvin.CommandArgu
 
 	defer func() { recompileSemaphore <- struct{}{} }()
 
+	if !args.Confirmed {
+		return marvin.CmdConfirm(args, "This will restart Marvin.")
+	}
+	t.Audit(args.Source, marvin.AuditEntry{Module: Identifier, Action: "restart"})
 	go mod.Restart()
 	return marvin.CmdSuccess(args, "Restarting, be back soon.")
 }
 
 // Execute the shell script located in $HOME/marvin/build (with +x perms).
-func (mod *RestartModule) Recompile() (string, error) {
-	cmd := exec.Command(os.Getenv("HOME") + "/marvin/build.sh")
-	stdout, err := cmd.CombinedOutput()
+// The last line of output is reported as progress.
+func (mod *RestartModule) Recompile(ctx context.Context, progress marvin.ProgressFunc) (string, error) {
+	cmd := exec.CommandContext(ctx, os.Getenv("HOME")+"/marvin/build.sh")
+	out := &progressWriter{progress: progress}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	stdout := out.buf.String()
 	fmt.Printf("Recompile output: \n%s", stdout)
-	return string(stdout), err
+	return stdout, err
+}
+
+type progressWriter struct {
+	buf      bytes.Buffer
+	progress marvin.ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	output := strings.TrimSpace(w.buf.String())
+	if i := strings.LastIndexByte(output, '\n'); i != -1 {
+		output = output[i+1:]
+	}
+	if output != "" {
+		w.progress(fmt.Sprintf("Recompiling... `%s`", output))
+	}
+	return len(p), nil
 }
 
 // This sends the SIGINT signal to itself so the proper shutdown procedures can be run from the main.
@@ -65,8 +65,8 @@ func (t *FacebookType) TypeID() TypeID { return feedTypeFacebook }
 func (t *FacebookType) Name() string   { return "facebook" }
 
 func (t *FacebookType) OnLoad(mod *RSSModule) {
-	mod.Config().AddProtect("facebook-clientid", "", true)
-	mod.Config().AddProtect("facebook-clientsecret", "", true)
+	mod.Config().AddTyped("facebook-clientid", "", marvin.ConfigSchema{Description: "Facebook app ID", Protected: true})
+	mod.Config().AddTyped("facebook-clientsecret", "", marvin.ConfigSchema{Description: "Facebook app secret", Protected: true})
 	mod.Config().OnModify(func(key string) {
 		if strings.HasPrefix(key, "facebook-") {
 			// key changed, invalidate cache
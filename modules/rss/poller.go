@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/metrics"
+	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/util"
 )
 
+var metricPollTime = metrics.NewHistogramVec("marvin_rss_poll_duration_seconds",
+	"Time taken to poll a feed, by feed type.", metrics.DefBuckets, "type")
+
 type poller struct {
 	mod *RSSModule
 }
@@ -20,12 +26,12 @@ func (p *poller) reportError(err error) {
 	p.mod.team.SendMessage(p.mod.team.TeamConfig().LogChannel, fmt.Sprintf("[RSS Poller] Error: %+v", err))
 }
 
-func (p *poller) Run() {
-	for {
-		p.pollAll()
-		util.LogGood("[RSS] poll complete")
-		time.Sleep(15 * time.Minute)
-	}
+const jobPoll = "poll"
+
+func (p *poller) runJob(t marvin.Team, job *marvin.Job) error {
+	p.pollAll()
+	util.LogGood("[RSS] poll complete")
+	return nil
 }
 
 func (p *poller) pollAll() {
@@ -40,7 +46,9 @@ func (p *poller) pollAll() {
 			util.LogWarnf("[RSS] Unknown feed type %d (%c:%s)", ft, ft, v.FeedID)
 			continue
 		}
+		start := time.Now()
 		_, err := p.pollFeed(ft, v.FeedID)
+		metricPollTime.With(ft.Name()).ObserveSince(start)
 		if err != nil {
 			util.LogBadf("[RSS] Error polling feed %c:%s\n%+v", ft, v.FeedID, err)
 			continue
@@ -94,8 +102,12 @@ func (p *poller) pollFeed(t FeedType, feedID string) (time.Duration, error) {
 		}
 
 		slMessage := items[i].Render(meta)
+		slMessage.Priority = slack.PriorityLow
 		for _, ch := range channelList {
-			go p.mod.team.SendComplexMessage(ch.Channel, slMessage)
+			_, _, err := p.mod.team.SendComplexMessage(ch.Channel, slMessage)
+			if err != nil {
+				util.LogBadf("[RSS] Error posting feed %c:%s to %s: %v", t.TypeID(), feedID, ch.Channel, err)
+			}
 		}
 		p.mod.DB().MarkSeen(t.TypeID(), feedID, items[i].ItemID())
 	}
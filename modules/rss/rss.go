@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
 )
 
 func init() {
@@ -70,7 +71,8 @@ func (mod *RSSModule) Load(t marvin.Team) {
 }
 
 func (mod *RSSModule) Enable(t marvin.Team) {
-	go mod.poller.Run()
+	t.RegisterJobHandler(Identifier, jobPoll, mod.poller.runJob)
+	util.LogIfError(t.ScheduleRecurringJob(Identifier, jobPoll, "@every 15m", nil))
 
 	parent := marvin.NewParentCommand()
 	subscribe := parent.RegisterCommandFunc("subscribe", mod.CommandSubscribe, usageSubscribe)
@@ -331,6 +333,11 @@ func (mod *RSSModule) CommandRemove(t marvin.Team, args *marvin.CommandArguments
 		feedID = arg[colon+1:]
 	}
 
+	if !args.Confirmed {
+		return marvin.CmdConfirm(args, fmt.Sprintf("This will unsubscribe %v from `%s:%s`.",
+			t.FormatChannel(targetChannel), mod.GetFeedTypeName(feedTypeID), feedID))
+	}
+
 	found, err := mod.DB().Unsubscribe(feedTypeID, feedID, targetChannel)
 	if err != nil {
 		return marvin.CmdError(args, err, "Database error")
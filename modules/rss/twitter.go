@@ -73,10 +73,10 @@ func (t *TwitterType) TypeID() TypeID { return feedTypeTwitter }
 func (t *TwitterType) Name() string   { return "twitter" }
 
 func (t *TwitterType) OnLoad(mod *RSSModule) {
-	mod.Config().AddProtect("twitter-clientid", "", true)
-	mod.Config().AddProtect("twitter-clientsecret", "", true)
-	mod.Config().AddProtect("twitter-token", "", true)
-	mod.Config().AddProtect("twitter-tokensecret", "", true)
+	mod.Config().AddTyped("twitter-clientid", "", marvin.ConfigSchema{Description: "Twitter consumer key", Protected: true})
+	mod.Config().AddTyped("twitter-clientsecret", "", marvin.ConfigSchema{Description: "Twitter consumer secret", Protected: true})
+	mod.Config().AddTyped("twitter-token", "", marvin.ConfigSchema{Description: "Twitter access token", Protected: true})
+	mod.Config().AddTyped("twitter-tokensecret", "", marvin.ConfigSchema{Description: "Twitter access token secret", Protected: true})
 	mod.Config().OnModify(func(key string) {
 		if strings.HasPrefix(key, "twitter-") {
 			// key changed, invalidate cache
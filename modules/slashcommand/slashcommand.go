@@ -0,0 +1,235 @@
+package slashcommand
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/modules/atcommand"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+func init() {
+	marvin.RegisterModule(NewSlashCommandModule)
+}
+
+const Identifier = "slashcommand"
+
+// The slash command should be configured in the Slack app with this path as
+// its Request URL.
+const slashCommandPath = "/slack/command"
+
+// Slack gives up on the HTTP response after 3 seconds. Commands that take
+// longer than this are answered through the response_url instead.
+const immediateReplyDeadline = 2500 * time.Millisecond
+
+type SlashCommandModule struct {
+	team marvin.Team
+}
+
+func NewSlashCommandModule(t marvin.Team) marvin.Module {
+	mod := &SlashCommandModule{
+		team: t,
+	}
+	return mod
+}
+
+func (mod *SlashCommandModule) Identifier() marvin.ModuleID {
+	return Identifier
+}
+
+func (mod *SlashCommandModule) Load(t marvin.Team) {
+	// Routes cannot be removed, so the handler checks that the module is enabled
+	t.Router().Path(slashCommandPath).Methods(http.MethodPost).HandlerFunc(mod.HTTPCommand)
+}
+
+func (mod *SlashCommandModule) Enable(t marvin.Team) {
+}
+
+func (mod *SlashCommandModule) Disable(t marvin.Team) {
+}
+
+// ---
+
+// ActionSourceSlashCommand is the ActionSource for commands run with a Slack
+// slash command.
+type ActionSourceSlashCommand struct {
+	Team    marvin.Team
+	Request slack.SlashCommandRequest
+}
+
+func (sc ActionSourceSlashCommand) UserID() slack.UserID          { return sc.Request.UserId }
+func (sc ActionSourceSlashCommand) ChannelID() slack.ChannelID    { return sc.Request.ChannelId }
+func (sc ActionSourceSlashCommand) MsgTimestamp() slack.MessageTS { return "" }
+func (sc ActionSourceSlashCommand) ArchiveLink() string           { return "" }
+func (sc ActionSourceSlashCommand) AccessLevel() marvin.AccessLevel {
+	return sc.Team.UserLevel(sc.Request.UserId)
+}
+
+// ---
+
+func (mod *SlashCommandModule) HTTPCommand(w http.ResponseWriter, r *http.Request) {
+	if !mod.team.GetModuleStatus(Identifier).IsEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := slack.VerifyRequest(r, []byte(mod.team.TeamConfig().SigningSecret))
+	if err != nil {
+		util.LogBad("slash command:", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := slack.ParseSlashCommand(form)
+	source := ActionSourceSlashCommand{Team: mod.team, Request: req}
+
+	if source.AccessLevel() < marvin.AccessLevelNormal {
+		writeResponse(w, slack.SlashCommandResponse{
+			OutgoingSlackMessage: slack.OutgoingSlackMessage{Text: "You are not allowed to use Marvin."},
+			ResponseType:         slack.ResponseTypeEphermal,
+		})
+		return
+	}
+
+	argSplit, splitErr := atcommand.ParseArgs(req.Text, 0)
+	if len(argSplit) == 1 && argSplit[0] == "" {
+		argSplit = []string{"help"}
+	}
+
+	resultCh := make(chan marvin.CommandResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		defer cancel()
+		args := &marvin.CommandArguments{
+			OriginalArguments: argSplit,
+			Arguments:         argSplit,
+			Command:           "",
+			Ctx:               ctx,
+			Source:            source,
+		}
+		if splitErr != nil {
+			resultCh <- marvin.CmdFailuref(args, splitErr.Error())
+			return
+		}
+		util.LogDebug("slash command args: [", strings.Join(argSplit, "] ["), "]")
+		resultCh <- marvin.WaitCommand(mod.team, mod.team.DispatchCommand(args))
+	}()
+
+	select {
+	case result := <-resultCh:
+		writeResponse(w, mod.buildResponse(result, source))
+	case <-time.After(immediateReplyDeadline):
+		// Acknowledge now, reply later
+		w.WriteHeader(http.StatusOK)
+		go func() {
+			result := <-resultCh
+			util.LogIfError(mod.sendDelayedResponse(req.ResponseURL, mod.buildResponse(result, source)))
+		}()
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp slack.SlashCommandResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (mod *SlashCommandModule) sendDelayedResponse(responseURL string, resp slack.SlashCommandResponse) error {
+	if !strings.HasPrefix(responseURL, "https://") {
+		return errors.Errorf("slash command: bad response_url '%s'", responseURL)
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return errors.Wrap(err, "slash command: encode response")
+	}
+	httpResp, err := http.Post(responseURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "slash command: post response")
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return errors.Errorf("slash command: response_url returned %s", httpResp.Status)
+	}
+	return nil
+}
+
+// buildResponse maps a CommandResult onto a slash command response. Only
+// successful results with an in-channel ReplyType are shown to the channel;
+// everything else goes to the invoking user alone.
+func (mod *SlashCommandModule) buildResponse(result marvin.CommandResult, source marvin.ActionSource) slack.SlashCommandResponse {
+	var resp slack.SlashCommandResponse
+	resp.ResponseType = slack.ResponseTypeEphermal
+
+	replyType := result.ReplyType
+	if replyType&marvin.ReplyTypeDestinations == marvin.ReplyTypeInvalid {
+		switch result.Code {
+		case marvin.CmdResultOK:
+			replyType |= marvin.ReplyTypeInChannel
+		case marvin.CmdResultFailure, marvin.CmdResultError:
+			replyType |= marvin.ReplyTypeShortProblem
+		default:
+			replyType |= marvin.ReplyTypePM
+		}
+	}
+
+	switch result.Code {
+	case marvin.CmdResultOK:
+		if result.Message == "" {
+			resp.Text = "Done."
+			break
+		}
+		resp.Text = atcommand.SanitizeForChannel(result.Message)
+		if replyType&marvin.ReplyTypeInChannel != 0 {
+			resp.ResponseType = slack.ResponseTypeInChannel
+		}
+	case marvin.CmdResultError:
+		if result.Message == "" {
+			result.Message = "Error"
+		}
+		resp.Text = fmt.Sprintf("%s: %v", result.Message, result.Err)
+		if replyType&marvin.ReplyTypeLog != 0 {
+			mod.logToChannel(fmt.Sprintf("Slash command from %v in <#%s>\n```\n%+v\n```",
+				source.UserID(), source.ChannelID(), result.Err))
+			util.LogError(result.Err)
+		}
+	case marvin.CmdResultNoSuchCommand:
+		prefix := ""
+		if sc, ok := source.(ActionSourceSlashCommand); ok {
+			prefix = sc.Request.Command + " "
+		}
+		didYouMean := marvin.FormatSuggestions(prefix, result.Suggestions)
+		if didYouMean != "" {
+			didYouMean += "\n"
+		}
+		resp.Text = fmt.Sprintf("I didn't quite understand that, sorry.\n%sYou said: [%s]",
+			didYouMean, strings.Join(result.Args.OriginalArguments, "] ["))
+	case marvin.CmdResultNeedsConfirm:
+		// There is no message to react to, so the prompt cannot be answered here
+		resp.Text = atcommand.SanitizeLoose(result.Message) + "\nThis command needs confirmation. Mention me with it in a channel instead."
+	default:
+		resp.Text = atcommand.SanitizeLoose(result.Message)
+	}
+	return resp
+}
+
+func (mod *SlashCommandModule) logToChannel(msg string) {
+	logChannel := mod.team.TeamConfig().LogChannel
+	if logChannel == "" {
+		return
+	}
+	_, _, err := mod.team.SendMessage(logChannel, atcommand.SanitizeForChannel(msg))
+	util.LogIfError(err)
+}
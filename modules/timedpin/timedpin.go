@@ -19,14 +19,12 @@ func init() {
 const Identifier = "timedpin"
 
 type TimedPinModule struct {
-	team     marvin.Team
-	notifyCh chan struct{}
+	team marvin.Team
 }
 
 func NewTimedPinModule(t marvin.Team) marvin.Module {
 	mod := &TimedPinModule{
-		team:     t,
-		notifyCh: make(chan struct{}, 1),
+		team: t,
 	}
 	return mod
 }
@@ -37,12 +35,7 @@ func (mod *TimedPinModule) Identifier() marvin.ModuleID {
 
 func (mod *TimedPinModule) Load(t marvin.Team) {
 	t.DB().MustMigrate(Identifier, 1486001919, sqlMigrate1, sqlMigrate1b)
-	t.DB().SyntaxCheck(
-		sqlGetNextUnpin,
-		sqlInsertTimedPin,
-		sqlGetCurrentUnpins,
-		sqlDeleteUnpin,
-	)
+	t.DB().MustMigrate(Identifier, 1792195200, sqlMigrate2, sqlMigrate2b)
 }
 
 const usage = "`@marvin timedpin &lt;duration: 10h30m&gt; &lt;slack archive link | \"last\"&gt;`\n" +
@@ -52,7 +45,7 @@ const usage = "`@marvin timedpin &lt;duration: 10h30m&gt; &lt;slack archive link
 func (mod *TimedPinModule) Enable(t marvin.Team) {
 	cmd := t.RegisterCommandFunc("timedpin", mod.CommandTimedPin, usage)
 	t.RegisterCommand("timed-pin", cmd)
-	go mod.unpinLoop()
+	t.RegisterJobHandler(Identifier, jobUnpin, mod.doUnpin)
 }
 
 func (mod *TimedPinModule) Disable(t marvin.Team) {
@@ -73,23 +66,14 @@ const (
 	)`
 	sqlMigrate1b = `CREATE INDEX idx_pins_by_time ON module_timedpin_pins (unpin_time)`
 
-	sqlGetNextUnpin = `SELECT MIN(unpin_time) as next_unpin FROM module_timedpin_pins`
-
-	// $1 = channel $2 = item ID $3 = time
-	sqlInsertTimedPin = `
-	INSERT INTO module_timedpin_pins
-	(channel, ts_or_file, unpin_time, original_duration, pinning_user)
-	VALUES ($1, $2, $3, $4, $5)`
-
-	sqlGetCurrentUnpins = `
-	SELECT id, channel, ts_or_file, original_duration, pinning_user
-	FROM module_timedpin_pins
-	WHERE unpin_time < CURRENT_TIMESTAMP`
-
-	// $1 = id
-	sqlDeleteUnpin = `
-	DELETE FROM module_timedpin_pins
-	WHERE id = $1`
+	// Unpins are now scheduled jobs
+	sqlMigrate2 = `
+	INSERT INTO jobs (module, name, payload, run_at)
+	SELECT 'timedpin', 'unpin', json_build_object(
+		'channel', channel, 'item', ts_or_file,
+		'duration', original_duration, 'user', pinning_user)::text, unpin_time
+	FROM module_timedpin_pins`
+	sqlMigrate2b = `DROP TABLE module_timedpin_pins`
 )
 
 // https://42schoolusa.slack.com/files/crenfrow/F40DWMBGW/cp2slj8.jpeg
@@ -135,11 +119,6 @@ func (mod *TimedPinModule) CommandTimedPin(t marvin.Team, args *marvin.CommandAr
 		return marvin.CmdFailuref(args, "'%s' isn't a thing I know how to pin.", thingArg).WithSimpleUndo()
 	}
 
-	stmt, err := t.DB().Prepare(sqlInsertTimedPin)
-	if err != nil {
-		return marvin.CmdFailuref(args, "database error: %s").WithSimpleUndo()
-	}
-	defer stmt.Close()
 	unpinTime := time.Now().Add(duration)
 
 	// Pin the thing
@@ -156,14 +135,15 @@ func (mod *TimedPinModule) CommandTimedPin(t marvin.Team, args *marvin.CommandAr
 		}
 	}
 
-	_, err = stmt.Exec(string(channelID), thingID, unpinTime, durationArg, string(args.Source.UserID()))
+	_, err = t.ScheduleJob(Identifier, jobUnpin, unpinTime, unpinJob{
+		Channel:      channelID,
+		ThingID:      thingID,
+		OrigDuration: durationArg,
+		SourceUser:   args.Source.UserID(),
+	})
 	if err != nil {
 		return marvin.CmdError(args, err, "Couldn't save record (needs manual unpin, cannot undo)").WithNoUndo()
 	}
-	select {
-	case mod.notifyCh <- struct{}{}:
-	default:
-	}
 
 	return marvin.CmdSuccess(args, fmt.Sprintf(
 		"Okay, %s will be unpinned in %s.", thingID, duration.String(),
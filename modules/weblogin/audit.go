@@ -0,0 +1,104 @@
+package weblogin
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+var ErrAuditForbidden = errors.New("You do not have permission to view the audit log.")
+
+var tmplAuditLog = template.Must(LayoutTemplateCopy().Parse(string(MustAsset("templates/audit-log.html"))))
+
+const auditPageSize = 100
+
+type bodyAuditLog struct {
+	team       marvin.Team
+	Entries    []marvin.AuditEntry
+	Filter     marvin.AuditFilter
+	FilterUser string
+	NextURL    string
+}
+
+func (d bodyAuditLog) Team() marvin.Team { return d.team }
+
+// IsSlackUser reports whether the actor can be linked to a Slack profile.
+func (d bodyAuditLog) IsSlackUser(actor slack.UserID) bool {
+	return actor != "" && !strings.Contains(string(actor), ":")
+}
+
+func (mod *WebLoginModule) ServeAuditLog(w http.ResponseWriter, r *http.Request) {
+	lc, err := NewLayoutContent(mod.team, w, r, NavSectionAudit)
+	if err != nil {
+		mod.HTTPError(w, r, err)
+		return
+	}
+	if lc.CurrentUser == nil {
+		w.WriteHeader(http.StatusForbidden)
+		mod.HTTPError(w, r, ErrNotLoggedIn)
+		return
+	}
+	if !mod.team.CheckPermission(ActionSourceWeb{Team: mod.team, User: lc.CurrentUser}, marvin.PermAuditView) {
+		w.WriteHeader(http.StatusForbidden)
+		mod.HTTPError(w, r, ErrAuditForbidden)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Bad form data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := bodyAuditLog{
+		team:       mod.team,
+		FilterUser: r.Form.Get("user"),
+		Filter: marvin.AuditFilter{
+			Module: marvin.ModuleID(r.Form.Get("module")),
+			Action: r.Form.Get("action"),
+			Limit:  auditPageSize,
+		},
+	}
+	if body.FilterUser != "" {
+		body.Filter.Actor = mod.team.ResolveUserName(body.FilterUser)
+		if body.Filter.Actor == "" {
+			// Web logins without Slack are recorded as "intra:login"
+			body.Filter.Actor = slack.UserID(body.FilterUser)
+		}
+	}
+	if before := r.Form.Get("before"); before != "" {
+		body.Filter.BeforeID, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad form data: before must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body.Entries, err = mod.team.ListAudit(body.Filter)
+	if err != nil {
+		mod.HTTPError(w, r, err)
+		return
+	}
+	if len(body.Entries) == auditPageSize {
+		next := url.Values{}
+		for _, k := range []string{"user", "module", "action"} {
+			if v := r.Form.Get(k); v != "" {
+				next.Set(k, v)
+			}
+		}
+		next.Set("before", strconv.FormatInt(body.Entries[len(body.Entries)-1].ID, 10))
+		body.NextURL = "/audit?" + next.Encode()
+	}
+
+	lc.Title = "Audit Log - Marvin"
+	lc.BodyData = body
+	util.LogIfError(tmplAuditLog.ExecuteTemplate(w, "layout", lc))
+}
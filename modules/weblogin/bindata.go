@@ -2,6 +2,7 @@
 // sources:
 // layout.html
 // assets/styles.css
+// templates/audit-log.html
 // templates/factoid-info.html
 // templates/factoid-list.html
 // templates/home.html
@@ -120,6 +121,26 @@ func assetsStylesCss() (*asset, error) {
 	return a, nil
 }
 
+var _templatesAuditLogHtml = "\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x9c\x54\x4d\x6f\xdc\x36\x10\xbd\xeb\x57\x0c\x04\x5f\x57\x4a\x50\xa0\x07\x97\xcb\xc2\x0d\xd2\xa2\x40\x9c\x16\x89\x83\x1e\x03\xae\x38\x2b\x11\xa6\x48\x81\x1a\xed\xda\x20\xf8\xdf\x0b\x92\x92\x56\x5e\xa7\x3d\xf8\x22\x70\x86\xc3\xf7\xe6\xe3\x8d\xbc\x97\x78\x54\x06\xa1\x1c\xe9\x59\xe3\x58\x86\x50\xb0\x74\xe4\x45\x25\x26\xa9\x68\x77\x54\x9a\xd0\x81\x32\xc3\x44\xe0\x0b\x00\x80\x5e\xb8\x56\x99\x9d\x53\x6d\x47\xb7\xf0\xf3\xf0\xf4\x4b\x11\x0a\x12\x07\x8d\xf3\x1b\x6d\x5b\x20\x59\x9d\x84\x9e\x70\x7d\xf3\xb4\x3b\x2b\x49\xdd\x2d\xfc\xf4\xee\x5d\x7c\x12\x91\xec\x09\xdd\x51\xdb\xf3\xee\xec\xc4\x70\x0b\x07\x87\xe2\x71\x77\xb6\x4e\x46\x44\x56\xcf\x99\x78\x8f\x46\x86\x50\x5c\xb2\x6d\xac\x21\x34\x94\xd2\x95\xea\x04\x8d\x16\xe3\xb8\x4f\x6e\xa1\x0c\xba\x92\xbf\xf0\x0f\xa2\xc5\x5d\x87\x42\xa6\x9b\x48\xcc\xba\xf7\xfc\x2e\xe6\x0a\x9f\x6c\xcb\xea\xee\x3d\x67\x63\x2f\xb4\xe6\x7f\x3b\x75\x52\x1a\x5b\x94\x20\x1a\x52\xd6\x8c\x40\xe2\x11\x0d\x50\xe7\xec\xd4\x76\x70\x2f\xdc\x49\x19\x56\xe7\xf0\x82\xd5\x52\x9d\x78\x51\xb0\xa3\x75\xfd\xc2\x17\xcf\x3b\x65\x74\xec\xec\xb6\x8b\x25\xf4\x48\x9d\x95\xfb\xf2\x8f\x8f\x0f\xe5\x4c\xb0\x2f\xeb\x14\xb3\x64\x96\x3b\xbd\x45\x8a\x65\x39\xab\x4b\xa0\xe7\x01\xf7\x25\xe1\x13\x95\x60\x44\x8f\xfb\x72\x1a\x23\xea\xa0\x45\x83\x9d\xd5\x12\xdd\xe2\x4a\xbd\xdf\x97\xde\x57\xbf\xa7\x01\x7e\x1b\xd1\x85\xf0\x26\x8e\xde\xca\x49\xe3\x15\xcb\xe2\xbc\xe6\xa9\xee\xd3\xc5\x1b\xa9\x72\x47\xae\xa8\x16\xe7\x2b\xaa\xbb\x74\x71\xa1\x3a\x4c\x44\xd6\x2c\x5c\x07\x32\x70\x20\xb3\x93\x78\x14\x93\xa6\x85\x6e\x9c\x0e\xbd\xa2\x92\x67\x0c\x56\xe7\x47\x33\x82\x80\xce\xe1\xf1\x32\x91\x0f\x1a\x85\x63\xb5\xe0\x05\xab\xe3\x50\x79\x51\x78\x0f\xea\x08\xd5\x47\x43\x4e\xe1\x08\x51\x81\x49\xfa\x0b\x6d\x36\xd2\x77\xd7\x58\x23\xd1\x8c\x51\x4b\xcb\x5e\x44\x65\x52\x94\x62\x64\x64\xe4\x38\xa3\x8e\xff\xd3\xa1\x61\x35\x75\xb3\x61\xd7\x73\x6e\xe6\x6a\xe6\x82\x57\xf3\x41\xb8\x16\x69\x35\x7f\xc3\xa3\x75\x9b\xe0\x63\xaa\x2f\x5e\xd6\xe4\x62\x05\x33\x2f\xa3\x83\x95\xcf\x3c\x56\xe2\x84\x69\xf1\x45\x31\x39\xa9\xdc\x0d\x92\xdc\xfb\x58\xec\xfd\xd8\x3e\x7c\x0d\x61\x6d\x8f\xf7\xc2\x35\x9d\x3a\xe1\xf7\x68\xc3\x0d\x54\x1f\x3a\x61\x0c\xea\x35\xb4\xe4\xde\x3b\xd4\xa4\x7a\x84\xea\x41\xf5\x18\x42\x6c\xa3\xf7\xa8\x47\x0c\xe1\xd5\xe5\xbc\xe2\xac\x26\x79\x45\x7e\x53\xfd\x39\x7e\xd5\xa2\x79\x8c\x0a\x86\xea\xae\x21\xeb\x22\x40\x94\xf9\x77\xad\xcc\x23\xdc\x6c\xbc\x0b\x7c\x8e\xab\xbe\x88\xf3\x7f\x60\xaf\xb5\xe4\x51\xff\x9a\xf5\xbc\xf7\x7e\x23\xe0\x8d\x11\x93\xff\x7f\x80\x79\x99\xbd\xdf\xc8\x92\x35\x56\x22\xdf\xb8\x58\x9d\x3c\xaf\xd1\xbc\xaf\xf2\x34\xaf\xf2\x5c\x54\x95\xb4\x5f\xce\xe3\xc8\x83\x0e\x61\x85\x5f\x1d\xf5\xec\x79\x5d\xf0\x0f\x81\x92\x44\x36\x38\x8b\xfd\x43\x98\xac\x22\xef\x01\x8d\x4c\xb2\xaf\x67\x1d\xb1\x3a\xa9\x9d\x2f\xab\xf1\x19\x9f\xe8\xdb\x97\x4f\x10\x02\x1b\x2e\x6d\xf2\x7e\xb9\x88\x0b\xfb\x57\xfc\x57\x01\xe6\x25\xca\xed\x18\xf8\x05\x3b\x9e\xf4\x88\xf1\xc8\x06\xfe\xd9\x42\x2f\xa8\xe9\x94\x69\x97\x27\x55\x8c\x7f\x91\x4c\xfa\x0d\x7b\x8f\x46\x86\x50\xfc\x3b\x00\x49\x02\x2f\xa2\xd8\x06\x00\x00"
+
+func templatesAuditLogHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_templatesAuditLogHtml,
+		"templates/audit-log.html",
+	)
+}
+
+func templatesAuditLogHtml() (*asset, error) {
+	bytes, err := templatesAuditLogHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "templates/audit-log.html", size: 1752, mode: os.FileMode(420), modTime: time.Unix(1792267160, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _templatesFactoidInfoHtml = "\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xbc\x56\xd1\x8f\xdb\xa8\x13\x7e\xcf\x5f\xc1\xcf\xbf\xd5\xc5\x91\x1a\x5b\xed\xe3\xae\x63\xa9\x4d\x5b\xdd\x4a\x7b\xa7\xaa\xdb\xea\x1e\x2b\x02\xe3\x18\x2d\x01\x0b\x70\xd2\x08\xf1\xbf\x9f\xb0\xb1\x43\xbc\xc9\xae\xae\x3a\x9d\x5f\x8c\xe1\x9b\x61\xe6\x9b\x8f\xc1\xd6\x52\xa8\x98\x00\x94\x68\x73\xe4\xa0\x13\xe7\x66\x45\x37\x2c\x67\xb3\x22\x0f\x23\x6b\x41\x50\xe7\x66\x27\x34\x91\xc2\x80\x30\x1d\x9c\xb2\x3d\x22\x1c\x6b\xbd\xea\xa6\x31\x13\xa0\x92\xf2\x6c\xbe\xc1\x5b\x58\xd6\x80\x69\xb7\x82\x10\x42\x45\xfd\xb6\xfc\x8c\x89\x91\x8c\xde\xa2\x82\x48\x0a\xa5\xb5\x59\x98\x19\xde\x7f\xe2\x1d\x38\x57\xe4\xdd\x32\xb2\x96\x55\x68\x84\xdc\xeb\x07\x49\x9e\x80\x3a\x57\xb0\x61\x9b\x0a\xa3\x0a\x2f\xb9\x24\x4f\x49\x59\xe4\xac\x0c\x71\x17\x79\xfd\xb6\x9c\x15\x39\x65\xfb\x72\xd6\xed\x6e\xed\x81\x99\x7a\x74\xe6\x5c\x1f\x53\x53\x3e\x60\x6d\x10\x50\x66\x6e\x91\xb5\xad\x06\xf5\x83\x33\xf1\x84\x6e\x50\xe6\x57\xbe\x6b\x50\x01\xeb\x1f\x26\x90\xb5\xa4\xc6\x42\x00\x3f\xc3\xad\xfb\xb9\x08\x6a\xad\x02\x6e\xd8\x0e\x7a\xc0\x37\xb6\x03\x6d\xf0\xae\x71\x0e\x2d\x51\x81\x51\xad\xa0\x5a\x25\xd6\x62\x45\x6a\xb6\x87\x1f\xfe\x7b\xe2\xad\xff\xf8\x03\xb4\xc6\x5b\x70\x2e\x29\x1f\x39\x26\x4f\xe8\x7d\x6f\x51\xe4\xb8\x0c\xa9\xb1\x0a\xdd\x64\x0f\xf8\x28\x5b\x93\xad\x5b\xa5\x40\x84\xc0\x0b\xdd\x60\x81\x18\x5d\x25\x3e\xc3\x65\xd5\x27\x9f\x0c\xec\x6d\x8c\x40\x1b\x23\x96\x14\x2a\xdc\x72\x93\xa0\xae\xfa\xab\xa4\xe2\x12\x9b\x5b\xc5\xb6\xb5\x49\xca\x29\xd9\x0d\x08\xc2\x78\x4f\x37\xfa\x44\x99\x29\x72\xbf\xcb\x89\xfa\x26\xd4\xdb\xab\x21\x38\x24\x1c\xb0\xba\xdd\x48\x53\x7b\xbb\x53\x55\x8a\x46\x41\x17\x5e\xa3\xd8\x0e\xab\xe3\x52\xcb\x56\x11\x18\x03\x0c\x9f\xe5\x28\x97\xaf\xf8\xf0\xd8\xcd\x8d\x22\x29\xf2\x46\xc1\x40\x44\x2f\xda\x20\xb7\x77\xe5\xef\x4c\x1b\xa9\x8e\x45\x5e\xbf\xf3\xe2\x8e\xe4\x59\xf7\x2b\xcb\x58\xbe\xd6\x2a\x2c\xb6\x80\xb2\x60\x36\x68\x24\x32\x0b\x04\x06\x61\xde\xeb\xcf\x52\x6d\xa5\x31\x20\x9c\xab\x86\x61\x88\x22\xa8\x7e\xea\x61\x07\x06\x47\x4b\x41\x84\x5f\x61\xcf\x34\x93\xc2\x6b\x30\xfb\xb8\xb9\xff\xe8\xdc\xa5\x3d\xfa\x72\x0e\x9e\x5a\x03\x34\x29\x53\x0a\x1c\x0c\xd0\xc5\x79\x19\xc6\x1d\x36\xc7\xff\x4c\xd7\xbf\x09\x8a\x75\x7d\xf7\xaf\xa9\x7b\x94\x52\xc7\x52\x2f\x9b\x13\x69\x0a\x7e\x4d\x25\xc1\xd1\xc0\x53\xf8\x1c\x5e\x9a\x28\xd6\x98\x1e\xb9\xc7\x0a\x31\xed\x25\xce\xc4\x16\xad\x50\x85\xb9\x86\xbb\x68\xe9\x11\xef\x2f\xae\x18\xf8\x69\xb0\x02\x8c\x56\x48\xb4\x9c\xdf\xcd\x6e\x52\x2a\x49\xbb\x03\x61\xb2\x2d\x98\x4f\x1c\xfc\xf0\xc3\xf1\x9e\xa6\xe7\x07\x73\xb1\xc8\x08\x67\xe4\x29\xad\x5a\x41\x0c\x93\x22\x5d\x20\x3b\xba\xbd\xf1\xd8\x0f\xad\x31\x52\xa0\x15\xba\x49\xe7\xff\x8f\x8d\xe7\x8b\x3e\x00\x56\xa1\xf4\x7f\x63\xd8\x83\xfd\xe8\xa3\x27\x2b\xd8\x9f\x1f\xbb\xc1\xc3\x80\x25\xad\xfa\x06\x3f\x8d\xc7\xf6\x80\xcc\x27\x96\x4e\x50\x02\x0e\xdf\x4e\xf9\x8e\x79\x12\x05\xd8\x40\x48\x35\x9d\x0f\x94\xc4\x7b\x44\x96\xd9\x1e\xf3\xd6\x47\x15\xf6\xbc\x0c\xd2\x60\xde\x1b\xa3\xd8\xa6\x35\x90\xce\xc3\x8d\xe4\x39\xc0\x1b\x0e\xf3\x37\x68\x7e\xcd\xfb\xb9\x21\xa3\x1e\x7b\x3d\xf7\xd8\xb2\x93\xd8\x03\xd3\x26\xc3\x94\xa6\xf3\xc0\xf5\xd2\x6f\x1a\x9b\xc4\x15\x3f\x59\x9f\xd6\x07\x02\x15\x34\x1c\x13\xf8\x8b\x99\x3a\x8d\x80\x91\xa7\xa8\xc8\x99\x82\x9d\xdc\xc3\xda\x87\x90\xce\xa3\x46\x3d\xbf\x82\xc7\x94\x46\xe0\x90\xe0\x35\x70\x6d\x76\x3c\x9d\x4f\xbb\x3b\xa9\x61\xb8\x4b\xd1\x23\xde\x9f\xf1\x12\x9f\x05\xa3\xda\x20\x78\x87\x80\x6b\x88\x64\xe6\x05\x38\x9c\x8d\x05\x52\x60\x5a\x25\xee\x66\xcf\xa8\x9a\x14\x85\x32\xed\xab\x48\xa7\x65\x7c\x91\x8f\x57\x52\x3c\xe7\xe3\x15\xf2\x2e\xf3\xa1\x1b\x26\x86\x77\x77\x4d\x0c\xcc\x30\xb1\xcd\xb2\xec\x9c\x9e\xb1\x1f\x9c\xd8\xf1\x4f\x23\xb5\xf9\x88\x0d\x4e\xe7\x79\xd0\x8f\xce\xad\xcd\x1e\x89\x6c\x20\x34\xc3\xef\x5f\x1f\x9c\xcb\xaf\xfd\x0f\xe5\x9d\xdc\xde\x20\xab\xf0\xe1\xf6\x44\x60\x77\x66\xdc\x22\x33\x35\x88\x53\xc7\x50\xa0\x9b\xf8\xd4\x4f\x42\x8b\x5a\xd5\xaf\xf4\x85\xe8\xd4\x7f\x51\xf0\xc2\x81\x6f\xd4\x35\xbb\xb5\xa4\x2f\x19\xfa\x7e\x3d\xb5\xec\x77\xcb\x70\xd3\x80\xa0\xeb\x9a\x71\x9a\x06\x47\xcf\x81\x7e\xb6\x6b\x54\xeb\xbe\x3f\xf8\x72\x9c\x31\x76\xd1\xf5\x3f\xeb\x10\x91\xe1\xa4\x41\x5c\xc6\x5e\x39\xfe\x5f\xd4\x34\xfe\xd7\x05\xf9\xec\xf7\x6b\xba\xd7\x95\x1b\x6b\x78\x0e\x4c\x50\x79\xc8\xb8\x24\xd8\xcb\x25\x53\xc0\x25\xa6\x71\x53\x77\x61\xec\x66\x7e\x54\xe4\xc3\x95\x18\x6e\xcc\xbf\x03\x00\x00\xff\xff\x5f\x5b\x1b\x03\x41\x0c\x00\x00"
 
 func templatesFactoidInfoHtmlBytes() ([]byte, error) {
@@ -394,6 +415,7 @@ func AssetNames() []string {
 var _bindata = map[string]func() (*asset, error){
 	"layout.html":                   layoutHtml,
 	"assets/styles.css":             assetsStylesCss,
+	"templates/audit-log.html":      templatesAuditLogHtml,
 	"templates/factoid-info.html":   templatesFactoidInfoHtml,
 	"templates/factoid-list.html":   templatesFactoidListHtml,
 	"templates/home.html":           templatesHomeHtml,
@@ -453,6 +475,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	}},
 	"layout.html": &bintree{layoutHtml, map[string]*bintree{}},
 	"templates": &bintree{nil, map[string]*bintree{
+		"audit-log.html":      &bintree{templatesAuditLogHtml, map[string]*bintree{}},
 		"factoid-info.html":   &bintree{templatesFactoidInfoHtml, map[string]*bintree{}},
 		"factoid-list.html":   &bintree{templatesFactoidListHtml, map[string]*bintree{}},
 		"home.html":           &bintree{templatesHomeHtml, map[string]*bintree{}},
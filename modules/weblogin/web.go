@@ -23,6 +23,7 @@ const (
 	NavSectionInvite   = "Channels"
 	NavSectionLogs     = "Logs"
 	NavSectionUser     = "User"
+	NavSectionAudit    = "Audit"
 )
 
 var NavbarContent = []struct {
@@ -165,6 +165,7 @@ func (mod *WebLoginModule) Enable(team marvin.Team) {
 	team.Router().PathPrefix("/cdn_proxy/").Handler(http.StripPrefix("/cdn_proxy", http.HandlerFunc(cdnproxy.ProxyIntraCDN)))
 	team.Router().PathPrefix("/cdnproxy/").Handler(http.StripPrefix("/cdnproxy", http.HandlerFunc(cdnproxy.ProxyIntraCDN)))
 	team.Router().HandleFunc("/session/csrf.json", mod.ServeCSRF)
+	team.Router().Methods(http.MethodGet).Path("/audit").HandlerFunc(mod.ServeAuditLog)
 	team.Router().Methods(http.MethodDelete).Path("/session/destroy").HandlerFunc(mod.DestroySession)
 	team.Router().NotFoundHandler = http.HandlerFunc(mod.Serve404)
 
@@ -1,6 +1,8 @@
 package marvin
 
 import (
+	"fmt"
+
 	"github.com/riking/marvin/slack"
 )
 
@@ -11,6 +13,12 @@ const (
 	ReplyTypeInChannel
 	ReplyTypeLog
 	ReplyTypeFlagOmitUsername
+	// ReplyTypeFlagInThread starts a thread under the command message for the
+	// channel reply. Commands sent inside a thread are always answered there.
+	ReplyTypeFlagInThread
+	// ReplyTypeFlagBroadcast also shows a threaded channel reply in the
+	// channel itself.
+	ReplyTypeFlagBroadcast
 )
 
 const (
@@ -31,6 +39,15 @@ type ActionSourceUserMessage struct {
 
 func (um ActionSourceUserMessage) UserID() slack.UserID          { return um.Msg.UserID() }
 func (um ActionSourceUserMessage) ChannelID() slack.ChannelID    { return um.Msg.ChannelID() }
-func (um ActionSourceUserMessage) ArchiveLink() string           { return um.Team.ArchiveURL(um.Msg.MessageID()) }
 func (um ActionSourceUserMessage) MsgTimestamp() slack.MessageTS { return um.Msg.MessageTS() }
+func (um ActionSourceUserMessage) ThreadTS() slack.MessageTS     { return um.Msg.ThreadTS() }
 func (um ActionSourceUserMessage) AccessLevel() AccessLevel      { return um.Team.UserLevel(um.Msg.UserID()) }
+
+func (um ActionSourceUserMessage) ArchiveLink() string {
+	link := um.Team.ArchiveURL(um.Msg.MessageID())
+	if threadTS := um.Msg.ThreadTS(); threadTS != "" && threadTS != um.Msg.MessageTS() {
+		// Thread replies only open in context with these parameters
+		link += fmt.Sprintf("?thread_ts=%s&cid=%s", threadTS, um.Msg.ChannelID())
+	}
+	return link
+}
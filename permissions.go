@@ -0,0 +1,50 @@
+package marvin
+
+import (
+	"fmt"
+
+	"github.com/riking/marvin/slack"
+)
+
+// Permission is a named right that a command can require. It is declared with
+// RegisterCommandPerm and checked by Team.DispatchCommand.
+//
+// Users at or above Level have the permission without needing a role. Other
+// users need a role that grants the permission, either team-wide or in the
+// channel the command was used in.
+type Permission struct {
+	// Conventionally "command.subcommand", e.g. "config.set".
+	Name  string
+	Level AccessLevel
+}
+
+// PermissionWildcard can be granted to a role to grant every permission.
+const PermissionWildcard = "*"
+
+func (p Permission) String() string {
+	return fmt.Sprintf("%s (%s)", p.Name, p.Level)
+}
+
+// RoleGrant records that a user has a role. An empty Channel means the role
+// applies everywhere.
+type RoleGrant struct {
+	Role    string
+	UserID  slack.UserID
+	Channel slack.ChannelID
+}
+
+func (a AccessLevel) String() string {
+	switch a {
+	case AccessLevelBlacklisted:
+		return "blacklisted"
+	case AccessLevelNormal:
+		return "everyone"
+	case AccessLevelChannelAdmin:
+		return "channel admins"
+	case AccessLevelAdmin:
+		return "admins"
+	case AccessLevelController:
+		return "controllers"
+	}
+	return fmt.Sprintf("AccessLevel(%d)", int(a))
+}
@@ -0,0 +1,138 @@
+package marvin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riking/marvin/util"
+)
+
+// PipeSeparator is the argument that splits a command line into pipeline
+// stages, as in `@marvin factoid get lunchmenu | uppercase`. It must be a
+// separate word; a `|` inside an argument is left alone.
+const PipeSeparator = "|"
+
+// SplitPipeline splits the arguments at each PipeSeparator. It returns nil
+// if there is no separator.
+func SplitPipeline(args []string) ([][]string, error) {
+	var stages [][]string
+	start := 0
+	for i, v := range args {
+		if v != PipeSeparator {
+			continue
+		}
+		if i == start {
+			return nil, fmt.Errorf("empty command in pipeline before `%s` #%d", PipeSeparator, len(stages)+1)
+		}
+		stages = append(stages, args[start:i])
+		start = i + 1
+	}
+	if stages == nil {
+		return nil, nil
+	}
+	if start == len(args) {
+		return nil, fmt.Errorf("pipeline ends with `%s`", PipeSeparator)
+	}
+	return append(stages, args[start:]), nil
+}
+
+// PipelineData is the ModuleData of a pipeline's result. It keeps the
+// result of each stage so that an edit can give each stage its own
+// PreviousResult.
+type PipelineData struct {
+	Results []CommandResult
+	// ConfirmStage is the index of the stage that asked for confirmation,
+	// or -1.
+	ConfirmStage int
+}
+
+// RunPipeline runs each stage with dispatch, appending the Message of the
+// previous stage as one more argument. The first stage that does not
+// succeed stops the pipeline, and its result is returned with the stage
+// named in the message.
+//
+// A stage that asks for confirmation stops the pipeline too. When args is
+// Confirmed and its ModuleData is that pipeline's PipelineData, the earlier
+// stages are not run again: the pipeline resumes at the waiting stage, and
+// only that stage is Confirmed.
+//
+// The returned result has args as its Args, so the whole command line is
+// edited or undone as one.
+func RunPipeline(args *CommandArguments, stages [][]string, dispatch func(*CommandArguments) CommandResult) CommandResult {
+	var prev *PipelineData
+	if args.IsEdit {
+		if d, ok := args.ModuleData.(*PipelineData); ok && len(d.Results) == len(stages) {
+			prev = d
+		}
+	}
+
+	var resume *PipelineData
+	if args.Confirmed {
+		if d, ok := args.ModuleData.(*PipelineData); ok && d.ConfirmStage >= 0 &&
+			d.ConfirmStage < len(stages) && len(d.Results) == d.ConfirmStage+1 {
+			resume = d
+		}
+	}
+
+	data := &PipelineData{ConfirmStage: -1}
+	var input string
+	var result CommandResult
+	start := 0
+	if resume != nil {
+		start = resume.ConfirmStage
+		data.Results = append(data.Results, resume.Results[:start]...)
+		if start > 0 {
+			input = data.Results[start-1].Message
+		}
+	}
+	for i := start; i < len(stages); i++ {
+		stage := stages[i]
+		stageLine := make([]string, len(stage), len(stage)+1)
+		copy(stageLine, stage)
+		if i > 0 && input != "" {
+			stageLine = append(stageLine, input)
+		}
+		stageArgs := &CommandArguments{
+			Source:            args.Source,
+			Arguments:         stageLine,
+			OriginalArguments: stageLine,
+			Ctx:               args.Ctx,
+			IsEdit:            args.IsEdit,
+			IsUndo:            args.IsUndo,
+			Confirmed:         resume != nil && i == resume.ConfirmStage,
+		}
+		if prev != nil {
+			stageArgs.PreviousResult = &prev.Results[i]
+			if prev.Results[i].Args != nil {
+				stageArgs.ModuleData = prev.Results[i].Args.ModuleData
+			}
+		}
+
+		result = dispatch(stageArgs)
+		data.Results = append(data.Results, result)
+		if result.Code == CmdResultNeedsConfirm {
+			data.ConfirmStage = i
+			result.Message = fmt.Sprintf("Pipeline stage %d (`%s`): %s",
+				i+1, strings.Join(stage, " "), result.Message)
+			break
+		} else if result.Code != CmdResultOK {
+			result.Message = fmt.Sprintf("Pipeline stopped at stage %d (`%s`): %s",
+				i+1, strings.Join(stage, " "), result.Message)
+			break
+		}
+		input = result.Message
+	}
+
+	args.SetModuleData(data)
+	result.Args = args
+	result.CanEdit, result.CanUndo = util.TriYes, UndoSimple
+	for _, r := range data.Results {
+		if !r.Editable() {
+			result.CanEdit = util.TriNo
+		}
+		if ok, custom := r.Undoable(); !ok || custom {
+			result.CanUndo = util.TriNo
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package marvin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	stages, err := SplitPipeline(strings.Fields("factoid get menu | upper | paste"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{{"factoid", "get", "menu"}, {"upper"}, {"paste"}}
+	if !reflect.DeepEqual(stages, expect) {
+		t.Errorf("wrong split: %q", stages)
+	}
+	if stages, _ := SplitPipeline(strings.Fields("echo a|b")); stages != nil {
+		t.Errorf("split inside an argument: %q", stages)
+	}
+	for _, bad := range []string{"| upper", "echo a | | upper", "echo a |"} {
+		if _, err := SplitPipeline(strings.Fields(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+
+	var seen [][]string
+	dispatch := func(args *CommandArguments) CommandResult {
+		seen = append(seen, args.Arguments)
+		if args.Arguments[0] == "fail" {
+			return CmdFailuref(args, "nope")
+		}
+		if args.Arguments[0] == "forget" && !args.Confirmed {
+			return CmdConfirm(args, "sure?")
+		}
+		return CmdSuccess(args, strings.ToUpper(strings.Join(args.Arguments[1:], " "))).WithSimpleUndo()
+	}
+	args := &CommandArguments{}
+	result := RunPipeline(args, expect, dispatch)
+	if result.Code != CmdResultOK || result.Args != args || result.CanUndo != UndoSimple {
+		t.Errorf("wrong result: %+v", result)
+	}
+	if got := seen[2]; len(got) != 2 || got[1] != "GET MENU" {
+		t.Errorf("input not passed on: %q", seen)
+	}
+
+	result = RunPipeline(&CommandArguments{}, [][]string{{"echo", "x"}, {"fail"}, {"echo"}}, dispatch)
+	if result.Code != CmdResultFailure || !strings.Contains(result.Message, "stage 2 (`fail`)") {
+		t.Errorf("wrong failure: %+v", result)
+	}
+	if data := result.Args.ModuleData.(*PipelineData); len(data.Results) != 2 {
+		t.Errorf("stage after failure was run: %d results", len(data.Results))
+	}
+
+	// Each destructive stage is confirmed on its own, and a resumed
+	// pipeline does not run the earlier stages again
+	destructive := [][]string{{"echo", "x"}, {"forget", "a"}, {"forget", "b"}}
+	seen = nil
+	args = &CommandArguments{}
+	result = RunPipeline(args, destructive, dispatch)
+	if result.Code != CmdResultNeedsConfirm || !strings.Contains(result.Message, "stage 2") {
+		t.Fatalf("expected a confirmation for stage 2: %+v", result)
+	}
+	args = &CommandArguments{Confirmed: true, ModuleData: args.ModuleData}
+	result = RunPipeline(args, destructive, dispatch)
+	if result.Code != CmdResultNeedsConfirm || !strings.Contains(result.Message, "stage 3") {
+		t.Fatalf("stage 3 ran without its own confirmation: %+v", result)
+	}
+	args = &CommandArguments{Confirmed: true, ModuleData: args.ModuleData}
+	result = RunPipeline(args, destructive, dispatch)
+	if result.Code != CmdResultOK || result.Message != "B A X" {
+		t.Errorf("wrong confirmed result: %+v", result)
+	}
+	if len(seen) != 5 || seen[0][0] != "echo" || seen[1][0] != "forget" || seen[2][0] != "forget" {
+		t.Errorf("stages run again after confirming: %q", seen)
+	}
+	if data := args.ModuleData.(*PipelineData); len(data.Results) != 3 {
+		t.Errorf("wrong resumed results: %d", len(data.Results))
+	}
+
+	result = RunPipeline(&CommandArguments{Confirmed: true}, destructive, dispatch)
+	if result.Code != CmdResultNeedsConfirm {
+		t.Errorf("pipeline without saved data was confirmed: %+v", result)
+	}
+}
@@ -0,0 +1,40 @@
+package marvin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Wednesday
+	base := time.Date(2026, time.January, 7, 10, 17, 30, 0, time.UTC)
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 20m", base.Add(20 * time.Minute)},
+		{"@hourly", time.Date(2026, time.January, 7, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, time.January, 8, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.January, 7, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, time.January, 8, 9, 0, 0, 0, time.UTC)},
+		{"30 8 1,15 * *", time.Date(2026, time.January, 15, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 31 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.spec)
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(c.next) {
+			t.Errorf("%q: got %v, expected %v", c.spec, got, c.next)
+		}
+	}
+
+	for _, spec := range []string{"", "@every 1ms", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
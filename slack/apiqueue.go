@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"time"
+)
+
+// APIPriority orders calls waiting in the outbound Web API queue. Calls with
+// a lower value are sent first; calls to the same channel are always sent in
+// the order they were made.
+type APIPriority int
+
+const (
+	// PriorityHigh is for replies to commands.
+	PriorityHigh APIPriority = -1
+	// PriorityNormal is the default.
+	PriorityNormal APIPriority = 0
+	// PriorityLow is for bulk posts, like feed items.
+	PriorityLow APIPriority = 1
+)
+
+func (p APIPriority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	}
+	return "unknown"
+}
+
+// APIQueueStats is a snapshot of the outbound Web API queue.
+type APIQueueStats struct {
+	// Queued is the number of calls waiting to be sent, by priority.
+	Queued map[APIPriority]int
+	// InFlight is the number of calls currently being sent.
+	InFlight int
+	// OldestWait is how long the oldest waiting call has been queued.
+	OldestWait time.Duration
+
+	// Sent counts calls that completed, whether or not Slack returned an error.
+	Sent uint64
+	// RateLimited counts responses that asked us to slow down.
+	RateLimited uint64
+	// Dropped counts calls that were still rate limited after every retry.
+	Dropped uint64
+}
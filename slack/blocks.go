@@ -0,0 +1,142 @@
+package slack
+
+// Block Kit layout blocks.
+//
+// https://api.slack.com/reference/block-kit/blocks
+type Block struct {
+	Type    string `json:"type"`
+	BlockID string `json:"block_id,omitempty"`
+
+	// section
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory *BlockElement `json:"accessory,omitempty"`
+
+	// actions, context
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// TextObject is a "plain_text" or "mrkdwn" composition object.
+type TextObject struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// BlockElement is an interactive element, such as a button.
+//
+// https://api.slack.com/reference/block-kit/block-elements
+type BlockElement struct {
+	Type     string      `json:"type"`
+	ActionID string      `json:"action_id,omitempty"`
+	Text     *TextObject `json:"text,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	// "primary" or "danger"
+	Style string `json:"style,omitempty"`
+}
+
+const (
+	BlockTypeSection = "section"
+	BlockTypeActions = "actions"
+	BlockTypeContext = "context"
+	BlockTypeDivider = "divider"
+
+	TextTypePlain    = "plain_text"
+	TextTypeMarkdown = "mrkdwn"
+
+	ElementTypeButton = "button"
+
+	ButtonStylePrimary = "primary"
+	ButtonStyleDanger  = "danger"
+)
+
+func PlainText(text string) *TextObject {
+	return &TextObject{Type: TextTypePlain, Text: text, Emoji: true}
+}
+
+func MarkdownText(text string) *TextObject {
+	return &TextObject{Type: TextTypeMarkdown, Text: text}
+}
+
+// SectionBlock makes a block of mrkdwn text.
+func SectionBlock(text string) Block {
+	return Block{Type: BlockTypeSection, Text: MarkdownText(text)}
+}
+
+// ActionsBlock makes a row of interactive elements.
+func ActionsBlock(elements ...BlockElement) Block {
+	b := Block{Type: BlockTypeActions}
+	for _, v := range elements {
+		b.Elements = append(b.Elements, v)
+	}
+	return b
+}
+
+// ContextBlock makes a line of small mrkdwn text.
+func ContextBlock(texts ...string) Block {
+	b := Block{Type: BlockTypeContext}
+	for _, v := range texts {
+		b.Elements = append(b.Elements, MarkdownText(v))
+	}
+	return b
+}
+
+func DividerBlock() Block {
+	return Block{Type: BlockTypeDivider}
+}
+
+// ButtonElement makes a button. Clicking it sends a block_actions payload
+// with the given action_id and value to the interactivity endpoint.
+func ButtonElement(actionID, text, value string) BlockElement {
+	return BlockElement{
+		Type:     ElementTypeButton,
+		ActionID: actionID,
+		Text:     PlainText(text),
+		Value:    value,
+	}
+}
+
+// WithStyle returns a copy of the element with the given button style.
+func (e BlockElement) WithStyle(style string) BlockElement {
+	e.Style = style
+	return e
+}
+
+// BlockActionPayload is the payload sent to the interactivity endpoint when
+// a user clicks a button in a message.
+//
+// https://api.slack.com/reference/interaction-payloads/block-actions
+type BlockActionPayload struct {
+	Type string `json:"type"`
+	Team struct {
+		ID     TeamID `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	User struct {
+		ID       UserID `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID   ChannelID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"channel"`
+	Container struct {
+		Type      string    `json:"type"`
+		ChannelID ChannelID `json:"channel_id"`
+		MessageTS MessageTS `json:"message_ts"`
+		ThreadTS  MessageTS `json:"thread_ts"`
+	} `json:"container"`
+	TriggerID   string        `json:"trigger_id"`
+	ResponseURL string        `json:"response_url"`
+	Actions     []BlockAction `json:"actions"`
+}
+
+// BlockAction is one element interaction in a BlockActionPayload.
+type BlockAction struct {
+	Type     string    `json:"type"`
+	ActionID string    `json:"action_id"`
+	BlockID  string    `json:"block_id"`
+	Value    string    `json:"value"`
+	ActionTS MessageTS `json:"action_ts"`
+}
@@ -0,0 +1,311 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/riking/marvin/metrics"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+var (
+	metricQueueDepth = metrics.NewGaugeVec("marvin_slack_api_queue_depth",
+		"Slack API calls waiting to be sent, by priority.", "priority")
+	metricRateLimited = metrics.NewCounterVec("marvin_slack_api_rate_limited_total",
+		"Slack API responses asking us to slow down, by tier.", "tier")
+)
+
+// An apiTier is a Slack rate limit tier. perChannel tiers are limited
+// separately in each channel.
+type apiTier struct {
+	name       string
+	perMinute  float64
+	burst      float64
+	perChannel bool
+}
+
+var (
+	tier1    = &apiTier{name: "tier1", perMinute: 1, burst: 1}
+	tier2    = &apiTier{name: "tier2", perMinute: 20, burst: 5}
+	tier3    = &apiTier{name: "tier3", perMinute: 50, burst: 10}
+	tier4    = &apiTier{name: "tier4", perMinute: 100, burst: 20}
+	tierPost = &apiTier{name: "post", perMinute: 60, burst: 3, perChannel: true}
+	tierRTM  = &apiTier{name: "rtm", perMinute: 60, burst: 5}
+)
+
+// https://api.slack.com/docs/rate-limits
+var methodTiers = map[string]*apiTier{
+	"chat.postMessage":   tierPost,
+	"chat.postEphemeral": tierPost,
+	"chat.update":        tier3,
+	"chat.delete":        tier3,
+	"reactions.add":      tier3,
+	"reactions.remove":   tier2,
+	"pins.add":           tier2,
+	"pins.remove":        tier2,
+	"files.upload":       tier2,
+	"users.info":         tier4,
+	"users.list":         tier2,
+	"conversations.info": tier3,
+	"conversations.list": tier2,
+	"channels.info":      tier3,
+	"groups.info":        tier3,
+	"im.open":            tier3,
+	"users.admin.invite": tier1,
+}
+
+func tierForMethod(method string) *apiTier {
+	if tier, ok := methodTiers[method]; ok {
+		return tier
+	}
+	return tier3
+}
+
+const (
+	apiMaxInFlight = 4
+	apiMaxAttempts = 5
+	// apiDefaultBackoff is used when Slack does not send Retry-After
+	apiDefaultBackoff = 1 * time.Second
+)
+
+type tierBucket struct {
+	tokens    float64
+	last      time.Time
+	notBefore time.Time
+}
+
+// reserve takes a token, or returns how long to wait for one.
+func (b *tierBucket) reserve(tier *apiTier, now time.Time) time.Duration {
+	if now.Before(b.notBefore) {
+		return b.notBefore.Sub(now)
+	}
+	b.tokens += now.Sub(b.last).Minutes() * tier.perMinute
+	if b.tokens > tier.burst {
+		b.tokens = tier.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / tier.perMinute * float64(time.Minute))
+}
+
+type queuedCall struct {
+	tier     *apiTier
+	channel  slack.ChannelID
+	priority slack.APIPriority
+	seq      uint64
+	enqueued time.Time
+	attempts int
+
+	// run makes the call. A positive retryAfter means Slack rate limited it.
+	run  func(attempt int) (retryAfter time.Duration, err error)
+	done chan error
+}
+
+// apiQueue sends Slack API calls within the rate limits. Calls naming the
+// same channel are sent one at a time, in order.
+type apiQueue struct {
+	lock     sync.Mutex
+	pending  []*queuedCall
+	buckets  map[string]*tierBucket
+	busy     map[slack.ChannelID]bool
+	inFlight int
+	seq      uint64
+	wake     chan struct{}
+	stop     chan struct{}
+
+	sent        uint64
+	rateLimited uint64
+	dropped     uint64
+}
+
+func newAPIQueue() *apiQueue {
+	q := &apiQueue{
+		buckets: make(map[string]*tierBucket),
+		busy:    make(map[slack.ChannelID]bool),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	go q.loop()
+	return q
+}
+
+func (q *apiQueue) Stop() {
+	close(q.stop)
+}
+
+// Do queues the call and waits for it to finish.
+func (q *apiQueue) Do(tier *apiTier, channel slack.ChannelID, prio slack.APIPriority, run func(attempt int) (time.Duration, error)) error {
+	c := &queuedCall{
+		tier:     tier,
+		channel:  channel,
+		priority: prio,
+		enqueued: time.Now(),
+		run:      run,
+		done:     make(chan error, 1),
+	}
+	q.lock.Lock()
+	q.seq++
+	c.seq = q.seq
+	q.pending = append(q.pending, c)
+	q.lock.Unlock()
+	q.signal()
+	return <-c.done
+}
+
+func (q *apiQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *apiQueue) loop() {
+	for {
+		wait := q.dispatch(time.Now())
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (q *apiQueue) bucket(c *queuedCall) *tierBucket {
+	key := c.tier.name
+	if c.tier.perChannel {
+		key = key + ":" + string(c.channel)
+	}
+	b, ok := q.buckets[key]
+	if !ok {
+		b = &tierBucket{tokens: c.tier.burst, last: time.Now()}
+		q.buckets[key] = b
+	}
+	return b
+}
+
+// dispatch starts every call that can be sent now, and returns how long to
+// wait before trying again.
+func (q *apiQueue) dispatch(now time.Time) time.Duration {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	sort.Slice(q.pending, func(i, j int) bool {
+		if q.pending[i].priority != q.pending[j].priority {
+			return q.pending[i].priority < q.pending[j].priority
+		}
+		return q.pending[i].seq < q.pending[j].seq
+	})
+	// Only the oldest call for each channel may go
+	firstInChannel := make(map[slack.ChannelID]uint64)
+	for _, c := range q.pending {
+		if c.channel == "" {
+			continue
+		}
+		if seq, ok := firstInChannel[c.channel]; !ok || c.seq < seq {
+			firstInChannel[c.channel] = c.seq
+		}
+	}
+
+	wait := time.Minute
+	remaining := q.pending[:0]
+	for _, c := range q.pending {
+		if q.inFlight >= apiMaxInFlight {
+			remaining = append(remaining, c)
+			continue
+		}
+		if c.channel != "" && (q.busy[c.channel] || firstInChannel[c.channel] != c.seq) {
+			remaining = append(remaining, c)
+			continue
+		}
+		if d := q.bucket(c).reserve(c.tier, now); d > 0 {
+			if d < wait {
+				wait = d
+			}
+			remaining = append(remaining, c)
+			continue
+		}
+		q.inFlight++
+		if c.channel != "" {
+			q.busy[c.channel] = true
+		}
+		go q.execute(c)
+	}
+	q.pending = remaining
+
+	depth := make(map[slack.APIPriority]int)
+	for _, c := range q.pending {
+		depth[c.priority]++
+	}
+	for _, p := range []slack.APIPriority{slack.PriorityHigh, slack.PriorityNormal, slack.PriorityLow} {
+		metricQueueDepth.With(p.String()).Set(float64(depth[p]))
+	}
+	return wait
+}
+
+func (q *apiQueue) execute(c *queuedCall) {
+	retryAfter, err := c.run(c.attempts)
+
+	q.lock.Lock()
+	q.inFlight--
+	delete(q.busy, c.channel)
+	c.attempts++
+	if retryAfter > 0 {
+		q.rateLimited++
+		metricRateLimited.With(c.tier.name).Inc()
+		b := q.bucket(c)
+		b.tokens = 0
+		b.notBefore = time.Now().Add(retryAfter)
+		if c.attempts < apiMaxAttempts {
+			// Keeps its sequence number, so it stays first in its channel
+			q.pending = append(q.pending, c)
+			q.lock.Unlock()
+			q.signal()
+			return
+		}
+		q.dropped++
+		util.LogBadf("Slack API: giving up on rate limited %s call after %d attempts", c.tier.name, c.attempts)
+	} else {
+		q.sent++
+	}
+	q.lock.Unlock()
+	q.signal()
+	c.done <- err
+}
+
+func (q *apiQueue) Stats() slack.APIQueueStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stats := slack.APIQueueStats{
+		Queued:      make(map[slack.APIPriority]int),
+		InFlight:    q.inFlight,
+		Sent:        q.sent,
+		RateLimited: q.rateLimited,
+		Dropped:     q.dropped,
+	}
+	now := time.Now()
+	for _, c := range q.pending {
+		stats.Queued[c.priority]++
+		if wait := now.Sub(c.enqueued); wait > stats.OldestWait {
+			stats.OldestWait = wait
+		}
+	}
+	return stats
+}
+
+// retryAfter reads the Retry-After header of a 429 response.
+func retryAfter(resp *http.Response, attempts int) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return apiDefaultBackoff << uint(attempts)
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riking/marvin/slack"
+)
+
+// tierTest is fast enough that tokens are never the limit.
+var tierTest = &apiTier{name: "test", perMinute: 600000, burst: 100}
+
+// waitQueued waits until n calls are waiting in the queue. It may be called
+// from any goroutine, so it does not stop the test.
+func waitQueued(t *testing.T, q *apiQueue, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		total := 0
+		for _, v := range q.Stats().Queued {
+			total += v
+		}
+		if total == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("timed out waiting for %d queued calls", n)
+}
+
+func TestAPIQueueChannelOrder(t *testing.T) {
+	q := newAPIQueue()
+	defer q.Stop()
+
+	var lock sync.Mutex
+	var order []string
+	call := func(name string) func(int) (time.Duration, error) {
+		return func(int) (time.Duration, error) {
+			lock.Lock()
+			order = append(order, name)
+			lock.Unlock()
+			return 0, nil
+		}
+	}
+
+	// Hold the channel busy while the rest are queued
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.Do(tierTest, "C1", slack.PriorityNormal, func(int) (time.Duration, error) {
+			close(started)
+			<-release
+			return call("first")(0)
+		})
+	}()
+	<-started
+
+	// A later high priority call does not overtake the calls before it in
+	// the same channel
+	queue := []struct {
+		name string
+		prio slack.APIPriority
+	}{
+		{"low", slack.PriorityLow},
+		{"normal", slack.PriorityNormal},
+		{"high", slack.PriorityHigh},
+	}
+	for i, v := range queue {
+		v := v
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Do(tierTest, "C1", v.prio, call(v.name))
+		}()
+		waitQueued(t, q, i+1)
+	}
+	close(release)
+	wg.Wait()
+
+	expect := []string{"first", "low", "normal", "high"}
+	if !reflect.DeepEqual(order, expect) {
+		t.Errorf("wrong order: got %v, expected %v", order, expect)
+	}
+	if stats := q.Stats(); stats.Sent != 4 || stats.Dropped != 0 {
+		t.Errorf("wrong stats: %+v", stats)
+	}
+}
+
+func TestAPIQueueRateLimited(t *testing.T) {
+	q := newAPIQueue()
+	defer q.Stop()
+
+	// Rate limited once, then sent; the call queued behind it waits
+	var lock sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	started := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := q.Do(tierTest, "C1", slack.PriorityNormal, func(attempt int) (time.Duration, error) {
+			lock.Lock()
+			order = append(order, "first")
+			lock.Unlock()
+			if attempt == 0 {
+				close(started)
+				// Let the second call queue up before asking to retry
+				waitQueued(t, q, 1)
+				return 10 * time.Millisecond, errors.New("rate limited")
+			}
+			return 0, nil
+		})
+		if err != nil {
+			t.Errorf("retried call failed: %v", err)
+		}
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		q.Do(tierTest, "C1", slack.PriorityHigh, func(int) (time.Duration, error) {
+			lock.Lock()
+			order = append(order, "second")
+			lock.Unlock()
+			return 0, nil
+		})
+	}()
+	wg.Wait()
+
+	if expect := []string{"first", "first", "second"}; !reflect.DeepEqual(order, expect) {
+		t.Errorf("requeued call lost its place: got %v", order)
+	}
+	if stats := q.Stats(); stats.Sent != 2 || stats.RateLimited != 1 || stats.Dropped != 0 {
+		t.Errorf("wrong stats after retry: %+v", stats)
+	}
+
+	// Always rate limited: dropped after apiMaxAttempts, and not counted as sent
+	attempts := 0
+	limited := errors.New("rate limited")
+	err := q.Do(tierTest, "C2", slack.PriorityNormal, func(int) (time.Duration, error) {
+		attempts++
+		return time.Millisecond, limited
+	})
+	if err != limited {
+		t.Errorf("dropped call returned %v", err)
+	}
+	if attempts != apiMaxAttempts {
+		t.Errorf("made %d attempts, expected %d", attempts, apiMaxAttempts)
+	}
+	if stats := q.Stats(); stats.Sent != 2 || stats.RateLimited != 1+apiMaxAttempts || stats.Dropped != 1 {
+		t.Errorf("wrong stats after drop: %+v", stats)
+	}
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/util"
+)
+
+// asyncCommands tracks the commands started by StartCommand.
+type asyncCommands struct {
+	lock    sync.Mutex
+	nextID  int64
+	running map[int64]*runningCommand
+}
+
+type runningCommand struct {
+	// info.Progress is guarded by asyncCommands.lock
+	info   marvin.RunningCommand
+	cancel context.CancelFunc
+}
+
+// commandValues keeps the values of a command's context, like the batch
+// marker, without its deadline, which ends when the command returns.
+type commandValues struct {
+	context.Context
+	values context.Context
+}
+
+func (c commandValues) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+func (t *Team) StartCommand(result marvin.CommandResult, progress marvin.ProgressFunc) (int64, <-chan marvin.CommandResult) {
+	args := result.Args
+	parent := context.Background()
+	if args.Ctx != nil {
+		parent = commandValues{Context: parent, values: args.Ctx}
+	}
+	ctx, cancel := context.WithTimeout(parent, marvin.AsyncCommandTimeout)
+	rc := &runningCommand{
+		info: marvin.RunningCommand{
+			User:     args.Source.UserID(),
+			Channel:  args.Source.ChannelID(),
+			Command:  strings.Join(args.OriginalArguments, " "),
+			Started:  time.Now(),
+			Progress: result.Message,
+		},
+		cancel: cancel,
+	}
+
+	t.async.lock.Lock()
+	if t.async.running == nil {
+		t.async.running = make(map[int64]*runningCommand)
+	}
+	t.async.nextID++
+	rc.info.ID = t.async.nextID
+	t.async.running[rc.info.ID] = rc
+	t.async.lock.Unlock()
+
+	report := func(msg string) {
+		t.async.lock.Lock()
+		rc.info.Progress = msg
+		t.async.lock.Unlock()
+		if progress != nil {
+			progress(msg)
+		}
+	}
+
+	done := make(chan marvin.CommandResult, 1)
+	go func() {
+		defer func() {
+			cancel()
+			t.async.lock.Lock()
+			delete(t.async.running, rc.info.ID)
+			t.async.lock.Unlock()
+		}()
+
+		var final marvin.CommandResult
+		err := util.PCall(func() error {
+			final = result.Async(ctx, report)
+			return nil
+		})
+		if err != nil {
+			final = marvin.CmdError(args, err, "Runtime error")
+		} else if errors.Cause(final.Err) == context.Canceled {
+			final = marvin.CmdFailuref(args, "Cancelled.")
+		} else if final.Code == marvin.CmdResultInProgress {
+			final = marvin.CmdError(args, errors.New("async command returned another async result"), "Runtime error")
+		}
+		if final.Args == nil {
+			final.Args = args
+		}
+		done <- final
+	}()
+	return rc.info.ID, done
+}
+
+func (t *Team) CancelCommand(id int64) bool {
+	t.async.lock.Lock()
+	defer t.async.lock.Unlock()
+	rc, ok := t.async.running[id]
+	if ok {
+		rc.cancel()
+	}
+	return ok
+}
+
+func (t *Team) RunningCommands() []marvin.RunningCommand {
+	t.async.lock.Lock()
+	result := make([]marvin.RunningCommand, 0, len(t.async.running))
+	for _, rc := range t.async.running {
+		result = append(result, rc.info)
+	}
+	t.async.lock.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// cancelAllCommands stops every running command, for shutdown.
+func (t *Team) cancelAllCommands() {
+	t.async.lock.Lock()
+	defer t.async.lock.Unlock()
+	for _, rc := range t.async.running {
+		rc.cancel()
+	}
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/database"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+func MigrateAudit(c *database.Conn) error {
+	err := c.Migrate("main", 1542326400,
+		`CREATE TABLE audit_log (
+			id SERIAL PRIMARY KEY,
+			time timestamptz NOT NULL DEFAULT now(),
+			actor varchar(64) NOT NULL,
+			channel varchar(15) NOT NULL DEFAULT '',
+			msg_ts varchar(20) NOT NULL DEFAULT '',
+			module varchar(64) NOT NULL,
+			action varchar(64) NOT NULL,
+			target text NOT NULL DEFAULT '',
+			before text NOT NULL DEFAULT '',
+			after text NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX audit_log_actor ON audit_log (actor)`,
+		`CREATE INDEX audit_log_module ON audit_log (module)`,
+	)
+	if err != nil {
+		return err
+	}
+	c.SyntaxCheck(
+		sqlAuditInsert,
+		sqlAuditList,
+	)
+	return nil
+}
+
+const (
+	defaultAuditLimit = 50
+
+	// $1 = actor $2 = channel $3 = msg_ts $4 = module $5 = action
+	// $6 = target $7 = before $8 = after
+	sqlAuditInsert = `
+		INSERT INTO audit_log (actor, channel, msg_ts, module, action, target, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	// $1 = actor $2 = module $3 = action $4 = before id $5 = limit
+	sqlAuditList = `
+		SELECT id, time, actor, channel, msg_ts, module, action, target, before, after
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+		AND ($2 = '' OR module = $2)
+		AND ($3 = '' OR action = $3)
+		AND ($4 = 0 OR id < $4)
+		ORDER BY id DESC
+		LIMIT $5`
+)
+
+func (t *Team) Audit(source marvin.ActionSource, entry marvin.AuditEntry) {
+	if source != nil {
+		entry.Actor = source.UserID()
+		entry.Channel = source.ChannelID()
+		entry.MsgTS = source.MsgTimestamp()
+	}
+	_, err := t.DB().Exec(sqlAuditInsert,
+		string(entry.Actor), string(entry.Channel), string(entry.MsgTS),
+		string(entry.Module), entry.Action, entry.Target,
+		entry.Before, entry.After)
+	if err != nil {
+		util.LogError(errors.Wrapf(err, "audit %s by %s", entry.Action, entry.Actor))
+	}
+}
+
+func (t *Team) ListAudit(filter marvin.AuditFilter) ([]marvin.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	rows, err := t.DB().Query(sqlAuditList,
+		string(filter.Actor), string(filter.Module), filter.Action,
+		filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "list audit log")
+	}
+	defer rows.Close()
+
+	var result []marvin.AuditEntry
+	for rows.Next() {
+		var e marvin.AuditEntry
+		var actor, channel, msgTS, module string
+		var when time.Time
+		err = rows.Scan(&e.ID, &when, &actor, &channel, &msgTS, &module,
+			&e.Action, &e.Target, &e.Before, &e.After)
+		if err != nil {
+			return nil, errors.Wrap(err, "list audit log")
+		}
+		e.Time = when
+		e.Actor = slack.UserID(actor)
+		e.Channel = slack.ChannelID(channel)
+		e.MsgTS = slack.MessageTS(msgTS)
+		e.Module = marvin.ModuleID(module)
+		result = append(result, e)
+	}
+	return result, errors.Wrap(rows.Err(), "list audit log")
+}
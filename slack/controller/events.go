@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/csrf"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/slack/rtm"
+	"github.com/riking/marvin/util"
+)
+
+// EventSource is a transport that feeds Slack events into the handlers
+// registered with OnEvent, OnNormalMessage, etc.
+//
+// The transport is selected with the EventTransport key in config.ini.
+type EventSource interface {
+	// Start begins delivering events. It does not block.
+	Start() error
+}
+
+// slackEndpointPrefix is the URL prefix for HTTP endpoints that Slack calls
+// directly. These endpoints must verify the request signature instead of
+// relying on CSRF protection.
+const slackEndpointPrefix = "/slack/"
+
+const eventsAPIPath = "/slack/events"
+
+// How long to remember event IDs for retry de-duplication. Slack gives up
+// after 3 retries over about an hour and a half.
+const eventsSeenExpiry = 2 * time.Hour
+
+// rtmEventSource receives events over the RTM websocket.
+type rtmEventSource struct {
+	client *rtm.Client
+}
+
+func (s rtmEventSource) Start() error {
+	go s.client.Start()
+	return nil
+}
+
+// eventsAPISource receives events from the Slack Events API.
+//
+// https://api.slack.com/apis/connections/events-api
+type eventsAPISource struct {
+	team          *Team
+	client        *rtm.Client
+	signingSecret []byte
+
+	seenLock sync.Mutex
+	seen     map[string]time.Time
+}
+
+func newEventsAPISource(t *Team, c *rtm.Client) *eventsAPISource {
+	s := &eventsAPISource{
+		team:          t,
+		client:        c,
+		signingSecret: []byte(t.teamConfig.SigningSecret),
+		seen:          make(map[string]time.Time),
+	}
+	if len(s.signingSecret) == 0 {
+		util.LogWarn("EventTransport is", marvin.EventTransportHTTP, "but SigningSecret is not set; all events will be rejected")
+	}
+	t.Router().Handle(eventsAPIPath, s)
+	return s
+}
+
+func (s *eventsAPISource) Start() error {
+	err := s.client.StartWithoutRTM()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			time.Sleep(10 * time.Minute)
+			s.janitorSeen()
+		}
+	}()
+	return nil
+}
+
+// markSeen records the event ID and returns true if it was already recorded.
+func (s *eventsAPISource) markSeen(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	s.seenLock.Lock()
+	defer s.seenLock.Unlock()
+	if _, ok := s.seen[eventID]; ok {
+		return true
+	}
+	s.seen[eventID] = time.Now()
+	return false
+}
+
+func (s *eventsAPISource) janitorSeen() {
+	s.seenLock.Lock()
+	defer s.seenLock.Unlock()
+	threshold := time.Now().Add(-eventsSeenExpiry)
+	for k, v := range s.seen {
+		if v.Before(threshold) {
+			delete(s.seen, k)
+		}
+	}
+}
+
+func (s *eventsAPISource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := slack.VerifyRequest(r, s.signingSecret)
+	if err != nil {
+		util.LogBad("events api:", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var envelope struct {
+		Type      string          `json:"type"`
+		Challenge string          `json:"challenge"`
+		TeamID    slack.TeamID    `json:"team_id"`
+		EventID   string          `json:"event_id"`
+		Event     json.RawMessage `json:"event"`
+	}
+	err = json.Unmarshal(body, &envelope)
+	if err != nil {
+		util.LogBad("events api: bad request:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(envelope.Challenge))
+		return
+	case "app_rate_limited":
+		util.LogWarn("events api: Slack is rate limiting event deliveries")
+	case "event_callback":
+		if s.markSeen(envelope.EventID) {
+			util.LogDebug("events api: ignoring retry of", envelope.EventID, r.Header.Get(slack.HeaderRetryReason))
+			break
+		}
+		var msg slack.RTMRawMessage
+		err = json.Unmarshal(envelope.Event, &msg)
+		if err != nil || msg == nil {
+			util.LogBad("events api: bad event payload:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg[slack.MsgFieldRawBytes] = []byte(envelope.Event)
+		// Handlers run on their own goroutines, so this returns before the
+		// 3 second deadline.
+		s.client.DispatchMessage(msg)
+	default:
+		util.LogDebug("events api: unknown envelope type", envelope.Type)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// skipCSRFForSlack disables CSRF checks on the endpoints that Slack calls.
+// Those handlers verify the request signature instead.
+func skipCSRFForSlack(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, slackEndpointPrefix) {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
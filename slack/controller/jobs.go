@@ -0,0 +1,412 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/database"
+	"github.com/riking/marvin/metrics"
+	"github.com/riking/marvin/util"
+)
+
+var metricJobs = metrics.NewCounterVec("marvin_jobs_total",
+	"Scheduled jobs run, by job and result.", "job", "result")
+
+func MigrateJobs(c *database.Conn) error {
+	err := c.Migrate("main", 1792195200,
+		`CREATE TABLE jobs (
+			id SERIAL PRIMARY KEY,
+			module varchar(64) NOT NULL,
+			name varchar(64) NOT NULL,
+			payload text NOT NULL DEFAULT 'null',
+			schedule text NOT NULL DEFAULT '',
+			run_at timestamptz NOT NULL,
+			attempts int NOT NULL DEFAULT 0,
+			last_error text NOT NULL DEFAULT '',
+			failed boolean NOT NULL DEFAULT FALSE,
+			locked_until timestamptz
+		)`,
+		`CREATE INDEX jobs_run_at ON jobs (run_at) WHERE NOT failed`,
+		`CREATE UNIQUE INDEX jobs_recurring_uniq ON jobs (module, name) WHERE schedule <> ''`,
+	)
+	if err != nil {
+		return err
+	}
+	c.SyntaxCheck(
+		sqlJobInsert,
+		sqlJobUpsertRecurring,
+		sqlJobClaim,
+		sqlJobNextRun,
+		sqlJobDelete,
+		sqlJobReschedule,
+		sqlJobRetry,
+		sqlJobFail,
+		sqlJobList,
+	)
+	return nil
+}
+
+const (
+	// jobLockTime is how long a claimed job is left alone. A job still
+	// locked after this is assumed to have died with its process.
+	jobLockTime      = 10 * time.Minute
+	jobPollInterval  = 1 * time.Minute
+	jobRetryBase     = 30 * time.Second
+	jobRetryMax      = 1 * time.Hour
+	jobClaimPerCycle = 20
+
+	// $1 = module $2 = name $3 = payload $4 = run_at
+	sqlJobInsert = `
+		INSERT INTO jobs (module, name, payload, run_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	// $1 = module $2 = name $3 = payload $4 = schedule $5 = first run_at
+	sqlJobUpsertRecurring = `
+		INSERT INTO jobs (module, name, payload, schedule, run_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (module, name) WHERE schedule <> '' DO UPDATE
+		SET payload = EXCLUDED.payload, schedule = EXCLUDED.schedule, failed = FALSE,
+			run_at = CASE WHEN jobs.schedule = EXCLUDED.schedule THEN jobs.run_at ELSE EXCLUDED.run_at END`
+
+	// $1 = runnable "module/name" keys $2 = locked_until $3 = limit
+	sqlJobClaim = `
+		UPDATE jobs SET locked_until = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE NOT failed AND run_at <= now()
+			AND (locked_until IS NULL OR locked_until < now())
+			AND module || '/' || name = ANY($1)
+			ORDER BY run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, module, name, payload, schedule, run_at, attempts`
+
+	// $1 = runnable "module/name" keys
+	sqlJobNextRun = `
+		SELECT MIN(GREATEST(run_at, locked_until)) FROM jobs
+		WHERE NOT failed AND module || '/' || name = ANY($1)`
+
+	// $1 = id
+	sqlJobDelete = `DELETE FROM jobs WHERE id = $1`
+
+	// $1 = id $2 = next run_at $3 = last_error
+	sqlJobReschedule = `
+		UPDATE jobs SET run_at = $2, attempts = 0, last_error = $3, locked_until = NULL
+		WHERE id = $1`
+
+	// $1 = id $2 = retry at $3 = last_error
+	sqlJobRetry = `
+		UPDATE jobs SET run_at = $2, last_error = $3, locked_until = NULL
+		WHERE id = $1`
+
+	// $1 = id $2 = last_error
+	sqlJobFail = `
+		UPDATE jobs SET failed = TRUE, last_error = $2, locked_until = NULL
+		WHERE id = $1`
+
+	// $1 = module, or empty for all
+	sqlJobList = `
+		SELECT id, module, name, payload, schedule, run_at, attempts, last_error, failed,
+			COALESCE(locked_until > now(), FALSE)
+		FROM jobs
+		WHERE $1 = '' OR module = $1
+		ORDER BY failed, run_at`
+)
+
+// jobScheduler runs saved jobs when they are due, on every Marvin process
+// sharing the database. Claiming a job locks it for jobLockTime, so a job is
+// run again if its process dies before finishing.
+type jobScheduler struct {
+	team *Team
+
+	lock     sync.Mutex
+	handlers map[string]jobHandler
+
+	wake      chan struct{}
+	stop      chan struct{}
+	startOnce sync.Once
+}
+
+func newJobScheduler(t *Team) *jobScheduler {
+	return &jobScheduler{
+		team:     t,
+		handlers: make(map[string]jobHandler),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+type jobHandler struct {
+	module  marvin.ModuleID
+	handler marvin.JobHandler
+}
+
+func jobKey(mod marvin.ModuleID, name string) string {
+	return fmt.Sprintf("%s/%s", mod, name)
+}
+
+func (s *jobScheduler) Start() {
+	s.startOnce.Do(func() { go s.loop() })
+}
+
+func (s *jobScheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *jobScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *jobScheduler) loop() {
+	for {
+		wait := s.runDue()
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runnable lists the jobs whose module is enabled.
+func (s *jobScheduler) runnable() []string {
+	s.lock.Lock()
+	modules := make(map[string]marvin.ModuleID, len(s.handlers))
+	for k, v := range s.handlers {
+		modules[k] = v.module
+	}
+	s.lock.Unlock()
+
+	var keys []string
+	enabled := make(map[marvin.ModuleID]bool)
+	for k, mod := range modules {
+		ok, seen := enabled[mod]
+		if !seen {
+			ms := s.team.GetModuleStatus(mod)
+			ok = ms != nil && ms.IsEnabled()
+			enabled[mod] = ok
+		}
+		if ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// runDue starts every job that is due, and returns how long to wait before
+// looking again.
+func (s *jobScheduler) runDue() time.Duration {
+	keys := s.runnable()
+	if len(keys) == 0 {
+		return jobPollInterval
+	}
+
+	rows, err := s.team.DB().Query(sqlJobClaim, pq.StringArray(keys), time.Now().Add(jobLockTime), jobClaimPerCycle)
+	if err != nil {
+		util.LogError(errors.Wrap(err, "claim jobs"))
+		return jobPollInterval
+	}
+	var claimed []*marvin.Job
+	for rows.Next() {
+		job := new(marvin.Job)
+		var module string
+		err = rows.Scan(&job.ID, &module, &job.Name, &job.Payload, &job.Schedule, &job.RunAt, &job.Attempts)
+		if err != nil {
+			util.LogError(errors.Wrap(err, "claim jobs"))
+			break
+		}
+		job.Module = marvin.ModuleID(module)
+		job.Running = true
+		claimed = append(claimed, job)
+	}
+	util.LogIfError(errors.Wrap(rows.Err(), "claim jobs"))
+	rows.Close()
+
+	for _, job := range claimed {
+		go s.run(job)
+	}
+	if len(claimed) == jobClaimPerCycle {
+		return 0
+	}
+
+	var next *time.Time
+	err = s.team.DB().QueryRow(sqlJobNextRun, pq.StringArray(keys)).Scan(&next)
+	if err != nil && err != sql.ErrNoRows {
+		util.LogError(errors.Wrap(err, "find next job"))
+		return jobPollInterval
+	}
+	if next == nil {
+		return jobPollInterval
+	}
+	wait := next.Sub(time.Now())
+	if wait < time.Second {
+		wait = time.Second
+	} else if wait > jobPollInterval {
+		wait = jobPollInterval
+	}
+	return wait
+}
+
+func (s *jobScheduler) call(h marvin.JobHandler, job *marvin.Job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = errors.Errorf("panic: %v", rec)
+		}
+	}()
+	return h(s.team, job)
+}
+
+func (s *jobScheduler) run(job *marvin.Job) {
+	key := jobKey(job.Module, job.Name)
+	s.lock.Lock()
+	h, ok := s.handlers[key]
+	s.lock.Unlock()
+
+	var err error
+	if !ok {
+		err = errors.Errorf("no handler registered")
+	} else {
+		err = s.call(h.handler, job)
+	}
+	if err == nil {
+		metricJobs.With(key, "ok").Inc()
+		s.finish(job, "")
+		return
+	}
+
+	metricJobs.With(key, "error").Inc()
+	util.LogError(errors.Wrapf(err, "job %d (%s) attempt %d", job.ID, key, job.Attempts))
+	if job.Attempts < marvin.JobMaxAttempts {
+		backoff := jobRetryBase << uint(job.Attempts-1)
+		if backoff > jobRetryMax {
+			backoff = jobRetryMax
+		}
+		_, dbErr := s.team.DB().Exec(sqlJobRetry, job.ID, time.Now().Add(backoff), err.Error())
+		util.LogIfError(errors.Wrap(dbErr, "retry job"))
+		return
+	}
+
+	s.team.SendMessage(s.team.TeamConfig().LogChannel, fmt.Sprintf(
+		"Job %d (`%s`) failed after %d attempts: %v", job.ID, key, job.Attempts, err))
+	if job.Schedule != "" {
+		s.finish(job, err.Error())
+		return
+	}
+	_, dbErr := s.team.DB().Exec(sqlJobFail, job.ID, err.Error())
+	util.LogIfError(errors.Wrap(dbErr, "fail job"))
+}
+
+// finish removes a one-shot job, or moves a recurring job to its next run.
+func (s *jobScheduler) finish(job *marvin.Job, lastError string) {
+	if job.Schedule == "" {
+		_, err := s.team.DB().Exec(sqlJobDelete, job.ID)
+		util.LogIfError(errors.Wrap(err, "delete finished job"))
+		return
+	}
+	sched, err := marvin.ParseSchedule(job.Schedule)
+	var next time.Time
+	if err == nil {
+		next = sched.Next(time.Now())
+		if next.IsZero() {
+			err = errors.Errorf("schedule %q never runs again", job.Schedule)
+		}
+	}
+	if err != nil {
+		_, dbErr := s.team.DB().Exec(sqlJobFail, job.ID, err.Error())
+		util.LogIfError(errors.Wrap(dbErr, "fail job"))
+		return
+	}
+	_, err = s.team.DB().Exec(sqlJobReschedule, job.ID, next, lastError)
+	util.LogIfError(errors.Wrap(err, "reschedule job"))
+}
+
+// ---
+
+func (t *Team) RegisterJobHandler(mod marvin.ModuleID, name string, h marvin.JobHandler) {
+	t.jobs.lock.Lock()
+	t.jobs.handlers[jobKey(mod, name)] = jobHandler{module: mod, handler: h}
+	t.jobs.lock.Unlock()
+	t.jobs.signal()
+}
+
+func encodePayload(payload interface{}) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "encode job payload")
+	}
+	return string(b), nil
+}
+
+func (t *Team) ScheduleJob(mod marvin.ModuleID, name string, runAt time.Time, payload interface{}) (int64, error) {
+	data, err := encodePayload(payload)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = t.DB().QueryRow(sqlJobInsert, string(mod), name, data, runAt).Scan(&id)
+	if err != nil {
+		return 0, errors.Wrap(err, "schedule job")
+	}
+	t.jobs.signal()
+	return id, nil
+}
+
+func (t *Team) ScheduleRecurringJob(mod marvin.ModuleID, name string, schedule string, payload interface{}) error {
+	sched, err := marvin.ParseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+	first := sched.Next(time.Now())
+	if first.IsZero() {
+		return errors.Errorf("schedule %q never runs", schedule)
+	}
+	data, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+	_, err = t.DB().Exec(sqlJobUpsertRecurring, string(mod), name, data, schedule, first)
+	if err != nil {
+		return errors.Wrap(err, "schedule recurring job")
+	}
+	t.jobs.signal()
+	return nil
+}
+
+func (t *Team) CancelJob(id int64) error {
+	_, err := t.DB().Exec(sqlJobDelete, id)
+	return errors.Wrap(err, "cancel job")
+}
+
+func (t *Team) ListJobs(mod marvin.ModuleID) ([]marvin.Job, error) {
+	rows, err := t.DB().Query(sqlJobList, string(mod))
+	if err != nil {
+		return nil, errors.Wrap(err, "list jobs")
+	}
+	defer rows.Close()
+
+	var result []marvin.Job
+	for rows.Next() {
+		var job marvin.Job
+		var module string
+		err = rows.Scan(&job.ID, &module, &job.Name, &job.Payload, &job.Schedule, &job.RunAt,
+			&job.Attempts, &job.LastError, &job.Failed, &job.Running)
+		if err != nil {
+			return nil, errors.Wrap(err, "list jobs")
+		}
+		job.Module = marvin.ModuleID(module)
+		result = append(result, job)
+	}
+	return result, errors.Wrap(rows.Err(), "list jobs")
+}
@@ -1,13 +1,18 @@
 package controller
 
 import (
-	"database/sql"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/database"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
 )
 
 type DBModuleConfig struct {
@@ -19,7 +24,18 @@ type DBModuleConfig struct {
 	DefaultsLocked bool
 	defaults       map[string]string
 	protected      map[string]bool
+	schemas        map[string]marvin.ConfigSchema
 	callbacks      []func(string)
+
+	// cache holds every row of the config table for this module. It is nil
+	// until first use and after an invalidation.
+	cacheLock sync.Mutex
+	cache     map[configCacheKey]string
+}
+
+type configCacheKey struct {
+	key     string
+	channel slack.ChannelID
 }
 
 func newModuleConfig(t *Team, modID marvin.ModuleID) marvin.ModuleConfig {
@@ -30,6 +46,7 @@ func newModuleConfig(t *Team, modID marvin.ModuleID) marvin.ModuleConfig {
 		DefaultsLocked: false,
 		defaults:       make(map[string]string),
 		protected:      make(map[string]bool),
+		schemas:        make(map[string]marvin.ConfigSchema),
 		callbacks:      nil,
 	}
 	if modID == "blacklist" || modID == "apikeys" {
@@ -49,26 +66,45 @@ func MigrateModuleConfig(c *database.Conn) error {
 			CONSTRAINT confkey UNIQUE(module, key)
 		)`,
 	)
+	if err != nil {
+		return err
+	}
+	err = c.Migrate("main", 1541030400,
+		`ALTER TABLE config ADD COLUMN channel varchar(15) NOT NULL DEFAULT ''`,
+		`ALTER TABLE config DROP CONSTRAINT confkey`,
+		`ALTER TABLE config ADD CONSTRAINT confkey UNIQUE(module, key, channel)`,
+	)
 	c.SyntaxCheck(
-		sqlConfigGet,
+		sqlConfigGetAll,
 		sqlConfigSet,
+		sqlConfigReset,
 	)
 	return err
 }
 
 const (
-	sqlConfigGet = `SELECT value FROM config WHERE module = $1 AND key = $2`
+	// Team-wide values are stored with an empty channel.
+
+	// $1 = module $2 = key $3 = value $4 = channel
 	sqlConfigSet = `
-		INSERT INTO config (module, key, value)
-		VALUES ($1, $2, $3)
+		INSERT INTO config (module, key, value, channel)
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT ON CONSTRAINT confkey
 		DO UPDATE SET value = excluded.value
 			WHERE config.module = excluded.module
 			AND config.key = excluded.key
+			AND config.channel = excluded.channel
 	`
+	// $1 = module $2 = key $3 = channel
 	sqlConfigReset = `
 		DELETE FROM config
-		WHERE module = $1 AND key = $2
+		WHERE module = $1 AND key = $2 AND channel = $3
+	`
+
+	// $1 = module
+	sqlConfigGetAll = `
+		SELECT key, channel, value FROM config
+		WHERE module = $1
 	`
 )
 
@@ -87,6 +123,27 @@ func (c *DBModuleConfig) AddProtect(key string, defaultValue string, protect boo
 	c.protected[key] = protect
 }
 
+func (c *DBModuleConfig) AddTyped(key string, defaultValue string, schema marvin.ConfigSchema) {
+	if c.DefaultsLocked {
+		panic("Module configuration must be set up during Load()")
+	}
+	c.defaults[key] = defaultValue
+	c.protected[key] = schema.Protected
+	c.schemas[key] = schema
+}
+
+func (c *DBModuleConfig) Validate(key, value string) error {
+	schema, ok := c.schemas[key]
+	if !ok {
+		return nil
+	}
+	err := schema.Validate(value)
+	if err != nil {
+		return marvin.ErrConfInvalid{Key: fmt.Sprintf("%s.%s", c.ModuleIdentifier, key), Reason: err}
+	}
+	return nil
+}
+
 func (c *DBModuleConfig) OnModify(f func(key string)) {
 	if c.DefaultsLocked {
 		panic("Module configuration must be set up during Load()")
@@ -94,29 +151,85 @@ func (c *DBModuleConfig) OnModify(f func(key string)) {
 	c.callbacks = append(c.callbacks, f)
 }
 
-func (c *DBModuleConfig) Get(key string) (string, error) {
-	def, haveDefault := c.defaults[key]
-	if !haveDefault {
-		panic("Get() must have a default set")
+// lookup returns the stored value for the key, loading the cache if needed.
+func (c *DBModuleConfig) lookup(key string, channel slack.ChannelID) (string, bool, error) {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
+	if c.cache == nil {
+		err := c.loadCache()
+		if err != nil {
+			return "", false, err
+		}
 	}
+	value, ok := c.cache[configCacheKey{key: key, channel: channel}]
+	return value, ok, nil
+}
 
-	stmt, err := c.team.DB().Prepare(sqlConfigGet)
+// loadCache must be called with cacheLock held.
+func (c *DBModuleConfig) loadCache() error {
+	stmt, err := c.team.DB().Prepare(sqlConfigGetAll)
 	if err != nil {
-		return def, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
+		return errors.Wrapf(err, "config.load(%s)", c.ModuleIdentifier)
 	}
 	defer stmt.Close()
 
-	row := stmt.QueryRow(c.ModuleIdentifier, key)
+	rows, err := stmt.Query(c.ModuleIdentifier)
+	if err != nil {
+		return errors.Wrapf(err, "config.load(%s)", c.ModuleIdentifier)
+	}
+	defer rows.Close()
+
+	cache := make(map[configCacheKey]string)
+	for rows.Next() {
+		var key, channel, value string
+		err = rows.Scan(&key, &channel, &value)
+		if err != nil {
+			return errors.Wrapf(err, "config.load(%s)", c.ModuleIdentifier)
+		}
+		cache[configCacheKey{key: key, channel: slack.ChannelID(channel)}] = value
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Wrapf(err, "config.load(%s)", c.ModuleIdentifier)
+	}
+	c.cache = cache
+	return nil
+}
+
+// invalidate drops the cache, so the next lookup reads from the database.
+func (c *DBModuleConfig) invalidate() {
+	c.cacheLock.Lock()
+	c.cache = nil
+	c.cacheLock.Unlock()
+}
+
+func (c *DBModuleConfig) fireCallbacks(key string) {
+	for _, v := range c.callbacks {
+		go v(key)
+	}
+}
 
-	var result sql.NullString
-	err = row.Scan(&result)
+// modified is called after a write to the database.
+func (c *DBModuleConfig) modified(key string) {
+	c.invalidate()
+	util.LogIfError(c.team.DB().Notify(configNotifyChannel,
+		fmt.Sprintf("%s %s %s", c.team.instanceID, c.ModuleIdentifier, key)))
+	c.fireCallbacks(key)
+}
 
-	if !result.Valid {
+func (c *DBModuleConfig) Get(key string) (string, error) {
+	def, haveDefault := c.defaults[key]
+	if !haveDefault {
+		panic("Get() must have a default set")
+	}
+
+	value, ok, err := c.lookup(key, "")
+	if err != nil {
+		return def, err
+	} else if !ok {
 		return def, nil
-	} else if err != nil {
-		return def, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
 	}
-	return result.String, nil
+	return value, nil
 }
 
 // GetIsDefault gets a module configuration value, but does not require the key have been initialized.
@@ -129,87 +242,125 @@ func (c *DBModuleConfig) Get(key string) (string, error) {
 func (c *DBModuleConfig) GetIsDefault(key string) (string, bool, error) {
 	def, haveDefault := c.defaults[key]
 
-	stmt, err := c.team.DB().Prepare(sqlConfigGet)
+	value, ok, err := c.lookup(key, "")
 	if err != nil {
-		return def, true, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
-	}
-	defer stmt.Close()
-
-	row := stmt.QueryRow(c.ModuleIdentifier, key)
-	var result sql.NullString
-	err = row.Scan(&result)
-	if !result.Valid {
+		return def, true, err
+	} else if !ok {
 		if haveDefault {
 			return def, true, nil
 		} else {
 			return "", true, marvin.ErrConfNoDefault{Key: fmt.Sprintf("%s.%s", c.ModuleIdentifier, key)}
 		}
-	} else if err != nil {
-		return def, true, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
 	}
-	return result.String, false, nil
+	return value, false, nil
 }
 
 func (c *DBModuleConfig) GetIsDefaultNotProtected(key string) (string, bool, error) {
-	def, haveDefault := c.defaults[key]
-
 	if c.protected[key] {
 		return "__ERROR", true, marvin.ErrConfProtected{Key: fmt.Sprintf("%s.%s", c.ModuleIdentifier, key)}
 	}
+	return c.GetIsDefault(key)
+}
 
-	stmt, err := c.team.DB().Prepare(sqlConfigGet)
-	if err != nil {
-		return def, true, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
-	}
-	defer stmt.Close()
+func (c *DBModuleConfig) Set(key, value string) error {
+	return c.SetForChannel(key, "", value)
+}
 
-	row := stmt.QueryRow(c.ModuleIdentifier, key)
-	var result sql.NullString
-	err = row.Scan(&result)
-	if !result.Valid {
-		if haveDefault {
-			return def, true, nil
-		} else {
-			return "", true, marvin.ErrConfNoDefault{Key: fmt.Sprintf("%s.%s", c.ModuleIdentifier, key)}
+func (c *DBModuleConfig) SetDefault(key string) error {
+	return c.SetDefaultForChannel(key, "")
+}
+
+func (c *DBModuleConfig) GetForChannel(key string, channel slack.ChannelID) (string, error) {
+	def, haveDefault := c.defaults[key]
+	if !haveDefault {
+		panic("GetForChannel() must have a default set")
+	}
+	if channel != "" {
+		value, ok, err := c.lookup(key, channel)
+		if err != nil {
+			return def, err
+		} else if ok {
+			return value, nil
 		}
-	} else if err != nil {
-		return def, true, errors.Wrapf(err, "config.get(%s, %s)", c.ModuleIdentifier, key)
 	}
-	return result.String, false, nil
+	return c.Get(key)
 }
 
-func (c *DBModuleConfig) Set(key, value string) error {
+func (c *DBModuleConfig) SetForChannel(key string, channel slack.ChannelID, value string) error {
+	err := c.Validate(key, value)
+	if err != nil {
+		return err
+	}
+
 	stmt, err := c.team.DB().Prepare(sqlConfigSet)
 	if err != nil {
 		return errors.Wrapf(err, "moduleconfig.set(%s, %s)", c.ModuleIdentifier, key)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(c.ModuleIdentifier, key, value)
+	_, err = stmt.Exec(c.ModuleIdentifier, key, value, string(channel))
 	if err != nil {
 		return errors.Wrapf(err, "moduleconfig.set(%s, %s)", c.ModuleIdentifier, key)
 	}
 
-	for _, v := range c.callbacks {
-		go v(key)
-	}
+	c.modified(key)
 	return nil
 }
 
-func (c *DBModuleConfig) SetDefault(key string) error {
+func (c *DBModuleConfig) SetDefaultForChannel(key string, channel slack.ChannelID) error {
 	stmt, err := c.team.DB().Prepare(sqlConfigReset)
 	if err != nil {
 		return errors.Wrapf(err, "moduleconfig.set(%s, %s)", c.ModuleIdentifier, key)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(c.ModuleIdentifier, key)
+	_, err = stmt.Exec(c.ModuleIdentifier, key, string(channel))
 	if err != nil {
 		return errors.Wrapf(err, "moduleconfig.set(%s, %s)", c.ModuleIdentifier, key)
 	}
+
+	c.modified(key)
 	return nil
 }
 
+func (c *DBModuleConfig) ListChannelOverrides(key string) (map[slack.ChannelID]string, error) {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
+	if c.cache == nil {
+		err := c.loadCache()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[slack.ChannelID]string)
+	for k, v := range c.cache {
+		if k.key == key && k.channel != "" {
+			result[k.channel] = v
+		}
+	}
+	return result, nil
+}
+
+func (c *DBModuleConfig) ListOverrides() (map[string]string, error) {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
+	if c.cache == nil {
+		err := c.loadCache()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string]string)
+	for k, v := range c.cache {
+		if k.channel == "" {
+			result[k.key] = v
+		}
+	}
+	return result, nil
+}
+
 func (c *DBModuleConfig) ListDefaults() map[string]string {
 	if !c.DefaultsLocked {
 		//panic("ListDefaults() called before defaults locked")
@@ -224,6 +375,18 @@ func (c *DBModuleConfig) ListProtected() map[string]bool {
 	return c.protected
 }
 
+func (c *DBModuleConfig) ListSchemas() map[string]marvin.ConfigSchema {
+	result := make(map[string]marvin.ConfigSchema, len(c.defaults))
+	for key := range c.defaults {
+		schema, ok := c.schemas[key]
+		if !ok {
+			schema = marvin.ConfigSchema{Type: marvin.ConfTypeString, Protected: c.protected[key]}
+		}
+		result[key] = schema
+	}
+	return result
+}
+
 func (c *DBModuleConfig) LockDefaults() {
 	c.DefaultsLocked = true
 }
@@ -235,3 +398,82 @@ type AllProtectedModuleConfig struct {
 func (c AllProtectedModuleConfig) GetIsDefaultNotProtected(key string) (string, bool, error) {
 	return "__ERROR", true, marvin.ErrConfProtected{Key: fmt.Sprintf("%s.%s", c.ModuleIdentifier, key)}
 }
+
+// ---
+
+// configNotifyChannel is the Postgres NOTIFY channel for config changes. The
+// payload is "<instance> <module> <key>".
+const configNotifyChannel = "marvin_config"
+
+// listenConfig keeps the config caches of this process in sync with changes
+// made by other Marvin processes using the same database.
+func (t *Team) listenConfig() error {
+	l, err := t.db.Listen(configNotifyChannel, t.onConfigNotify, t.onConfigReconnect)
+	if err != nil {
+		return err
+	}
+	t.confListener = l
+	return nil
+}
+
+func (t *Team) dbModuleConfig(modID marvin.ModuleID) *DBModuleConfig {
+	t.confLock.Lock()
+	conf := t.confMap[modID]
+	t.confLock.Unlock()
+
+	switch c := conf.(type) {
+	case *DBModuleConfig:
+		return c
+	case AllProtectedModuleConfig:
+		return c.DBModuleConfig
+	}
+	return nil
+}
+
+func (t *Team) onConfigNotify(payload string) {
+	split := strings.SplitN(payload, " ", 3)
+	if len(split) != 3 {
+		util.LogWarn("bad config notification:", payload)
+		return
+	}
+	if split[0] == t.instanceID {
+		// Already handled in modified()
+		return
+	}
+	c := t.dbModuleConfig(marvin.ModuleID(split[1]))
+	if c == nil {
+		return
+	}
+	c.invalidate()
+	c.fireCallbacks(split[2])
+}
+
+// onConfigReconnect drops all caches, as notifications may have been missed.
+// Every key is reported as modified, so that modules caching values of their
+// own reload them too.
+func (t *Team) onConfigReconnect() {
+	t.confLock.Lock()
+	var modIDs []marvin.ModuleID
+	for k := range t.confMap {
+		modIDs = append(modIDs, k)
+	}
+	t.confLock.Unlock()
+
+	for _, v := range modIDs {
+		if c := t.dbModuleConfig(v); c != nil {
+			c.invalidate()
+			for key := range c.defaults {
+				c.fireCallbacks(key)
+			}
+		}
+	}
+}
+
+func newInstanceID() string {
+	var b [8]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(errors.Wrap(err, "crypto/rand"))
+	}
+	return hex.EncodeToString(b[:])
+}
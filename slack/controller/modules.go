@@ -11,7 +11,7 @@ import (
 	"github.com/riking/marvin/util"
 )
 
-const ConfTurnOffModule = "off"
+const ConfTurnOffModule = marvin.ConfTurnOffModule
 
 // DependModule places the instance of the requested module in the given pointer.
 //
@@ -89,81 +89,110 @@ func (t *Team) GetAllEnabledModules() []marvin.ModuleStatus {
 }
 
 func (t *Team) EnableModule(ident marvin.ModuleID) error {
-	var idx int = -1
-
-	for i, ms := range t.modules {
-		if ms.identifier == ident {
-			idx = i
-			break
-		}
-	}
-	if idx == -1 {
+	ms := t.getModuleStatus(ident)
+	if ms == nil {
 		return errors.Errorf("No such module '%s'", ident)
 	}
 
-	switch t.modules[idx].state {
+	switch ms.state {
 	case marvin.ModuleStateEnabled:
 		// Do nothing
 		return nil
-	case marvin.ModuleStateConstructed:
-	case marvin.ModuleStateErrorLoading:
+	case marvin.ModuleStateLoaded, marvin.ModuleStateErrorEnabling, marvin.ModuleStateDisabled:
+		// OK
 	default:
 		return errors.Errorf("module must complete loading first")
-	case marvin.ModuleStateLoaded:
-	case marvin.ModuleStateErrorEnabling:
-	case marvin.ModuleStateDisabled:
-		// OK
-		break
 	}
 
-	err := t.enableModule2(t.modules[idx])
-	if err != nil {
-		return errors.Wrapf(err, "Could not enable '%s'", ident)
+	for _, v := range ms.Dependencies {
+		dependMS := t.getModuleStatus(v.Identifier)
+		if dependMS == nil || !dependMS.IsEnabled() {
+			return errors.Errorf("Could not enable '%s': dependency '%s' is not enabled", ident, v.Identifier)
+		}
+		*v.Pointer = dependMS.instance
 	}
 
-	for _, v := range t.modules[idx].Dependencies {
-		*v.Pointer = t.modules[idx].instance
+	err := t.enableModule2(ms)
+	if err != nil {
+		return errors.Wrapf(err, "Could not enable '%s'", ident)
 	}
 	return nil
 }
 
-func (t *Team) DisableModule(ident marvin.ModuleID) error {
-	var ms *moduleStatus
-
-	for _, v := range t.modules {
-		if v.identifier == ident {
-			ms = v
-			break
-		}
-	}
+func (t *Team) DisableModule(ident marvin.ModuleID) ([]marvin.ModuleID, error) {
+	ms := t.getModuleStatus(ident)
 	if ms == nil {
-		return errors.Errorf("No such module '%s'", ident)
+		return nil, errors.Errorf("No such module '%s'", ident)
 	}
 
 	switch ms.state {
-	case marvin.ModuleStateDisabled:
-	case marvin.ModuleStateLoaded:
-	case marvin.ModuleStateConstructed:
+	case marvin.ModuleStateDisabled, marvin.ModuleStateLoaded, marvin.ModuleStateConstructed:
 		// Do nothing
-		return nil
-	case marvin.ModuleStateErrorLoading:
-	case marvin.ModuleStateErrorEnabling:
-	default:
-		return errors.Errorf("module must complete loading first")
-	case marvin.ModuleStateEnabled:
+		return nil, nil
+	case marvin.ModuleStateEnabled, marvin.ModuleStateErrorEnabling:
 		// OK
-		break
+	default:
+		return nil, errors.Errorf("module must complete loading first")
+	}
+
+	var cascaded []marvin.ModuleID
+	for _, dependent := range t.enabledDependents(ident) {
+		err := t.disableModule2(dependent)
+		if err != nil {
+			return cascaded, errors.Wrapf(err, "Failure disabling '%s', which depends on '%s'", dependent.identifier, ident)
+		}
+		cascaded = append(cascaded, dependent.identifier)
 	}
 
+	err := t.disableModule2(ms)
+	if err != nil {
+		return cascaded, errors.Wrapf(err, "Failure disabling '%s'", ident)
+	}
+	return cascaded, nil
+}
+
+// enabledDependents returns the enabled modules that depend on ident,
+// directly or indirectly. Each module is listed before its own dependencies,
+// so they can be disabled in order.
+func (t *Team) enabledDependents(ident marvin.ModuleID) []*moduleStatus {
+	var result []*moduleStatus
+	seen := make(map[marvin.ModuleID]bool)
+
+	var visit func(ident marvin.ModuleID)
+	visit = func(ident marvin.ModuleID) {
+		for _, ms := range t.modules {
+			if seen[ms.identifier] || !ms.IsEnabled() {
+				continue
+			}
+			for _, v := range ms.Dependencies {
+				if v.Identifier == ident {
+					seen[ms.identifier] = true
+					visit(ms.identifier)
+					result = append(result, ms)
+					break
+				}
+			}
+		}
+	}
+	visit(ident)
+	return result
+}
+
+func (t *Team) disableModule2(ms *moduleStatus) error {
 	err := protectedCallT(t, ms.instance.Disable)
+	// Don't trust modules to clean up their event handlers
+	t.OffAllEvents(ms.identifier)
 
 	for _, v := range ms.Dependencies {
 		*v.Pointer = nil
 	}
-
+	ms.state = marvin.ModuleStateDisabled
 	if err != nil {
-		return errors.Wrapf(err, "Failure disabling '%s'", ident)
+		ms.degradeReason = err
+		util.LogBadf("Disabling module %s failed: %+v\n", ms.identifier, err)
+		return err
 	}
+	util.LogGood("Disabled module", ms.identifier)
 	return nil
 }
 
@@ -210,6 +239,14 @@ func (ms *moduleStatus) Err() error {
 	return ms.degradeReason
 }
 
+func (ms *moduleStatus) DependsOn() []marvin.ModuleID {
+	var result []marvin.ModuleID
+	for _, v := range ms.Dependencies {
+		result = append(result, v.Identifier)
+	}
+	return result
+}
+
 func (t *Team) constructModules() bool {
 	var modList []*moduleStatus
 	var err error
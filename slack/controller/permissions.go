@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/database"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/util"
+)
+
+func MigratePermissions(c *database.Conn) error {
+	err := c.Migrate("main", 1541721600,
+		`CREATE TABLE roles_permissions (
+			id SERIAL PRIMARY KEY,
+			role varchar(64) NOT NULL,
+			permission varchar(255) NOT NULL,
+
+			CONSTRAINT roles_permissions_uniq UNIQUE(role, permission)
+		)`,
+		`CREATE TABLE roles_members (
+			id SERIAL PRIMARY KEY,
+			role varchar(64) NOT NULL,
+			user_id varchar(15) NOT NULL,
+			channel varchar(15) NOT NULL DEFAULT '',
+
+			CONSTRAINT roles_members_uniq UNIQUE(role, user_id, channel)
+		)`,
+		`CREATE INDEX roles_members_user ON roles_members (user_id)`,
+	)
+	if err != nil {
+		return err
+	}
+	c.SyntaxCheck(
+		sqlCheckPermission,
+		sqlGrantRole,
+		sqlRevokeRole,
+		sqlAddRolePermission,
+		sqlRemoveRolePermission,
+		sqlListRoles,
+		sqlListRoleGrants,
+	)
+	return nil
+}
+
+const (
+	// $1 = user $2 = channel $3 = permission
+	sqlCheckPermission = `
+		SELECT 1 FROM roles_members m
+		JOIN roles_permissions p ON m.role = p.role
+		WHERE m.user_id = $1 AND m.channel IN ('', $2)
+		AND p.permission IN ($3, '*')
+		LIMIT 1`
+
+	// $1 = role $2 = user $3 = channel
+	sqlGrantRole = `
+		INSERT INTO roles_members (role, user_id, channel)
+		VALUES ($1, $2, $3)
+		ON CONFLICT ON CONSTRAINT roles_members_uniq DO NOTHING`
+
+	// $1 = role $2 = user $3 = channel
+	sqlRevokeRole = `
+		DELETE FROM roles_members
+		WHERE role = $1 AND user_id = $2 AND channel = $3`
+
+	// $1 = role $2 = permission
+	sqlAddRolePermission = `
+		INSERT INTO roles_permissions (role, permission)
+		VALUES ($1, $2)
+		ON CONFLICT ON CONSTRAINT roles_permissions_uniq DO NOTHING`
+
+	// $1 = role $2 = permission
+	sqlRemoveRolePermission = `
+		DELETE FROM roles_permissions
+		WHERE role = $1 AND permission = $2`
+
+	sqlListRoles = `SELECT role, permission FROM roles_permissions ORDER BY role, permission`
+
+	// $1 = user
+	sqlListRoleGrants = `
+		SELECT role, channel FROM roles_members
+		WHERE user_id = $1
+		ORDER BY role, channel`
+)
+
+func (t *Team) RegisterCommandPerm(name string, c marvin.SubCommand, perm marvin.Permission) {
+	t.commands.RegisterCommandPerm(name, c, perm)
+}
+
+func (t *Team) RegisterCommandFuncPerm(name string, c marvin.SubCommandFunc, help string, perm marvin.Permission) marvin.SubCommand {
+	return t.commands.RegisterCommandFuncPerm(name, c, help, perm)
+}
+
+func (t *Team) ListPermissions() []marvin.Permission {
+	return t.commands.ListPermissions()
+}
+
+func (t *Team) CheckPermission(source marvin.ActionSource, perm marvin.Permission) bool {
+	level := source.AccessLevel()
+	if level >= perm.Level || level == marvin.AccessLevelController {
+		return true
+	}
+	if level < marvin.AccessLevelNormal {
+		return false
+	}
+
+	stmt, err := t.DB().Prepare(sqlCheckPermission)
+	if err != nil {
+		util.LogError(errors.Wrap(err, "check permission"))
+		return false
+	}
+	defer stmt.Close()
+
+	var found int
+	err = stmt.QueryRow(string(source.UserID()), string(source.ChannelID()), perm.Name).Scan(&found)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			util.LogError(errors.Wrap(err, "check permission"))
+		}
+		return false
+	}
+	return true
+}
+
+func (t *Team) GrantRole(role string, user slack.UserID, channel slack.ChannelID) error {
+	_, err := t.DB().Exec(sqlGrantRole, role, string(user), string(channel))
+	return errors.Wrap(err, "grant role")
+}
+
+func (t *Team) RevokeRole(role string, user slack.UserID, channel slack.ChannelID) error {
+	_, err := t.DB().Exec(sqlRevokeRole, role, string(user), string(channel))
+	return errors.Wrap(err, "revoke role")
+}
+
+func (t *Team) SetRolePermission(role string, permission string, granted bool) error {
+	query := sqlRemoveRolePermission
+	if granted {
+		query = sqlAddRolePermission
+	}
+	_, err := t.DB().Exec(query, role, permission)
+	return errors.Wrap(err, "set role permission")
+}
+
+func (t *Team) ListRoles() (map[string][]string, error) {
+	rows, err := t.DB().Query(sqlListRoles)
+	if err != nil {
+		return nil, errors.Wrap(err, "list roles")
+	}
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var role, permission string
+		err = rows.Scan(&role, &permission)
+		if err != nil {
+			return nil, errors.Wrap(err, "list roles")
+		}
+		result[role] = append(result[role], permission)
+	}
+	return result, errors.Wrap(rows.Err(), "list roles")
+}
+
+func (t *Team) ListRoleGrants(user slack.UserID) ([]marvin.RoleGrant, error) {
+	rows, err := t.DB().Query(sqlListRoleGrants, string(user))
+	if err != nil {
+		return nil, errors.Wrap(err, "list role grants")
+	}
+	defer rows.Close()
+
+	var result []marvin.RoleGrant
+	for rows.Next() {
+		var role, channel string
+		err = rows.Scan(&role, &channel)
+		if err != nil {
+			return nil, errors.Wrap(err, "list role grants")
+		}
+		result = append(result, marvin.RoleGrant{Role: role, UserID: user, Channel: slack.ChannelID(channel)})
+	}
+	return result, errors.Wrap(rows.Err(), "list role grants")
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/riking/marvin"
 	"github.com/riking/marvin/database"
+	"github.com/riking/marvin/metrics"
 	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/slack/rtm"
 	"github.com/riking/marvin/util"
@@ -26,13 +27,21 @@ import (
 type Team struct {
 	teamConfig *marvin.TeamConfig
 	client     *rtm.Client
+	events     EventSource
 	db         *database.Conn
 	commands   *marvin.ParentCommand
 
 	modules []*moduleStatus
 
-	confLock sync.Mutex
-	confMap  map[marvin.ModuleID]marvin.ModuleConfig
+	confLock     sync.Mutex
+	confMap      map[marvin.ModuleID]marvin.ModuleConfig
+	confListener *database.Listener
+	// instanceID distinguishes this process in config notifications
+	instanceID string
+
+	apiQueue *apiQueue
+	jobs     *jobScheduler
+	async    asyncCommands
 
 	outerHttp http.Handler
 	httpMux   *mux.Router
@@ -49,6 +58,18 @@ func NewTeam(cfg *marvin.TeamConfig) (*Team, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = MigratePermissions(db)
+	if err != nil {
+		return nil, err
+	}
+	err = MigrateAudit(db)
+	if err != nil {
+		return nil, err
+	}
+	err = MigrateJobs(db)
+	if err != nil {
+		return nil, err
+	}
 
 	t := &Team{
 		teamConfig: cfg,
@@ -57,8 +78,17 @@ func NewTeam(cfg *marvin.TeamConfig) (*Team, error) {
 		commands:   marvin.NewParentCommand(),
 		modules:    nil,
 		confMap:    make(map[marvin.ModuleID]marvin.ModuleConfig),
+		instanceID: newInstanceID(),
+		apiQueue:   newAPIQueue(),
 		httpMux:    mux.NewRouter(),
 	}
+	t.jobs = newJobScheduler(t)
+
+	err = t.listenConfig()
+	if err != nil {
+		// Changes from other processes won't be seen until restart
+		util.LogBad("could not listen for config changes:", err)
+	}
 
 	u, err := url.Parse(cfg.HTTPURL)
 	if err != nil {
@@ -68,14 +98,29 @@ func NewTeam(cfg *marvin.TeamConfig) (*Team, error) {
 		t.httpStrip = u.Path
 	}
 
+	t.httpMux.Path("/metrics").Methods(http.MethodGet).Handler(metrics.Handler())
+
 	t.outerHttp = t.httpMux
 	t.addCSRFMiddleware()
+	t.HTTPMiddleware(skipCSRFForSlack)
 
 	return t, nil
 }
 
+// ConnectRTM sets the rtm.Client used for team metadata and event dispatch,
+// and sets up the event source selected by the EventTransport config key.
 func (t *Team) ConnectRTM(c *rtm.Client) {
 	t.client = c
+	if t.teamConfig.EventTransport == marvin.EventTransportHTTP {
+		t.events = newEventsAPISource(t, c)
+	} else {
+		t.events = rtmEventSource{client: c}
+	}
+}
+
+// StartEvents starts delivering events from the configured event source.
+func (t *Team) StartEvents() error {
+	return t.events.Start()
 }
 
 func (t *Team) EnableModules() bool {
@@ -101,11 +146,18 @@ func (t *Team) EnableModules() bool {
 	if !t.enableModules() {
 		return false
 	}
+	t.jobs.Start()
 	return true
 }
 
 func (t *Team) Shutdown() {
+	t.jobs.Stop()
+	t.cancelAllCommands()
 	t.disableModules()
+	if t.confListener != nil {
+		util.LogIfError(t.confListener.Close())
+	}
+	t.apiQueue.Stop()
 	util.LogIfError(errors.Wrap(
 		t.DB().Close(), "db shutdown"))
 	// t.client.Stop()
@@ -179,7 +231,55 @@ func (t *Team) UnregisterCommand(name string) {
 	t.commands.UnregisterCommand(name)
 }
 
+func (t *Team) RegisterAlias(name string, expansion string) error {
+	return t.commands.RegisterAlias(name, expansion)
+}
+
+func (t *Team) UnregisterAlias(name string) {
+	t.commands.UnregisterAlias(name)
+}
+
+var (
+	metricCommands = metrics.NewCounterVec("marvin_commands_total",
+		"Commands run, by command and result.", "command", "result")
+	metricCommandTime = metrics.NewHistogramVec("marvin_command_duration_seconds",
+		"Time taken to run commands.", metrics.DefBuckets, "command")
+	metricAPICalls = metrics.NewCounterVec("marvin_slack_api_calls_total",
+		"Slack Web API calls, by method.", "method")
+	metricAPIErrors = metrics.NewCounterVec("marvin_slack_api_errors_total",
+		"Slack Web API calls that failed, by method.", "method")
+)
+
 func (t *Team) DispatchCommand(args *marvin.CommandArguments) marvin.CommandResult {
+	stages, err := marvin.SplitPipeline(args.Arguments)
+	if err != nil {
+		return marvin.CmdFailuref(args, "%v", err).WithSimpleUndo()
+	} else if stages != nil {
+		return marvin.RunPipeline(args, stages, func(stageArgs *marvin.CommandArguments) marvin.CommandResult {
+			return marvin.WaitCommand(t, t.DispatchCommand(stageArgs))
+		})
+	}
+
+	// Only label registered commands, so typos don't make new series
+	command := "(unknown)"
+	if len(args.Arguments) > 0 && t.commands.HasCommand(args.Arguments[0]) {
+		command = args.Arguments[0]
+	}
+	start := time.Now()
+	result := t.dispatchCommand(args)
+	metricCommands.With(command, result.Code.String()).Inc()
+	metricCommandTime.With(command).ObserveSince(start)
+	return result
+}
+
+func (t *Team) dispatchCommand(args *marvin.CommandArguments) marvin.CommandResult {
+	for _, perm := range t.commands.RequiredPermissions(args.Arguments) {
+		if !t.CheckPermission(args.Source, perm) {
+			return marvin.CmdFailuref(args, "Sorry, %v, I can't let you do that. This command needs the `%s` permission.",
+				args.Source.UserID(), perm.Name).WithSimpleUndo()
+		}
+	}
+
 	var result marvin.CommandResult
 	err := util.PCall(func() error {
 		result = t.commands.Handle(t, args)
@@ -198,7 +298,16 @@ func (t *Team) Help(args *marvin.CommandArguments) marvin.CommandResult {
 // ---
 
 func (t *Team) SendMessage(channel slack.ChannelID, message string) (slack.MessageTS, slack.RTMRawMessage, error) {
-	msg, err := t.client.SendMessage(channel, message)
+	if t.teamConfig.EventTransport == marvin.EventTransportHTTP {
+		// No websocket to send over
+		return t.SendComplexMessage(channel, slack.OutgoingSlackMessage{Text: message})
+	}
+	var msg slack.RTMRawMessage
+	err := t.apiQueue.Do(tierRTM, channel, slack.PriorityNormal, func(int) (time.Duration, error) {
+		var err error
+		msg, err = t.client.SendMessage(channel, message)
+		return 0, err
+	})
 	if err != nil {
 		return "", msg, err
 	}
@@ -221,6 +330,13 @@ func (t *Team) SendComplexMessage(channelID slack.ChannelID, message slack.Outgo
 		}
 		form.Set("attachments", string(b))
 	}
+	if message.Blocks != nil {
+		b, err := json.Marshal(message.Blocks)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "building messsage")
+		}
+		form.Set("blocks", string(b))
+	}
 	if message.LinkNames != util.TriDefault {
 		b, err := message.LinkNames.MarshalJSON()
 		if err != nil {
@@ -249,13 +365,16 @@ func (t *Team) SendComplexMessage(channelID slack.ChannelID, message slack.Outgo
 	}
 	if message.ThreadTS != "" {
 		form.Set("thread_ts", string(message.ThreadTS))
+		if message.Broadcast {
+			form.Set("reply_broadcast", "true")
+		}
 	}
 
 	var resp struct {
 		TS      slack.MessageTS `json:"ts"`
 		Channel slack.ChannelID `json:"channel"`
 	}
-	err := t.SlackAPIPostJSON("chat.postMessage", form, &resp)
+	err := t.slackAPIPostJSON("chat.postMessage", form, &resp, message.Priority)
 	if err != nil {
 		return "", nil, err
 	}
@@ -271,12 +390,21 @@ func (t *Team) ReactMessage(msgID slack.MessageID, emojiName string) error {
 	return t.SlackAPIPostJSON("reactions.add", form, nil)
 }
 
+// APIURL returns the base URL that Slack Web API method names are appended
+// to, including the trailing slash.
+func (t *Team) APIURL() string {
+	if t.teamConfig.SlackAPIURL != "" {
+		return t.teamConfig.SlackAPIURL
+	}
+	return slack.DefaultAPIURL
+}
+
 func (t *Team) SlackAPIPostRaw(method string, form url.Values) (*http.Response, error) {
 	var u string
-	if strings.HasPrefix(method, "https://slack.com") {
+	if strings.HasPrefix(method, "https://") || strings.HasPrefix(method, "http://") {
 		u = method
 	} else {
-		u = fmt.Sprintf("https://slack.com/api/%s", method)
+		u = t.APIURL() + method
 	}
 
 	// Allow custom tokens
@@ -298,32 +426,57 @@ func (t *Team) SlackAPIPostRaw(method string, form url.Values) (*http.Response,
 }
 
 func (t *Team) SlackAPIPostJSON(method string, form url.Values, result interface{}) error {
+	return t.slackAPIPostJSON(method, form, result, slack.PriorityNormal)
+}
+
+// SlackAPIQueueStats returns a snapshot of the outbound API queue.
+func (t *Team) SlackAPIQueueStats() slack.APIQueueStats {
+	return t.apiQueue.Stats()
+}
+
+// slackAPIPostJSON sends the call through the outbound queue, retrying it if
+// Slack says we are going too fast.
+func (t *Team) slackAPIPostJSON(method string, form url.Values, result interface{}, prio slack.APIPriority) error {
 	var rawResponse json.RawMessage
 	var slackResponse slack.APIResponse
 
-	resp, err := t.SlackAPIPostRaw(method, form)
-	if err != nil {
-		util.LogBadf("Slack API %s error: %s", method, err)
-		return errors.Wrapf(err, "Slack API %s: connect", method)
-	}
-	err = json.NewDecoder(resp.Body).Decode(&rawResponse)
-	resp.Body.Close()
-	if err != nil {
-		util.LogBadf("Slack API %s error: %s", method, err)
-		return errors.Wrapf(err, "Slack API %s: decode json", method)
-	}
-	err = json.Unmarshal(rawResponse, &slackResponse)
+	err := t.apiQueue.Do(tierForMethod(method), slack.ChannelID(form.Get("channel")), prio, func(attempt int) (time.Duration, error) {
+		metricAPICalls.With(method).Inc()
+		resp, err := t.SlackAPIPostRaw(method, form)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Slack API %s: connect", method)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			util.LogWarnf("Slack API %s: rate limited (attempt %d)", method, attempt+1)
+			return retryAfter(resp, attempt), errors.Errorf("Slack API %s: rate limited", method)
+		}
+		rawResponse = nil
+		err = json.NewDecoder(resp.Body).Decode(&rawResponse)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Slack API %s: decode json", method)
+		}
+		slackResponse = slack.APIResponse{}
+		err = json.Unmarshal(rawResponse, &slackResponse)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Slack API %s: decode json", method)
+		}
+		if slackResponse.SlackError == "ratelimited" {
+			util.LogWarnf("Slack API %s: rate limited (attempt %d)", method, attempt+1)
+			return retryAfter(resp, attempt), errors.Wrapf(slackResponse, "Slack API %s", method)
+		}
+		return 0, nil
+	})
 	if err != nil {
+		metricAPIErrors.With(method).Inc()
 		util.LogBadf("Slack API %s error: %s", method, err)
-		return errors.Wrapf(err, "Slack API %s: decode json", method)
+		return err
 	}
 	if !slackResponse.OK {
+		metricAPIErrors.With(method).Inc()
 		err = slackResponse
 		util.LogBadf("Slack API %s error: %s", method, err)
 		util.LogBadf("Form for %s: %v", method, form)
-		if slackResponse.SlackError == "ratelimited" {
-			time.Sleep(1 * time.Second)
-		}
 		return errors.Wrapf(err, "Slack API %s", method)
 	}
 
@@ -356,7 +509,7 @@ func (t *Team) OnSpecialMessage(mod marvin.ModuleID, msgSubtype []string, f func
 	t.client.RegisterRawHandler(mod, f, "message", msgSubtype)
 }
 
-var _filterNoSubgroup = []string{""}
+var _filterNoSubgroup = []string{"", "thread_broadcast"}
 
 func (t *Team) OnNormalMessage(mod marvin.ModuleID, f func(slack.RTMRawMessage)) {
 	t.client.RegisterRawHandler(mod, f, "message", _filterNoSubgroup)
@@ -5,11 +5,19 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/riking/marvin/metrics"
 	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/util"
 	"golang.org/x/net/websocket"
 )
 
+var (
+	metricEvents = metrics.NewCounterVec("marvin_events_received_total",
+		"Events received from Slack, by type.", "type")
+	metricHandlerPanics = metrics.NewCounterVec("marvin_handler_panics_total",
+		"Panics recovered from event handlers, by module.", "module")
+)
+
 const MsgTypeAll = "_all"
 
 const pingOnIdleTime = 5 * time.Minute
@@ -148,7 +156,16 @@ func (c *Client) resetPingTimer() {
 	c.connLock.L.Unlock()
 }
 
+// DispatchMessage sends an event received from another transport, such as the
+// Events API, to the registered handlers. The msg must have the
+// slack.MsgFieldRawBytes field set.
+func (c *Client) DispatchMessage(msg slack.RTMRawMessage) {
+	c.dispatchMessage(msg)
+}
+
 func (c *Client) dispatchMessage(msg slack.RTMRawMessage) {
+	metricEvents.With(msg.Type()).Inc()
+
 	c.msgCbsLock.RLock()
 	defer c.msgCbsLock.RUnlock()
 
@@ -176,6 +193,7 @@ func (c *Client) dispatchMessage(msg slack.RTMRawMessage) {
 func dispatchOne(handler messageHandler, msg slack.RTMRawMessage) {
 	defer func() {
 		if err := recover(); err != nil {
+			metricHandlerPanics.With(string(handler.Module)).Inc()
 			util.LogError(errors.Errorf("A message handler callback panicked: %+v", err))
 		}
 	}()
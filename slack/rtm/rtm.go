@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/riking/marvin"
+	"github.com/riking/marvin/metrics"
 	"github.com/riking/marvin/slack"
 	"github.com/riking/marvin/util"
 	"golang.org/x/net/websocket"
@@ -196,6 +197,57 @@ func (c *Client) Connect() error {
 }
 
 func (c *Client) Start() {
+	c.registerInternalHandlers()
+
+	c.started = true
+	go c.pump()
+	go c.pumpSend()
+	go c.pinger()
+	c.reconnect()
+}
+
+// StartWithoutRTM sets up the client as a metadata cache and event dispatcher
+// without opening the RTM websocket. It is used when events are delivered by
+// the Events API; the caller passes each event to DispatchMessage().
+//
+// Sending messages over the websocket is not available in this mode.
+func (c *Client) StartWithoutRTM() error {
+	var authResponse struct {
+		URL          string             `json:"url"`
+		Team         string             `json:"team"`
+		User         string             `json:"user"`
+		TeamID       slack.TeamID       `json:"team_id"`
+		UserID       slack.UserID       `json:"user_id"`
+		EnterpriseID slack.EnterpriseID `json:"enterprise_id"`
+	}
+	err := c.team.SlackAPIPostJSON("auth.test", url.Values{}, &authResponse)
+	if err != nil {
+		return errors.Wrap(err, "identify bot user")
+	}
+
+	c.MetadataLock.Lock()
+	c.Self.ID = authResponse.UserID
+	c.Self.Name = authResponse.User
+	c.Team.ID = authResponse.TeamID
+	c.Team.Name = authResponse.Team
+	c.Team.Domain = c.team.Domain()
+	c.Team.EnterpriseID = authResponse.EnterpriseID
+	c.MetadataLock.Unlock()
+
+	c.registerInternalHandlers()
+	c.started = true
+	go c.fetchTeamInfo()
+
+	// Modules expect a hello event once the bot user is known.
+	hello := slack.RTMRawMessage{"type": "hello"}
+	hello[slack.MsgFieldRawBytes] = []byte(`{"type":"hello"}`)
+	c.DispatchMessage(hello)
+
+	util.LogGood("Identified to Slack as", authResponse.User)
+	return nil
+}
+
+func (c *Client) registerInternalHandlers() {
 	c.RegisterRawHandler("__internal", c.onChannelJoin, "channel_joined", nil)
 	c.RegisterRawHandler("__internal", c.onGroupJoin, "group_joined", nil)
 	c.RegisterRawHandler("__internal", c.onIMCreate, "im_created", nil)
@@ -207,12 +259,6 @@ func (c *Client) Start() {
 
 	c.RegisterRawHandler("__internal", c.onUserJoinChannel, "message", []string{"channel_join", "group_join"})
 	c.RegisterRawHandler("__internal", c.onUserLeaveChannel, "message", []string{"channel_leave", "group_leave"})
-
-	c.started = true
-	go c.pump()
-	go c.pumpSend()
-	go c.pinger()
-	c.reconnect()
 }
 
 func (c *Client) reconnect() {
@@ -223,14 +269,23 @@ func (c *Client) reconnect() {
 	}
 }
 
+var (
+	metricConnected = metrics.NewGauge("marvin_rtm_connected",
+		"Whether the RTM websocket is connected.")
+	metricReconnects = metrics.NewCounter("marvin_rtm_reconnects_total",
+		"Times the RTM websocket was reconnected after being lost.")
+)
+
 func (c *Client) reconnectWorker() {
 	doReconnect := func() {
 		c.connLock.L.Lock()
 		if c.conn != nil {
 			c.conn.Close()
+			metricReconnects.Inc()
 		}
 		c.conn = nil
 		c.connLock.L.Unlock()
+		metricConnected.Set(0)
 		util.LogWarn("Disconnected.")
 
 		for {
@@ -243,6 +298,7 @@ func (c *Client) reconnectWorker() {
 			}
 			break
 		}
+		metricConnected.Set(1)
 		c.connLock.Broadcast()
 	}
 
@@ -267,6 +323,7 @@ func (c *Client) RegisterRawHandler(
 		Cb:           cb,
 		MsgType:      typeOnly,
 		SubtypesOnly: subtypes,
+		Module:       mod,
 	})
 }
 
@@ -2,6 +2,7 @@ package slack
 
 import (
 	"encoding/json"
+	"net/url"
 
 	"github.com/riking/marvin/util"
 )
@@ -20,6 +21,7 @@ func (m RTMRawMessage) Text() string         { q, _ := m["text"].(string); retur
 func (m RTMRawMessage) MessageTS() MessageTS { q, _ := m["ts"].(string); return MessageTS(q) }
 func (m RTMRawMessage) EventTS() MessageTS   { q, _ := m["ts"].(string); return MessageTS(q) }
 func (m RTMRawMessage) IsHidden() bool       { q, _ := m["hidden"].(bool); return q }
+func (m RTMRawMessage) ThreadTS() MessageTS  { q, _ := m["thread_ts"].(string); return MessageTS(q) }
 func (m RTMRawMessage) MessageID() MessageID {
 	return MessageID{ChannelID: m.ChannelID(), MessageTS: m.MessageTS()}
 }
@@ -79,6 +81,10 @@ func (m EditMessage) Subtype() string       { return "" }
 func (m EditMessage) MessageID() MessageID {
 	return MessageID{ChannelID: m.ChannelID(), MessageTS: m.MessageTS()}
 }
+func (m EditMessage) ThreadTS() MessageTS {
+	q, _ := m.EditHash()["thread_ts"].(string)
+	return MessageTS(q)
+}
 func (m EditMessage) AssertText() bool {
 	return m.RTMRawMessage.Type() == "message" && m.RTMRawMessage.Subtype() == "message_changed"
 }
@@ -89,6 +95,9 @@ type SlackTextMessage interface {
 	MessageID() MessageID
 	MessageTS() MessageTS
 	EventTS() MessageTS
+	// ThreadTS is the timestamp of the thread parent, or "" if the message
+	// was not sent in a thread.
+	ThreadTS() MessageTS
 	Subtype() string
 	Text() string
 	AssertText() bool
@@ -122,12 +131,17 @@ const (
 type OutgoingSlackMessage struct {
 	Text        string        `json:"text,omitempty"`
 	ThreadTS    MessageTS     `json:"thread_ts,omitempty"`
+	Broadcast   bool          `json:"reply_broadcast,omitempty"`
 	Attachments []Attachment  `json:"attachments,omitempty"`
+	Blocks      []Block       `json:"blocks,omitempty"`
 	UnfurlLinks util.TriValue `json:"unfurl_links,omitempty"`
 	UnfurlMedia util.TriValue `json:"unfurl_media,omitempty"`
 	Parse       ParseStyle    `json:"parse,omitempty"`
 	LinkNames   util.TriValue `json:"link_names,omitempty"`
 	Markdown    util.TriValue `json:"mrkdwn,omitempty"`
+
+	// Priority is the message's place in the outbound queue.
+	Priority APIPriority `json:"-"`
 }
 
 type SlashCommandRequest struct {
@@ -144,6 +158,23 @@ type SlashCommandRequest struct {
 	ResponseURL string        `schema:"response_url"`
 }
 
+// ParseSlashCommand reads a slash command invocation from the POST form that
+// Slack sends.
+func ParseSlashCommand(form url.Values) SlashCommandRequest {
+	return SlashCommandRequest{
+		Token:       form.Get("token"),
+		TeamId:      TeamID(form.Get("team_id")),
+		TeamDomain:  form.Get("team_domain"),
+		ChannelId:   ChannelID(form.Get("channel_id")),
+		ChannelName: form.Get("channel_name"),
+		UserId:      UserID(form.Get("user_id")),
+		UserName:    form.Get("user_name"),
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+	}
+}
+
 type ResponseType string
 
 const (
@@ -0,0 +1,85 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderSignature        = "X-Slack-Signature"
+	HeaderRequestTimestamp = "X-Slack-Request-Timestamp"
+	HeaderRetryNum         = "X-Slack-Retry-Num"
+	HeaderRetryReason      = "X-Slack-Retry-Reason"
+
+	signatureVersion = "v0"
+	// Requests older than this are rejected to prevent replays.
+	signatureMaxAge = 5 * time.Minute
+)
+
+// ErrBadSignature is returned from VerifyRequest when the request was not
+// signed with the expected signing secret.
+type ErrBadSignature struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (e ErrBadSignature) Error() string {
+	return "slack request verification failed: " + e.Reason
+}
+
+// ComputeSignature returns the value of the X-Slack-Signature header for the
+// given timestamp and body.
+func ComputeSignature(signingSecret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, signingSecret)
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest reads the request body and checks it against the Slack
+// signing secret. The body is returned so that the caller can decode it, and
+// r.Body is left drained.
+//
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifyRequest(r *http.Request, signingSecret []byte) ([]byte, error) {
+	return verifyRequestAt(r, signingSecret, time.Now())
+}
+
+func verifyRequestAt(r *http.Request, signingSecret []byte, now time.Time) ([]byte, error) {
+	if len(signingSecret) == 0 {
+		return nil, ErrBadSignature{Reason: "no signing secret configured"}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "read request body")
+	}
+
+	timestamp := r.Header.Get(HeaderRequestTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrBadSignature{Reason: "missing timestamp"}
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > signatureMaxAge || age < -signatureMaxAge {
+		return nil, ErrBadSignature{Reason: "stale timestamp"}
+	}
+
+	sig := r.Header.Get(HeaderSignature)
+	if !strings.HasPrefix(sig, signatureVersion+"=") {
+		return nil, ErrBadSignature{Reason: "missing signature"}
+	}
+	expected := ComputeSignature(signingSecret, timestamp, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return nil, ErrBadSignature{Reason: "signature mismatch"}
+	}
+	return body, nil
+}
@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(secret, body string, ts time.Time) *http.Request {
+	r, _ := http.NewRequest("POST", "/slack/events", strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(HeaderRequestTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, ComputeSignature([]byte(secret), timestamp, []byte(body)))
+	return r
+}
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	body := `{"type":"url_verification","challenge":"abc"}`
+
+	got, err := verifyRequestAt(signedRequest("s3cret", body, now), []byte("s3cret"), now)
+	if err != nil {
+		t.Errorf("Unexpected error for valid signature: %v", err)
+	} else if string(got) != body {
+		t.Errorf("Wrong body returned:\nEXP: %s\nGOT: %s", body, got)
+	}
+
+	_, err = verifyRequestAt(signedRequest("wrong", body, now), []byte("s3cret"), now)
+	if _, ok := err.(ErrBadSignature); !ok {
+		t.Errorf("Expected ErrBadSignature for wrong secret, got %v", err)
+	}
+
+	_, err = verifyRequestAt(signedRequest("s3cret", body, now.Add(-10*time.Minute)), []byte("s3cret"), now)
+	if _, ok := err.(ErrBadSignature); !ok {
+		t.Errorf("Expected ErrBadSignature for stale timestamp, got %v", err)
+	}
+
+	r := signedRequest("s3cret", body, now)
+	r.Header.Del(HeaderSignature)
+	_, err = verifyRequestAt(r, []byte("s3cret"), now)
+	if _, ok := err.(ErrBadSignature); !ok {
+		t.Errorf("Expected ErrBadSignature for missing signature, got %v", err)
+	}
+}
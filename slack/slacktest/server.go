@@ -0,0 +1,870 @@
+/*
+Package slacktest provides an in-process fake Slack server for end-to-end
+tests of Marvin modules.
+
+The Server implements enough of the Web API and the RTM websocket protocol for
+a controller.Team to run against it: rtm.connect and the hello handshake,
+chat.*, reactions.*, pins.*, users.*, conversations.* and the legacy channel
+methods that the controller still uses. Every Web API call and every message
+sent over the websocket is recorded, and tests can inject user messages.
+
+	srv := slacktest.NewServer()
+	defer srv.Close()
+
+	cfg := &marvin.TeamConfig{
+		TeamDomain:  "test",
+		SlackAPIURL: srv.APIURL(),
+		DatabaseURL: os.Getenv("MARVIN_TEST_DATABASE"),
+		...
+	}
+	team, _ := controller.NewTeam(cfg)
+	client := rtm.NewClient(team)
+	team.ConnectRTM(client)
+	team.EnableModules()
+	team.StartEvents()
+
+	srv.InjectMessage(channel, user, "<@"+srv.BotUserID+"> factoid get hello")
+	call, ok := srv.WaitForCall("reactions.add", 5*time.Second)
+*/
+package slacktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/riking/marvin/slack"
+)
+
+// Default identifiers used by a new Server.
+const (
+	DefaultTeamID    slack.TeamID    = "T00000000"
+	DefaultBotUserID slack.UserID    = "U0000BOT0"
+	DefaultChannelID slack.ChannelID = "C0000GEN0"
+)
+
+// Call is a single recorded Web API call or outgoing RTM message.
+type Call struct {
+	Method string
+	Form   url.Values
+	// RTM is set instead of Form for messages sent over the websocket.
+	RTM slack.RTMRawMessage
+	At  time.Time
+}
+
+// Server is a fake Slack server. Use NewServer to construct one.
+type Server struct {
+	TeamID     slack.TeamID
+	TeamDomain string
+	BotUserID  slack.UserID
+	BotName    string
+
+	http *httptest.Server
+
+	lock      sync.Mutex
+	callCond  *sync.Cond
+	calls     []Call
+	tsCounter int64
+	users     map[slack.UserID]*slack.User
+	channels  map[slack.ChannelID]*slack.Channel
+	ims       map[slack.UserID]slack.ChannelID
+	messages  map[slack.MessageID]slack.RTMRawMessage
+	reactions map[slack.MessageID]map[string][]slack.UserID
+	pins      map[slack.ChannelID][]slack.PinnedItem
+	conns     []*websocket.Conn
+	connCond  *sync.Cond
+}
+
+// NewServer starts a fake Slack server listening on a local port. The server
+// knows about the bot user and a #general channel.
+func NewServer() *Server {
+	s := &Server{
+		TeamID:     DefaultTeamID,
+		TeamDomain: "slacktest",
+		BotUserID:  DefaultBotUserID,
+		BotName:    "marvin",
+
+		tsCounter: time.Now().Unix() * 1000000,
+		users:     make(map[slack.UserID]*slack.User),
+		channels:  make(map[slack.ChannelID]*slack.Channel),
+		ims:       make(map[slack.UserID]slack.ChannelID),
+		messages:  make(map[slack.MessageID]slack.RTMRawMessage),
+		reactions: make(map[slack.MessageID]map[string][]slack.UserID),
+		pins:      make(map[slack.ChannelID][]slack.PinnedItem),
+	}
+	s.callCond = sync.NewCond(&s.lock)
+	s.connCond = sync.NewCond(&s.lock)
+
+	s.AddUser(&slack.User{ID: s.BotUserID, Name: s.BotName, IsBot: true})
+	s.AddChannel(&slack.Channel{ID: DefaultChannelID, Name: "general", IsChannel: true, IsGeneral: true})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", s.serveAPI)
+	mux.Handle("/rtm", websocket.Handler(s.serveRTM))
+	s.http = httptest.NewServer(mux)
+	return s
+}
+
+// Close shuts down the server and disconnects all websocket clients.
+func (s *Server) Close() {
+	s.lock.Lock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+	s.lock.Unlock()
+	s.http.Close()
+}
+
+// APIURL returns the value to use for the SlackAPIURL config key.
+func (s *Server) APIURL() string {
+	return s.http.URL + "/api/"
+}
+
+// rtmURL returns the websocket URL handed out by rtm.connect.
+func (s *Server) rtmURL() string {
+	return "ws" + strings.TrimPrefix(s.http.URL, "http") + "/rtm"
+}
+
+// ---
+// Fixtures
+
+// AddUser adds or replaces a user.
+func (s *Server) AddUser(u *slack.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if u.TeamID == "" {
+		u.TeamID = s.TeamID
+	}
+	if u.Profile.DisplayName == "" {
+		u.Profile.DisplayName = u.Name
+	}
+	s.users[u.ID] = u
+}
+
+// AddChannel adds or replaces a channel. Channel IDs starting with G are
+// treated as private channels.
+func (s *Server) AddChannel(ch *slack.Channel) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.channels[ch.ID] = ch
+}
+
+// ---
+// Recording
+
+func (s *Server) record(c Call) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c.At = time.Now()
+	s.calls = append(s.calls, c)
+	s.callCond.Broadcast()
+}
+
+// Calls returns all recorded calls to the given method. Outgoing RTM messages
+// are recorded with the method "rtm.<type>", e.g. "rtm.message".
+func (s *Server) Calls(method string) []Call {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var result []Call
+	for _, v := range s.calls {
+		if v.Method == method {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// AllCalls returns every recorded call in order.
+func (s *Server) AllCalls() []Call {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]Call, len(s.calls))
+	copy(result, s.calls)
+	return result
+}
+
+// ResetCalls forgets all recorded calls.
+func (s *Server) ResetCalls() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls = nil
+}
+
+// WaitForCall waits until a call to the given method has been recorded after
+// any calls already returned by Calls(), and returns it.
+func (s *Server) WaitForCall(method string, timeout time.Duration) (Call, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	seen := 0
+	for _, v := range s.calls {
+		if v.Method == method {
+			seen++
+		}
+	}
+	return s.waitLocked(method, seen, timeout)
+}
+
+// WaitForCalls waits until at least n calls to the method have been recorded
+// in total, and returns the nth.
+func (s *Server) WaitForCalls(method string, n int, timeout time.Duration) (Call, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.waitLocked(method, n-1, timeout)
+}
+
+func (s *Server) waitLocked(method string, skip int, timeout time.Duration) (Call, bool) {
+	timer := time.AfterFunc(timeout, func() {
+		s.lock.Lock()
+		s.callCond.Broadcast()
+		s.lock.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		count := 0
+		for _, v := range s.calls {
+			if v.Method == method {
+				if count == skip {
+					return v, true
+				}
+				count++
+			}
+		}
+		if time.Now().After(deadline) {
+			return Call{}, false
+		}
+		s.callCond.Wait()
+	}
+}
+
+// Message returns the current state of a message posted to the server.
+func (s *Server) Message(msgID slack.MessageID) slack.RTMRawMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.messages[msgID]
+}
+
+// Reactions returns the users who reacted with each emoji on a message.
+func (s *Server) Reactions(msgID slack.MessageID) map[string][]slack.UserID {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make(map[string][]slack.UserID)
+	for k, v := range s.reactions[msgID] {
+		result[k] = append([]slack.UserID(nil), v...)
+	}
+	return result
+}
+
+// Pins returns the pinned items in a channel.
+func (s *Server) Pins(channel slack.ChannelID) []slack.PinnedItem {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]slack.PinnedItem(nil), s.pins[channel]...)
+}
+
+// ---
+// Injecting events
+
+// nextTS returns a new unique message timestamp. Must hold s.lock.
+func (s *Server) nextTS() slack.MessageTS {
+	s.tsCounter++
+	return slack.MessageTS(fmt.Sprintf("%d.%06d", s.tsCounter/1000000, s.tsCounter%1000000))
+}
+
+// InjectMessage simulates a user sending a message and returns its
+// timestamp.
+func (s *Server) InjectMessage(channel slack.ChannelID, user slack.UserID, text string) slack.MessageTS {
+	return s.InjectThreadMessage(channel, user, text, "")
+}
+
+// InjectThreadMessage simulates a user replying in a thread. If threadTS is
+// empty, the message is posted to the channel.
+func (s *Server) InjectThreadMessage(channel slack.ChannelID, user slack.UserID, text string, threadTS slack.MessageTS) slack.MessageTS {
+	s.lock.Lock()
+	ts := s.nextTS()
+	msg := slack.RTMRawMessage{
+		"type":    "message",
+		"channel": string(channel),
+		"user":    string(user),
+		"text":    text,
+		"ts":      string(ts),
+		"team":    string(s.TeamID),
+	}
+	if threadTS != "" {
+		msg["thread_ts"] = string(threadTS)
+	}
+	s.messages[slack.MsgID(channel, ts)] = msg
+	s.lock.Unlock()
+
+	s.InjectEvent(msg)
+	return ts
+}
+
+// InjectEdit simulates a user editing a message previously sent with
+// InjectMessage.
+func (s *Server) InjectEdit(channel slack.ChannelID, ts slack.MessageTS, user slack.UserID, newText string) {
+	s.lock.Lock()
+	prev := s.messages[slack.MsgID(channel, ts)]
+	edited := slack.RTMRawMessage{
+		"type":   "message",
+		"user":   string(user),
+		"text":   newText,
+		"ts":     string(ts),
+		"edited": map[string]interface{}{"user": string(user), "ts": string(s.nextTS())},
+	}
+	if prev != nil {
+		if threadTS, ok := prev["thread_ts"]; ok {
+			edited["thread_ts"] = threadTS
+		}
+		prev["text"] = newText
+	}
+	eventTS := s.nextTS()
+	s.lock.Unlock()
+
+	s.InjectEvent(slack.RTMRawMessage{
+		"type":             "message",
+		"subtype":          "message_changed",
+		"hidden":           true,
+		"channel":          string(channel),
+		"ts":               string(eventTS),
+		"message":          edited,
+		"previous_message": prev,
+	})
+}
+
+// InjectReaction simulates a user adding a reaction to a message.
+func (s *Server) InjectReaction(msgID slack.MessageID, user slack.UserID, emoji string) {
+	s.lock.Lock()
+	s.addReactionLocked(msgID, user, emoji)
+	itemUser, _ := s.messages[msgID]["user"].(string)
+	eventTS := s.nextTS()
+	s.lock.Unlock()
+
+	s.InjectEvent(slack.RTMRawMessage{
+		"type":      "reaction_added",
+		"user":      string(user),
+		"reaction":  emoji,
+		"item_user": itemUser,
+		"item": map[string]interface{}{
+			"type":    "message",
+			"channel": string(msgID.ChannelID),
+			"ts":      string(msgID.MessageTS),
+		},
+		"event_ts": string(eventTS),
+	})
+}
+
+// InjectEvent sends an arbitrary event to every connected RTM client.
+func (s *Server) InjectEvent(ev slack.RTMRawMessage) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		panic(err)
+	}
+	s.lock.Lock()
+	conns := append([]*websocket.Conn(nil), s.conns...)
+	s.lock.Unlock()
+
+	for _, c := range conns {
+		websocket.Message.Send(c, string(b))
+	}
+}
+
+// WaitForConnection blocks until at least one RTM client has completed the
+// hello handshake.
+func (s *Server) WaitForConnection(timeout time.Duration) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	timer := time.AfterFunc(timeout, func() {
+		s.lock.Lock()
+		s.connCond.Broadcast()
+		s.lock.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	for len(s.conns) == 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		s.connCond.Wait()
+	}
+	return true
+}
+
+// ---
+// RTM websocket
+
+func (s *Server) serveRTM(conn *websocket.Conn) {
+	err := websocket.Message.Send(conn, `{"type":"hello"}`)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.conns = append(s.conns, conn)
+	s.connCond.Broadcast()
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		for i, v := range s.conns {
+			if v == conn {
+				s.conns = append(s.conns[:i], s.conns[i+1:]...)
+				break
+			}
+		}
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var msg slack.RTMRawMessage
+		err := websocket.JSON.Receive(conn, &msg)
+		if err != nil {
+			return
+		}
+		s.record(Call{Method: "rtm." + msg.Type(), RTM: msg})
+
+		reply := slack.RTMRawMessage{"ok": true, "reply_to": msg["id"]}
+		switch msg.Type() {
+		case "ping":
+			reply["type"] = "pong"
+			delete(reply, "ok")
+		case "message":
+			s.lock.Lock()
+			ts := s.nextTS()
+			stored := slack.RTMRawMessage{
+				"type":    "message",
+				"channel": msg.StringField("channel"),
+				"user":    string(s.BotUserID),
+				"text":    msg.StringField("text"),
+				"ts":      string(ts),
+			}
+			s.messages[slack.MsgID(msg.ChannelID(), ts)] = stored
+			s.lock.Unlock()
+			reply["ts"] = string(ts)
+			reply["text"] = msg.StringField("text")
+		}
+		err = websocket.JSON.Send(conn, reply)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ---
+// Web API
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.record(Call{Method: method, Form: r.Form})
+
+	var resp interface{}
+	handler, ok := apiMethods[method]
+	if !ok {
+		resp = apiError("unknown_method")
+	} else {
+		resp = handler(s, r.Form)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type apiResult map[string]interface{}
+
+func apiOK(fields apiResult) apiResult {
+	if fields == nil {
+		fields = apiResult{}
+	}
+	fields["ok"] = true
+	return fields
+}
+
+func apiError(code string) apiResult {
+	return apiResult{"ok": false, "error": code}
+}
+
+var apiMethods map[string]func(s *Server, form url.Values) interface{}
+
+func init() {
+	apiMethods = map[string]func(s *Server, form url.Values) interface{}{
+		"api.test":  (*Server).apiTest,
+		"auth.test": (*Server).authTest,
+
+		"rtm.connect": (*Server).rtmConnect,
+
+		"chat.postMessage": (*Server).chatPostMessage,
+		"chat.update":      (*Server).chatUpdate,
+		"chat.delete":      (*Server).chatDelete,
+
+		"reactions.add":    (*Server).reactionsAdd,
+		"reactions.remove": (*Server).reactionsRemove,
+		"reactions.get":    (*Server).reactionsGet,
+
+		"pins.add":    (*Server).pinsAdd,
+		"pins.remove": (*Server).pinsRemove,
+		"pins.list":   (*Server).pinsList,
+
+		"users.info": (*Server).usersInfo,
+		"users.list": (*Server).usersList,
+
+		"conversations.info":    (*Server).conversationsInfo,
+		"conversations.list":    (*Server).conversationsList,
+		"conversations.members": (*Server).conversationsMembers,
+		"conversations.open":    (*Server).conversationsOpen,
+		"conversations.invite":  (*Server).conversationsInvite,
+
+		// Legacy methods still used by slack/controller
+		"channels.info": (*Server).channelsInfo,
+		"groups.info":   (*Server).groupsInfo,
+		"groups.list":   (*Server).groupsList,
+		"groups.invite": (*Server).conversationsInvite,
+		"im.open":       (*Server).conversationsOpen,
+	}
+}
+
+func (s *Server) apiTest(form url.Values) interface{} {
+	return apiOK(apiResult{"args": form})
+}
+
+func (s *Server) authTest(form url.Values) interface{} {
+	return apiOK(apiResult{
+		"url":     fmt.Sprintf("https://%s.slack.com/", s.TeamDomain),
+		"team":    s.TeamDomain,
+		"user":    s.BotName,
+		"team_id": s.TeamID,
+		"user_id": s.BotUserID,
+	})
+}
+
+func (s *Server) rtmConnect(form url.Values) interface{} {
+	return apiOK(apiResult{
+		"url": s.rtmURL(),
+		"team": apiResult{
+			"id":     s.TeamID,
+			"name":   s.TeamDomain,
+			"domain": s.TeamDomain,
+		},
+		"self": apiResult{
+			"id":   s.BotUserID,
+			"name": s.BotName,
+		},
+	})
+}
+
+func (s *Server) chatPostMessage(form url.Values) interface{} {
+	channel := slack.ChannelID(form.Get("channel"))
+	if channel == "" {
+		return apiError("channel_not_found")
+	}
+	if form.Get("text") == "" && form.Get("attachments") == "" && form.Get("blocks") == "" {
+		return apiError("no_text")
+	}
+
+	s.lock.Lock()
+	ts := s.nextTS()
+	msg := slack.RTMRawMessage{
+		"type":    "message",
+		"channel": string(channel),
+		"user":    string(s.BotUserID),
+		"text":    form.Get("text"),
+		"ts":      string(ts),
+	}
+	if threadTS := form.Get("thread_ts"); threadTS != "" {
+		msg["thread_ts"] = threadTS
+	}
+	s.messages[slack.MsgID(channel, ts)] = msg
+	s.lock.Unlock()
+
+	s.InjectEvent(msg)
+	return apiOK(apiResult{"channel": channel, "ts": ts, "message": msg})
+}
+
+func (s *Server) chatUpdate(form url.Values) interface{} {
+	msgID := slack.MsgID(slack.ChannelID(form.Get("channel")), slack.MessageTS(form.Get("ts")))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	msg, ok := s.messages[msgID]
+	if !ok {
+		return apiError("message_not_found")
+	}
+	msg["text"] = form.Get("text")
+	return apiOK(apiResult{"channel": msgID.ChannelID, "ts": msgID.MessageTS, "text": form.Get("text")})
+}
+
+func (s *Server) chatDelete(form url.Values) interface{} {
+	msgID := slack.MsgID(slack.ChannelID(form.Get("channel")), slack.MessageTS(form.Get("ts")))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.messages[msgID]; !ok {
+		return apiError("message_not_found")
+	}
+	delete(s.messages, msgID)
+	return apiOK(apiResult{"channel": msgID.ChannelID, "ts": msgID.MessageTS})
+}
+
+// Must hold s.lock.
+func (s *Server) addReactionLocked(msgID slack.MessageID, user slack.UserID, emoji string) bool {
+	m := s.reactions[msgID]
+	if m == nil {
+		m = make(map[string][]slack.UserID)
+		s.reactions[msgID] = m
+	}
+	for _, v := range m[emoji] {
+		if v == user {
+			return false
+		}
+	}
+	m[emoji] = append(m[emoji], user)
+	return true
+}
+
+func reactionTarget(form url.Values) slack.MessageID {
+	return slack.MsgID(slack.ChannelID(form.Get("channel")), slack.MessageTS(form.Get("timestamp")))
+}
+
+func (s *Server) reactionsAdd(form url.Values) interface{} {
+	msgID := reactionTarget(form)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.messages[msgID]; !ok {
+		return apiError("message_not_found")
+	}
+	if !s.addReactionLocked(msgID, s.BotUserID, form.Get("name")) {
+		return apiError("already_reacted")
+	}
+	return apiOK(nil)
+}
+
+func (s *Server) reactionsRemove(form url.Values) interface{} {
+	msgID := reactionTarget(form)
+	emoji := form.Get("name")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	users := s.reactions[msgID][emoji]
+	for i, v := range users {
+		if v == s.BotUserID {
+			s.reactions[msgID][emoji] = append(users[:i], users[i+1:]...)
+			return apiOK(nil)
+		}
+	}
+	return apiError("no_reaction")
+}
+
+func (s *Server) reactionsGet(form url.Values) interface{} {
+	msgID := reactionTarget(form)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var reactions []apiResult
+	for k, v := range s.reactions[msgID] {
+		if len(v) == 0 {
+			continue
+		}
+		reactions = append(reactions, apiResult{"name": k, "count": len(v), "users": v})
+	}
+	return apiOK(apiResult{
+		"channel": msgID.ChannelID,
+		"message": apiResult{"ts": msgID.MessageTS, "reactions": reactions},
+	})
+}
+
+func (s *Server) pinsAdd(form url.Values) interface{} {
+	channel := slack.ChannelID(form.Get("channel"))
+	item := slack.PinnedItem{Channel: channel, CreatedBy: s.BotUserID, Created: time.Now().Unix()}
+	if ts := form.Get("timestamp"); ts != "" {
+		item.Type = "message"
+		item.Message.TS = slack.MessageTS(ts)
+	} else if file := form.Get("file"); file != "" {
+		item.Type = "file"
+		item.File.ID = slack.FileID(file)
+	} else if fc := form.Get("file_comment"); fc != "" {
+		item.Type = "file_comment"
+		item.Comment.ID = slack.FileCommentID(fc)
+	} else {
+		return apiError("no_item_specified")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, v := range s.pins[channel] {
+		if pinKey(v) == pinKey(item) {
+			return apiError("already_pinned")
+		}
+	}
+	// Most recent first, like pins.list
+	s.pins[channel] = append([]slack.PinnedItem{item}, s.pins[channel]...)
+	return apiOK(nil)
+}
+
+func pinKey(p slack.PinnedItem) string {
+	return string(p.Message.TS) + string(p.File.ID) + string(p.Comment.ID)
+}
+
+func (s *Server) pinsRemove(form url.Values) interface{} {
+	channel := slack.ChannelID(form.Get("channel"))
+	key := form.Get("timestamp") + form.Get("file") + form.Get("file_comment")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, v := range s.pins[channel] {
+		if pinKey(v) == key {
+			s.pins[channel] = append(s.pins[channel][:i], s.pins[channel][i+1:]...)
+			return apiOK(nil)
+		}
+	}
+	return apiError("not_pinned")
+}
+
+func (s *Server) pinsList(form url.Values) interface{} {
+	channel := slack.ChannelID(form.Get("channel"))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	items := append([]slack.PinnedItem{}, s.pins[channel]...)
+	return apiOK(apiResult{"items": items})
+}
+
+// userJSON renders a user the way the Web API does; slack.User does not
+// marshal the name field.
+func userJSON(u *slack.User) apiResult {
+	b, _ := json.Marshal(u)
+	var result apiResult
+	json.Unmarshal(b, &result)
+	result["name"] = u.Name
+	return result
+}
+
+func (s *Server) usersInfo(form url.Values) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	u, ok := s.users[slack.UserID(form.Get("user"))]
+	if !ok {
+		return apiError("user_not_found")
+	}
+	return apiOK(apiResult{"user": userJSON(u)})
+}
+
+func (s *Server) usersList(form url.Values) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var members []apiResult
+	for _, u := range s.users {
+		members = append(members, userJSON(u))
+	}
+	return apiOK(apiResult{"members": members, "response_metadata": apiResult{"next_cursor": ""}})
+}
+
+func (s *Server) lookupChannel(form url.Values) (*slack.Channel, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ch, ok := s.channels[slack.ChannelID(form.Get("channel"))]
+	return ch, ok
+}
+
+func (s *Server) conversationsInfo(form url.Values) interface{} {
+	ch, ok := s.lookupChannel(form)
+	if !ok {
+		return apiError("channel_not_found")
+	}
+	return apiOK(apiResult{"channel": ch})
+}
+
+func (s *Server) conversationsList(form url.Values) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var channels []*slack.Channel
+	for _, v := range s.channels {
+		channels = append(channels, v)
+	}
+	return apiOK(apiResult{"channels": channels, "response_metadata": apiResult{"next_cursor": ""}})
+}
+
+func (s *Server) conversationsMembers(form url.Values) interface{} {
+	ch, ok := s.lookupChannel(form)
+	if !ok {
+		return apiError("channel_not_found")
+	}
+	return apiOK(apiResult{"members": ch.Members, "response_metadata": apiResult{"next_cursor": ""}})
+}
+
+func (s *Server) conversationsOpen(form url.Values) interface{} {
+	user := slack.UserID(form.Get("user"))
+	if user == "" {
+		user = slack.UserID(form.Get("users"))
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.users[user]; !ok {
+		return apiError("user_not_found")
+	}
+	im, ok := s.ims[user]
+	if !ok {
+		im = slack.ChannelID("D" + strings.TrimPrefix(string(user), "U"))
+		s.ims[user] = im
+	}
+	return apiOK(apiResult{"channel": apiResult{"id": im}})
+}
+
+func (s *Server) conversationsInvite(form url.Values) interface{} {
+	users := strings.Split(form.Get("users")+form.Get("user"), ",")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ch, ok := s.channels[slack.ChannelID(form.Get("channel"))]
+	if !ok {
+		return apiError("channel_not_found")
+	}
+	already := false
+	for _, u := range users {
+		found := false
+		for _, m := range ch.Members {
+			if m == slack.UserID(u) {
+				found = true
+			}
+		}
+		if found {
+			already = true
+		} else {
+			ch.Members = append(ch.Members, slack.UserID(u))
+		}
+	}
+	return apiOK(apiResult{"channel": ch, "already_in_group": already})
+}
+
+func (s *Server) channelsInfo(form url.Values) interface{} {
+	ch, ok := s.lookupChannel(form)
+	if !ok || ch.ID[0] != 'C' {
+		return apiError("channel_not_found")
+	}
+	return apiOK(apiResult{"channel": ch})
+}
+
+func (s *Server) groupsInfo(form url.Values) interface{} {
+	ch, ok := s.lookupChannel(form)
+	if !ok || ch.ID[0] != 'G' {
+		return apiError("channel_not_found")
+	}
+	return apiOK(apiResult{"group": ch})
+}
+
+func (s *Server) groupsList(form url.Values) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	groups := []*slack.Channel{}
+	for _, v := range s.channels {
+		if v.ID[0] == 'G' {
+			groups = append(groups, v)
+		}
+	}
+	return apiOK(apiResult{"groups": groups})
+}
@@ -0,0 +1,81 @@
+package slacktest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/riking/marvin/slack"
+)
+
+func apiCall(t *testing.T, s *Server, method string, form url.Values) slack.RTMRawMessage {
+	resp, err := http.PostForm(s.APIURL()+method, form)
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	var result slack.RTMRawMessage
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatalf("%s: decode: %v", method, err)
+	}
+	return result
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp := apiCall(t, s, "rtm.connect", url.Values{})
+	if !resp.Okay() {
+		t.Fatalf("rtm.connect failed: %v", resp)
+	}
+	ws, err := websocket.Dial(resp.StringField("url"), "", "http://localhost/")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	var msg slack.RTMRawMessage
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	if err = websocket.JSON.Receive(ws, &msg); err != nil || msg.Type() != "hello" {
+		t.Fatalf("Expected hello, got %v %v", msg, err)
+	}
+	if !s.WaitForConnection(5 * time.Second) {
+		t.Fatal("Server did not register the connection")
+	}
+
+	ts := s.InjectMessage(DefaultChannelID, "U12345678", "hello marvin")
+	msg = nil
+	if err = websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if msg.Text() != "hello marvin" || msg.MessageTS() != ts {
+		t.Errorf("Wrong injected message: %v", msg)
+	}
+
+	resp = apiCall(t, s, "reactions.add", url.Values{
+		"channel":   {string(DefaultChannelID)},
+		"timestamp": {string(ts)},
+		"name":      {"white_check_mark"},
+	})
+	if !resp.Okay() {
+		t.Errorf("reactions.add failed: %v", resp)
+	}
+	call, ok := s.WaitForCalls("reactions.add", 1, time.Second)
+	if !ok || call.Form.Get("name") != "white_check_mark" {
+		t.Errorf("reactions.add was not recorded: %v", call)
+	}
+	if users := s.Reactions(slack.MsgID(DefaultChannelID, ts))["white_check_mark"]; len(users) != 1 || users[0] != s.BotUserID {
+		t.Errorf("Wrong reaction state: %v", users)
+	}
+
+	resp = apiCall(t, s, "no.such.method", url.Values{})
+	if resp.Okay() || resp.StringField("error") != "unknown_method" {
+		t.Errorf("Expected unknown_method, got %v", resp)
+	}
+}
@@ -0,0 +1,67 @@
+package slacktest_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riking/marvin"
+	"github.com/riking/marvin/slack"
+	"github.com/riking/marvin/slack/controller"
+	"github.com/riking/marvin/slack/rtm"
+	"github.com/riking/marvin/slack/slacktest"
+
+	_ "github.com/riking/marvin/modules/atcommand"
+	_ "github.com/riking/marvin/modules/debug"
+)
+
+const testUser slack.UserID = "U0000USR0"
+
+// TestTeamCommand boots a Team against the fake server and runs a command
+// through atcommand. It needs a scratch Postgres database, given in the
+// MARVIN_TEST_DATABASE environment variable.
+func TestTeamCommand(t *testing.T) {
+	dbURL := os.Getenv("MARVIN_TEST_DATABASE")
+	if dbURL == "" {
+		t.Skip("MARVIN_TEST_DATABASE is not set")
+	}
+
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	srv.AddUser(&slack.User{ID: testUser, Name: "tester"})
+
+	team, err := controller.NewTeam(&marvin.TeamConfig{
+		TeamDomain:     srv.TeamDomain,
+		SlackAPIURL:    srv.APIURL(),
+		DatabaseURL:    dbURL,
+		UserToken:      "xoxb-test",
+		EventTransport: marvin.EventTransportRTM,
+	})
+	if err != nil {
+		t.Fatalf("NewTeam: %v", err)
+	}
+	defer team.Shutdown()
+	team.ConnectRTM(rtm.NewClient(team))
+	if !team.EnableModules() {
+		t.Fatal("Some modules failed to load")
+	}
+	if err = team.StartEvents(); err != nil {
+		t.Fatalf("start events: %v", err)
+	}
+	if !srv.WaitForConnection(5 * time.Second) {
+		t.Fatal("Team did not connect to the RTM websocket")
+	}
+
+	srv.InjectMessage(slacktest.DefaultChannelID, testUser, "<@"+string(srv.BotUserID)+"> whoami")
+	call, ok := srv.WaitForCall("chat.postMessage", 5*time.Second)
+	if !ok {
+		t.Fatal("No reply to the command")
+	}
+	if call.Form.Get("channel") != string(slacktest.DefaultChannelID) {
+		t.Errorf("Reply sent to the wrong channel: %v", call.Form)
+	}
+	if text := call.Form.Get("text"); !strings.Contains(text, "your user ID is "+string(testUser)) {
+		t.Errorf("Wrong reply: %q", text)
+	}
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+// DefaultAPIURL is the base URL for Slack Web API methods. It can be
+// overridden with the SlackAPIURL config key, e.g. to run against a
+// slacktest.Server.
+const DefaultAPIURL = "https://slack.com/api/"
+
 var (
 	mentionRegexp     = regexp.MustCompile(`<@([UW][A-Z0-9]+)>`)
 	channelMentionRgx = regexp.MustCompile(`<#(C[A-Z0-9]+)\|?([a-z0-9_-]+)?>`)
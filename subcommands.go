@@ -1,9 +1,15 @@
 package marvin
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/riking/marvin/util"
 )
 
 type subCommandWithHelp struct {
@@ -23,23 +29,169 @@ type ParentCommand struct {
 	extraHelp string
 	lock      sync.Mutex
 	nameMap   map[string]SubCommand
+	permMap   map[string]Permission
+	aliasMap  map[string][]string
 }
 
 func NewParentCommand() *ParentCommand {
 	return &ParentCommand{
-		nameMap: make(map[string]SubCommand),
+		nameMap:  make(map[string]SubCommand),
+		permMap:  make(map[string]Permission),
+		aliasMap: make(map[string][]string),
 	}
 }
 
+// maxAliasDepth limits how many aliases one command can expand through.
+const maxAliasDepth = 8
+
+// RegisterAlias makes name run the expansion, relative to this command.
+//
+// In the expansion, `$1`, `$2`... are replaced with the arguments given to the
+// alias, and `$@` with all of them. If the expansion has no `$` references,
+// the arguments are appended to it.
+//
+// An error is returned if the name is taken by a command or alias, or the
+// alias would expand into itself.
+func (pc *ParentCommand) RegisterAlias(name string, expansion string) error {
+	split := strings.Fields(expansion)
+	if len(split) == 0 {
+		return errors.Errorf("alias expansion is empty")
+	}
+
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	if _, ok := pc.nameMap[name]; ok {
+		return errors.Errorf("`%s` is already a command", name)
+	}
+	if _, ok := pc.aliasMap[name]; ok {
+		return errors.Errorf("`%s` is already an alias", name)
+	}
+	// Follow the chain of aliases to check for cycles
+	next := split[0]
+	for i := 0; i < maxAliasDepth; i++ {
+		if next == name {
+			return errors.Errorf("alias `%s` would expand into itself", name)
+		}
+		target, ok := pc.aliasMap[next]
+		if !ok {
+			break
+		}
+		next = target[0]
+	}
+	pc.aliasMap[name] = split
+	return nil
+}
+
+// UnregisterAlias removes an alias made with RegisterAlias.
+func (pc *ParentCommand) UnregisterAlias(name string) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	delete(pc.aliasMap, name)
+}
+
+// ListAliases returns the aliases and their expansions.
+func (pc *ParentCommand) ListAliases() map[string]string {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	result := make(map[string]string, len(pc.aliasMap))
+	for k, v := range pc.aliasMap {
+		result[k] = strings.Join(v, " ")
+	}
+	return result
+}
+
+// HasCommand reports whether the name is a command or alias.
+func (pc *ParentCommand) HasCommand(name string) bool {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	_, isCmd := pc.nameMap[name]
+	_, isAlias := pc.aliasMap[name]
+	return isCmd || isAlias
+}
+
+func (pc *ParentCommand) getAlias(name string) ([]string, bool) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	template, ok := pc.aliasMap[name]
+	return template, ok
+}
+
+// expandAlias substitutes the arguments into an alias expansion.
+func expandAlias(template []string, arguments []string) ([]string, error) {
+	var result []string
+	usedArgs := false
+	for _, v := range template {
+		if v == "$@" {
+			result = append(result, arguments...)
+			usedArgs = true
+		} else if len(v) > 1 && v[0] == '$' {
+			n, err := strconv.Atoi(v[1:])
+			if err != nil || n < 1 {
+				result = append(result, v)
+				continue
+			}
+			if n > len(arguments) {
+				return nil, errors.Errorf("needs at least %d arguments", n)
+			}
+			result = append(result, arguments[n-1])
+			usedArgs = true
+		} else {
+			result = append(result, v)
+		}
+	}
+	if !usedArgs {
+		result = append(result, arguments...)
+	}
+	return result, nil
+}
+
+// handleAlias rewrites the arguments with the expansion of the alias that was
+// just popped, then runs the result.
+func (pc *ParentCommand) handleAlias(t Team, args *CommandArguments, template []string) CommandResult {
+	if args.aliasDepth >= maxAliasDepth {
+		return CmdFailuref(args, "Too many nested aliases in `%s`", args.Command)
+	}
+	expanded, err := expandAlias(template, args.Arguments)
+	if err != nil {
+		return CmdUsage(args, fmt.Sprintf("`%s` is an alias for `%s`, which %v.", args.Command, strings.Join(template, " "), err))
+	}
+	preArgs := args.PreArgs()
+	newArgs := make([]string, 0, len(preArgs)-1+len(expanded))
+	newArgs = append(newArgs, preArgs[:len(preArgs)-1]...)
+	newArgs = append(newArgs, expanded...)
+
+	args.OriginalArguments = newArgs
+	args.Arguments = newArgs[len(preArgs)-1:]
+	args.aliasDepth++
+	return pc.Handle(t, args)
+}
+
 func (pc *ParentCommand) WithHelp(extraHelp string) *ParentCommand {
 	pc.extraHelp = extraHelp
 	return pc
 }
 
+// dropAlias removes an alias that a new command is taking the name of.
+// Must be called with the lock held.
+func (pc *ParentCommand) dropAlias(name string) {
+	if template, ok := pc.aliasMap[name]; ok {
+		util.LogWarnf("Command `%s` replaces the alias for `%s`", name, strings.Join(template, " "))
+		delete(pc.aliasMap, name)
+	}
+}
+
+// RegisterCommand registers a command. An alias with the same name is
+// removed.
 func (pc *ParentCommand) RegisterCommand(name string, c SubCommand) {
 	pc.lock.Lock()
 	defer pc.lock.Unlock()
 
+	pc.dropAlias(name)
 	pc.nameMap[name] = c
 }
 
@@ -48,15 +200,95 @@ func (pc *ParentCommand) RegisterCommandFunc(name string, f SubCommandFunc, help
 	defer pc.lock.Unlock()
 
 	sc := subCommandWithHelp{f: f, help: help}
+	pc.dropAlias(name)
 	pc.nameMap[name] = sc
 	return sc
 }
 
+// RegisterCommandPerm registers a command that requires the permission.
+func (pc *ParentCommand) RegisterCommandPerm(name string, c SubCommand, perm Permission) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	pc.dropAlias(name)
+	pc.nameMap[name] = c
+	pc.permMap[name] = perm
+}
+
+// RegisterCommandFuncPerm registers a command that requires the permission.
+func (pc *ParentCommand) RegisterCommandFuncPerm(name string, f SubCommandFunc, help string, perm Permission) SubCommand {
+	sc := subCommandWithHelp{f: f, help: help}
+	pc.RegisterCommandPerm(name, sc, perm)
+	return sc
+}
+
 func (pc *ParentCommand) UnregisterCommand(name string) {
 	pc.lock.Lock()
 	defer pc.lock.Unlock()
 
 	delete(pc.nameMap, name)
+	delete(pc.permMap, name)
+	delete(pc.aliasMap, name)
+}
+
+// RequiredPermissions returns the permissions needed to run the command with
+// the given arguments, following nested ParentCommands.
+func (pc *ParentCommand) RequiredPermissions(arguments []string) []Permission {
+	return pc.requiredPermissions(arguments, 0)
+}
+
+func (pc *ParentCommand) requiredPermissions(arguments []string, aliasDepth int) []Permission {
+	if len(arguments) == 0 || arguments[0] == "help" {
+		return nil
+	}
+
+	// Same order as Handle: a command wins over an alias of the same name
+	pc.lock.Lock()
+	subC, ok := pc.nameMap[arguments[0]]
+	perm, hasPerm := pc.permMap[arguments[0]]
+	pc.lock.Unlock()
+
+	if !ok {
+		template, isAlias := pc.getAlias(arguments[0])
+		if !isAlias {
+			return nil
+		}
+		expanded, err := expandAlias(template, arguments[1:])
+		if err != nil || aliasDepth >= maxAliasDepth {
+			// The alias will fail without running anything
+			return nil
+		}
+		return pc.requiredPermissions(expanded, aliasDepth+1)
+	}
+	var result []Permission
+	if hasPerm {
+		result = append(result, perm)
+	}
+	if subPC, ok := subC.(*ParentCommand); ok {
+		result = append(result, subPC.requiredPermissions(arguments[1:], aliasDepth)...)
+	}
+	return result
+}
+
+// ListPermissions returns every permission declared in the command tree.
+func (pc *ParentCommand) ListPermissions() []Permission {
+	pc.lock.Lock()
+	var result []Permission
+	var children []*ParentCommand
+	for _, v := range pc.permMap {
+		result = append(result, v)
+	}
+	for _, v := range pc.nameMap {
+		if subPC, ok := v.(*ParentCommand); ok {
+			children = append(children, subPC)
+		}
+	}
+	pc.lock.Unlock()
+
+	for _, v := range children {
+		result = append(result, v.ListPermissions()...)
+	}
+	return result
 }
 
 func (pc *ParentCommand) Help(t Team, args *CommandArguments) CommandResult {
@@ -69,30 +301,69 @@ func (pc *ParentCommand) Help(t Team, args *CommandArguments) CommandResult {
 	subC, ok := pc.nameMap[args.Command]
 	pc.lock.Unlock()
 
+	if template, isAlias := pc.getAlias(args.Command); !ok && isAlias {
+		return CmdHelpf(args, "`%s` is an alias for `%s`.", args.Command, strings.Join(template, " "))
+	}
 	if !ok {
 		cmdErr := CmdFailuref(args, "help: No such command `%s`", strings.Join(args.PreArgs(), " "))
 		cmdErr.Code = CmdResultNoSuchCommand
-		return cmdErr
+		return cmdErr.WithSuggestions(pc.suggest(args))
 	}
 	return subC.Help(t, args)
 }
 
 func (pc *ParentCommand) helpListCommands(t Team, args *CommandArguments) CommandResult {
 	var subNames []string
+	var aliasNames []string
 
+	pc.lock.Lock()
 	for k := range pc.nameMap {
 		subNames = append(subNames, k)
 	}
+	for k := range pc.aliasMap {
+		aliasNames = append(aliasNames, k)
+	}
+	pc.lock.Unlock()
 	sort.Strings(subNames)
+	sort.Strings(aliasNames)
+
+	aliasHelp := ""
+	if len(aliasNames) > 0 {
+		aliasHelp = fmt.Sprintf("\nAliases: `%s`", strings.Join(aliasNames, "` `"))
+	}
 
 	preArgs := args.PreArgs()
 	if len(preArgs) > 1 {
 		if pc.extraHelp != "" {
-			return CmdHelpf(args, "%s\nSubcommands: `%s`", pc.extraHelp, strings.Join(subNames, "` `"))
+			return CmdHelpf(args, "%s\nSubcommands: `%s`%s", pc.extraHelp, strings.Join(subNames, "` `"), aliasHelp)
 		}
-		return CmdHelpf(args, "Subcommands of `%s`:\n`%s`", strings.Join(preArgs[1:], " "), strings.Join(subNames, "` `"))
+		return CmdHelpf(args, "Subcommands of `%s`:\n`%s`%s", strings.Join(preArgs[1:], " "), strings.Join(subNames, "` `"), aliasHelp)
 	}
-	return CmdHelpf(args, "Available commands:\n`%s`", strings.Join(subNames, "` `"))
+	return CmdHelpf(args, "Available commands:\n`%s`%s", strings.Join(subNames, "` `"), aliasHelp)
+}
+
+// suggest finds commands and aliases close to the unknown args.Command, and
+// returns them as full command lines.
+func (pc *ParentCommand) suggest(args *CommandArguments) []string {
+	var names []string
+	pc.lock.Lock()
+	for k := range pc.nameMap {
+		names = append(names, k)
+	}
+	for k := range pc.aliasMap {
+		names = append(names, k)
+	}
+	pc.lock.Unlock()
+
+	suggestions := SuggestNames(args.Command, names)
+	preArgs := args.PreArgs()
+	path := strings.Join(preArgs[:len(preArgs)-1], " ")
+	for i, v := range suggestions {
+		if path != "" {
+			suggestions[i] = path + " " + v
+		}
+	}
+	return suggestions
 }
 
 func (pc *ParentCommand) Handle(t Team, args *CommandArguments) CommandResult {
@@ -111,10 +382,13 @@ func (pc *ParentCommand) Handle(t Team, args *CommandArguments) CommandResult {
 			subC, ok := pc.nameMap[args.Command]
 			pc.lock.Unlock()
 
+			if template, isAlias := pc.getAlias(args.Command); !ok && isAlias {
+				return CmdHelpf(args, "`%s` is an alias for `%s`.", args.Command, strings.Join(template, " "))
+			}
 			if !ok {
 				cmdErr := CmdFailuref(args, "help: No such command '%s'", args.Command)
 				cmdErr.Code = CmdResultNoSuchCommand
-				return cmdErr
+				return cmdErr.WithSuggestions(pc.suggest(args))
 			}
 			return subC.Help(t, args)
 		}
@@ -124,10 +398,13 @@ func (pc *ParentCommand) Handle(t Team, args *CommandArguments) CommandResult {
 	subC, ok := pc.nameMap[args.Command]
 	pc.lock.Unlock()
 
+	if template, isAlias := pc.getAlias(args.Command); !ok && isAlias {
+		return pc.handleAlias(t, args, template)
+	}
 	if !ok {
 		cmdErr := CmdFailuref(args, "No such subcommand '%s'", args.Command)
 		cmdErr.Code = CmdResultNoSuchCommand
-		return cmdErr
+		return cmdErr.WithSuggestions(pc.suggest(args))
 	}
 
 	return subC.Handle(t, args)
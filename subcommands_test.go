@@ -0,0 +1,33 @@
+package marvin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAliasPermissions(t *testing.T) {
+	perm := Permission{Name: "test.deploy", Level: AccessLevelAdmin}
+	noop := func(t Team, args *CommandArguments) CommandResult { return CmdSuccess(args, "") }
+
+	pc := NewParentCommand()
+	pc.RegisterCommandFunc("echo", noop, "")
+	pc.RegisterCommandFuncPerm("deploy", noop, "", perm)
+	if err := pc.RegisterAlias("ship", "deploy now"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pc.RequiredPermissions([]string{"ship"}); !reflect.DeepEqual(got, []Permission{perm}) {
+		t.Errorf("alias permissions: got %v", got)
+	}
+
+	// A command registered later over an alias replaces it
+	if err := pc.RegisterAlias("release", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	pc.RegisterCommandFuncPerm("release", noop, "", perm)
+	if got := pc.RequiredPermissions([]string{"release"}); !reflect.DeepEqual(got, []Permission{perm}) {
+		t.Errorf("command shadowed by alias: got %v", got)
+	}
+	if _, ok := pc.ListAliases()["release"]; ok {
+		t.Errorf("alias not dropped when a command took its name")
+	}
+}
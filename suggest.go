@@ -0,0 +1,114 @@
+package marvin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxSuggestions is the most names SuggestNames will return.
+const MaxSuggestions = 3
+
+// SuggestNames returns the candidates that the input may have been a typo of,
+// closest first. A candidate matches if the input is a prefix of it or if it
+// is within a small edit distance, counting swapped letters as one edit.
+func SuggestNames(input string, candidates []string) []string {
+	type match struct {
+		name string
+		dist int
+	}
+	input = strings.ToLower(input)
+	if len(input) < 2 {
+		return nil
+	}
+	limit := 1
+	if len(input) > 4 {
+		limit = 2
+	}
+
+	var matches []match
+	seen := make(map[string]bool)
+	for _, v := range candidates {
+		if v == input || seen[v] {
+			continue
+		}
+		dist := editDistance(input, strings.ToLower(v))
+		if dist > limit {
+			if strings.HasPrefix(strings.ToLower(v), input) {
+				dist = limit
+			} else {
+				continue
+			}
+		}
+		seen[v] = true
+		matches = append(matches, match{name: v, dist: dist})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+	if len(matches) > MaxSuggestions {
+		matches = matches[:MaxSuggestions]
+	}
+	result := make([]string, len(matches))
+	for i, v := range matches {
+		result[i] = v.name
+	}
+	return result
+}
+
+// editDistance is the optimal string alignment distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// FormatSuggestions renders a "Did you mean" line, or the empty string if
+// there are no suggestions. The prefix is put before each name.
+func FormatSuggestions(prefix string, suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	buf.WriteString("Did you mean ")
+	for i, v := range suggestions {
+		if i > 0 && i == len(suggestions)-1 {
+			buf.WriteString(" or ")
+		} else if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "`%s%s`", prefix, v)
+	}
+	buf.WriteString("?")
+	return buf.String()
+}
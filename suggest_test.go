@@ -0,0 +1,26 @@
+package marvin
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		dist int
+	}{
+		{"", "", 0},
+		{"", "help", 4},
+		{"help", "", 4},
+		{"factoid", "factoid", 0},
+		{"factid", "factoid", 1},   // insertion
+		{"factoidd", "factoid", 1}, // deletion
+		{"factoix", "factoid", 1},  // substitution
+		{"fcatoid", "factoid", 1},  // transposition
+		{"remind", "rss", 5},
+		{"héllo", "hello", 1},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.dist {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, got, c.dist)
+		}
+	}
+}
@@ -13,6 +13,15 @@ import (
 	"github.com/riking/marvin/slack"
 )
 
+// Values for the EventTransport configuration key.
+const (
+	// EventTransportRTM receives events over the RTM websocket.
+	EventTransportRTM = "rtm"
+	// EventTransportHTTP receives events from the Slack Events API at
+	// /slack/events. SigningSecret must be set.
+	EventTransportHTTP = "events"
+)
+
 // TeamConfig is loaded from the config.ini file.
 type TeamConfig struct {
 	TeamDomain      string
@@ -23,6 +32,9 @@ type TeamConfig struct {
 	IntraSecret     string
 	DatabaseURL     string
 	UserToken       string
+	SlackAPIURL     string
+	SigningSecret   string
+	EventTransport  string
 	LogChannel      slack.ChannelID
 	HTTPListen      string
 	HTTPURL         string
@@ -43,6 +55,12 @@ func LoadTeamConfig(sec *ini.Section) *TeamConfig {
 	c.IntraSecret = sec.Key("IntraSecret").String()
 	c.DatabaseURL = sec.Key("DatabaseURL").String()
 	c.UserToken = sec.Key("UserToken").String()
+	c.SlackAPIURL = sec.Key("SlackAPIURL").MustString(slack.DefaultAPIURL)
+	if !strings.HasSuffix(c.SlackAPIURL, "/") {
+		c.SlackAPIURL += "/"
+	}
+	c.SigningSecret = sec.Key("SigningSecret").String()
+	c.EventTransport = sec.Key("EventTransport").In(EventTransportRTM, []string{EventTransportRTM, EventTransportHTTP})
 	c.HTTPListen = sec.Key("HTTPListen").String()
 	c.HTTPURL = sec.Key("HTTPURL").String()
 	c.LogChannel = slack.ChannelID(sec.Key("LogChannel").String())